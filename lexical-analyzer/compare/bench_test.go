@@ -0,0 +1,41 @@
+package compare
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "analyzer/fsmlex"
+	_ "analyzer/genlex"
+	"analyzer/models"
+	_ "analyzer/rxlex"
+)
+
+// BenchmarkLex measures the throughput of the registered lexers on the
+// sources of this module, repeated to about a megabyte.
+func BenchmarkLex(b *testing.B) {
+	files, err := filepath.Glob("../*/*.go")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var sample strings.Builder
+	for _, file := range files {
+		text, err := os.ReadFile(file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sample.Write(text)
+	}
+	src := strings.Repeat(sample.String(), max(1, 1<<20/sample.Len()))
+
+	for _, name := range models.LexerNames() {
+		lexer := models.LookupLexer(name)
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			for b.Loop() {
+				lexer.Lex(src, models.Options{Semicolons: true})
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// Package compare runs several lexers over the same source and finds where
+// their token streams part. The standard library's go/scanner serves as the
+// reference.
+package compare
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"strings"
+	"unicode/utf8"
+
+	"analyzer/language"
+	"analyzer/models"
+)
+
+// Kind is a token kind all lexers agree on. Operators and separators are
+// both Punct, since go/scanner doesn't tell them apart, and the boolean
+// constants are identifiers.
+type Kind string
+
+const (
+	Ident   Kind = "IDENT"
+	Keyword Kind = "KEYWORD"
+	Int     Kind = "INT"
+	Float   Kind = "FLOAT"
+	Imag    Kind = "IMAG"
+	Rune    Kind = "CHAR"
+	String  Kind = "STRING"
+	Punct   Kind = "PUNCT"
+	Illegal Kind = "ILLEGAL"
+)
+
+// Token is a normalised token. Implicit semicolons have the text ";".
+type Token struct {
+	Kind   Kind
+	Text   string
+	Offset int
+}
+
+func (t Token) String() string {
+	return fmt.Sprintf("%s %q", t.Kind, t.Text)
+}
+
+// overlaps reports whether the source text of t and u overlaps.
+func (t Token) overlaps(u Token) bool {
+	return t.Offset < u.Offset+len(u.Text) && u.Offset < t.Offset+len(t.Text)
+}
+
+// Result is the outcome of lexing one source.
+type Result struct {
+	Tokens []Token
+
+	// ErrOffset is the offset of the earliest error, or -1. go/scanner
+	// reads one character ahead and may report an error in it before
+	// the error in the current token, so this isn't always the first one
+	// reported.
+	ErrOffset int
+	ErrMsg    string
+}
+
+func (r *Result) addError(offset int, msg string) {
+	if r.ErrOffset < 0 || offset < r.ErrOffset {
+		r.ErrOffset, r.ErrMsg = offset, msg
+	}
+}
+
+// Lexer lexes Go source with semicolon insertion and without comments.
+type Lexer struct {
+	Name string
+	Lex  func(src string) Result
+}
+
+// Scanner is the reference, go/scanner.
+var Scanner = Lexer{"go/scanner", scan}
+
+// Registered returns the Lexer for the lexer registered under name, if
+// there is one.
+func Registered(name string) (Lexer, bool) {
+	l := models.LookupLexer(name)
+	if l == nil {
+		return Lexer{}, false
+	}
+	return Lexer{name, lexWith(l.Lex)}, true
+}
+
+// Lexers lists the reference first, then the registered lexers that lex
+// Go by their names.
+func Lexers() []Lexer {
+	lexers := []Lexer{Scanner}
+	for _, name := range models.LexerNames() {
+		if models.Knows(models.LookupLexer(name), language.Go) {
+			l, _ := Registered(name)
+			lexers = append(lexers, l)
+		}
+	}
+	return lexers
+}
+
+func scan(src string) Result {
+	res := Result{ErrOffset: -1}
+	var s scanner.Scanner
+	errors := 0
+	file := token.NewFileSet().AddFile("", -1, len(src))
+	s.Init(file, []byte(src), func(pos token.Position, msg string) {
+		res.addError(pos.Offset, msg)
+		errors++
+	}, 0)
+
+	for {
+		before := errors
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return res
+		}
+		t := Token{Text: lit, Offset: file.Offset(pos)}
+		switch {
+		case tok == token.ILLEGAL:
+			// The text of an invalid encoding is U+FFFD
+			_, size := utf8.DecodeRuneInString(src[t.Offset:])
+			t.Kind, t.Text = Illegal, src[t.Offset:t.Offset+size]
+		case tok.IsLiteral() && errors > before && malformed(lit):
+			// The lexers make it an Error token. The error reported may
+			// also be in the character after it, which go/scanner reads
+			// ahead.
+			t.Kind = Illegal
+		case tok == token.IDENT:
+			t.Kind = Ident
+		case tok.IsKeyword():
+			t.Kind = Keyword
+		case tok == token.INT:
+			t.Kind = Int
+		case tok == token.FLOAT:
+			t.Kind = Float
+		case tok == token.IMAG:
+			t.Kind = Imag
+		case tok == token.CHAR:
+			t.Kind = Rune
+		case tok == token.STRING:
+			t.Kind = String
+		default:
+			t.Kind, t.Text = Punct, tok.String()
+		}
+		res.Tokens = append(res.Tokens, t)
+	}
+}
+
+// malformed reports whether go/scanner finds an error in a literal on its
+// own.
+func malformed(lit string) bool {
+	var s scanner.Scanner
+	found := false
+	file := token.NewFileSet().AddFile("", -1, len(lit))
+	s.Init(file, []byte(lit), func(token.Position, string) { found = true }, 0)
+	for tok := token.ILLEGAL; tok != token.EOF; {
+		_, tok, _ = s.Scan()
+	}
+	return found
+}
+
+var kinds = map[models.TokenType]Kind{
+	models.Identifier:       Ident,
+	models.BooleanLiteral:   Ident,
+	models.PredeclaredType:  Ident,
+	models.BuiltinFunc:      Ident,
+	models.Nil:              Ident,
+	models.Iota:             Ident,
+	models.Keyword:          Keyword,
+	models.IntLiteral:       Int,
+	models.FloatLiteral:     Float,
+	models.ImaginaryLiteral: Imag,
+	models.RuneLiteral:      Rune,
+	models.StringLiteral:    String,
+	models.Operator:         Punct,
+	models.Separator:        Punct,
+	models.Error:            Illegal,
+}
+
+func lexWith(lex func(string, models.Options) ([]models.Token, []models.Diagnostic)) func(string) Result {
+	return func(src string) Result {
+		tokens, diags := lex(src, models.Options{Semicolons: true})
+		res := Result{ErrOffset: -1}
+		for _, d := range diags {
+			res.addError(d.Pos.Offset, d.Message)
+		}
+		for _, t := range tokens {
+			if t.Type == models.Error && (strings.HasPrefix(t.Value, "//") || strings.HasPrefix(t.Value, "/*")) {
+				// A comment with an error, comments are left out
+				continue
+			}
+			text := t.Value
+			if (t.Type == models.StringLiteral || t.Type == models.Error) && strings.HasPrefix(text, "`") {
+				// go/scanner drops carriage returns from raw strings
+				text = strings.ReplaceAll(text, "\r", "")
+			}
+			res.Tokens = append(res.Tokens, Token{Kind: kinds[t.Type], Text: text, Offset: t.Pos.Offset})
+		}
+		return res
+	}
+}
@@ -0,0 +1,204 @@
+package compare
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"testing"
+
+	"analyzer/fsmlex"
+	_ "analyzer/genlex"
+	"analyzer/models"
+	_ "analyzer/rxlex"
+)
+
+// The lexers under test
+var (
+	FSM = registered("fsm")
+	RX  = registered("rx")
+	Gen = registered("gen")
+)
+
+func registered(name string) Lexer {
+	l, ok := Registered(name)
+	if !ok {
+		panic("lexer " + name + " is not registered")
+	}
+	return l
+}
+
+var seeds = []string{
+	"package p\n\nfunc f(a ...int) { return }\n",
+	"x := y[1:2] &^ ~z\n",
+	"s := \"a\\tb\" + `raw\r\nstring` + 'x' + '\\u00e9'\n",
+	"n := 0x1p-2 + 1_000 + 0o17 + 0b1 + .5e3 + 2i\n",
+	"a /* x\ny */ b // c\n",
+	"\uFEFFpackage p",
+	"x := 08",
+	"s := \"unterminated\n",
+	"a # b",
+	"a\xffb",
+	"",
+}
+
+func TestDiff(t *testing.T) {
+	for _, src := range seeds {
+		tokens, errors := Diff(src, Lexers()...)
+		for _, d := range []*Divergence{tokens, errors} {
+			if d != nil {
+				t.Errorf("Diff(%q):\n%s", src, d.Format("src.go", src))
+			}
+		}
+	}
+}
+
+func TestLexers(t *testing.T) {
+	var names []string
+	for _, l := range Lexers() {
+		names = append(names, l.Name)
+	}
+	if want := []string{"go/scanner", "fsm", "gen", "rx"}; !slices.Equal(names, want) {
+		t.Errorf("Lexers() = %q; want %q", names, want)
+	}
+}
+
+func TestDiffReports(t *testing.T) {
+	// A lexer that drops the last token, one that invents an error, one
+	// that drops the semicolon after an error and one that accepts all
+	// numbers
+	short := Lexer{"short", func(src string) Result {
+		res := FSM.Lex(src)
+		res.Tokens = res.Tokens[:len(res.Tokens)-1]
+		return res
+	}}
+	failing := Lexer{"failing", func(src string) Result {
+		res := FSM.Lex(src)
+		res.ErrOffset, res.ErrMsg = 2, "boom"
+		return res
+	}}
+	nosemi := Lexer{"nosemi", func(src string) Result {
+		res := FSM.Lex(src)
+		res.Tokens = slices.Delete(res.Tokens, 3, 4)
+		return res
+	}}
+	unchecked := Lexer{"unchecked", func(src string) Result {
+		res := FSM.Lex(src)
+		res.Tokens[2].Kind = Int
+		res.ErrOffset = -1
+		return res
+	}}
+
+	tests := []struct {
+		lexer Lexer
+		src   string
+		want  string
+	}{
+		{short, "a := 1\nb := 2", "src.go:2:7: short diverges from go/scanner: token 7 is missing, want PUNCT \";\"\n" +
+			"\tb := 2\n" +
+			"\t      ^\n" +
+			"\tgo/scanner: [IDENT \"b\" PUNCT \":=\" INT \"2\" PUNCT \";\"]\n" +
+			"\tshort: [IDENT \"b\" PUNCT \":=\" INT \"2\"]\n"},
+		{failing, "a := 1\nb := 2", "src.go:1:3: failing differs in errors from go/scanner: unexpected error: boom\n" +
+			"\ta := 1\n" +
+			"\t  ^\n"},
+		// Tokens after an error are still compared
+		{nosemi, "a := 08\nb := 2", "src.go:1:8: nosemi diverges from go/scanner: token 2 is IDENT \"b\", want PUNCT \";\"\n" +
+			"\ta := 08\n" +
+			"\t       ^\n" +
+			"\tgo/scanner: [IDENT \"a\" PUNCT \":=\" PUNCT \";\" IDENT \"b\" PUNCT \":=\" INT \"2\"]\n" +
+			"\tnosemi: [IDENT \"a\" PUNCT \":=\" IDENT \"b\" PUNCT \":=\" INT \"2\" PUNCT \";\"]\n"},
+		{unchecked, "a := 08\nb := 2", "src.go:1:6: unchecked differs in errors from go/scanner: token 0 is missing, want ILLEGAL \"08\"\n" +
+			"\ta := 08\n" +
+			"\t     ^\n" +
+			"\tgo/scanner: [ILLEGAL \"08\"]\n" +
+			"\tunchecked: []\n"},
+	}
+
+	for _, tt := range tests {
+		tokens, errors := Diff(tt.src, Scanner, tt.lexer)
+		if tokens != nil && errors != nil {
+			t.Errorf("%s: divergences in both tokens and errors", tt.lexer.Name)
+		}
+		d := cmp.Or(tokens, errors)
+		if d == nil {
+			t.Errorf("%s: no divergence", tt.lexer.Name)
+			continue
+		}
+		if got := d.Format("src.go", tt.src); got != tt.want {
+			t.Errorf("%s: divergence\n%s\nwant\n%s", tt.lexer.Name, got, tt.want)
+		}
+	}
+}
+
+func FuzzFSM(f *testing.F) {
+	for _, src := range seeds {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		tokens, errors := Diff(src, Scanner, FSM)
+		for _, d := range []*Divergence{tokens, errors} {
+			if d != nil {
+				t.Fatal(d.Format("src.go", src))
+			}
+		}
+	})
+}
+
+func FuzzRX(f *testing.F) {
+	for _, src := range seeds {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		tokens, errors := Diff(src, Scanner, RX)
+		for _, d := range []*Divergence{tokens, errors} {
+			if d != nil {
+				t.Fatal(d.Format("src.go", src))
+			}
+		}
+	})
+}
+
+func FuzzGen(f *testing.F) {
+	for _, src := range seeds {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		tokens, errors := Diff(src, Scanner, Gen)
+		for _, d := range []*Divergence{tokens, errors} {
+			if d != nil {
+				t.Fatal(d.Format("src.go", src))
+			}
+		}
+	})
+}
+
+// FuzzStream checks that fsmlex gives the same tokens when streamed in
+// small chunks.
+func FuzzStream(f *testing.F) {
+	for _, src := range seeds {
+		f.Add(src, 3)
+	}
+	f.Fuzz(func(t *testing.T, src string, size int) {
+		want := FSM.Lex(src)
+		got := streamed(src, size%16)
+		tokens, errors := diff(want, got)
+		for _, d := range []*Divergence{tokens, errors} {
+			if d != nil {
+				d.Ref, d.Lexer = "fsmlex", "fsmlex stream"
+				t.Fatal(strings.TrimSpace(d.Format("src.go", src)))
+			}
+		}
+	})
+}
+
+func streamed(src string, size int) Result {
+	return lexWith(func(src string, opts models.Options) ([]models.Token, []models.Diagnostic) {
+		l := fsmlex.NewLexerSize(strings.NewReader(src), size)
+		l.SetOptions(opts)
+		var tokens []models.Token
+		for token := range l.All() {
+			tokens = append(tokens, token)
+		}
+		return tokens, l.Diagnostics()
+	})(src)
+}
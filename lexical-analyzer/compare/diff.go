@@ -0,0 +1,166 @@
+package compare
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"analyzer/models"
+)
+
+// context is the number of tokens shown on each side of a divergence
+const context = 3
+
+// Divergence describes where a lexer parts from the reference.
+type Divergence struct {
+	Ref, Lexer string // names of the reference and the diverging lexer
+	Offset     int    // source offset of the divergence
+	Reason     string
+
+	// Errors is set if the lexers part only in what they make errors: the
+	// error tokens, how the text of errors is split into them, or where
+	// errors are reported
+	Errors bool
+
+	// Tokens of both lexers around the divergence
+	Want, Got []Token
+}
+
+// Diff runs the lexers over src and compares each with the first one. It
+// returns the first divergence in the tokens and the first in the errors,
+// each nil if there is none.
+//
+// The tokens are compared all through the source, errors or not, but
+// without the error tokens and the tokens they overlap in the other lexer:
+// the lexers may split the text of an error differently and still agree
+// on everything around it, such as an implicit semicolon after it. The
+// error tokens are compared on their own.
+func Diff(src string, lexers ...Lexer) (tokens, errors *Divergence) {
+	// go/scanner reports a UTF-16 byte order mark and skips everything
+	// after it, where the lexers go on
+	if strings.HasPrefix(src, "\xff\xfe") || strings.HasPrefix(src, "\xfe\xff") {
+		return nil, nil
+	}
+	ref := lexers[0].Lex(src)
+	for _, lexer := range lexers[1:] {
+		t, e := diff(ref, lexer.Lex(src))
+		if t != nil && tokens == nil {
+			t.Ref, t.Lexer = lexers[0].Name, lexer.Name
+			tokens = t
+		}
+		if e != nil && errors == nil {
+			e.Ref, e.Lexer = lexers[0].Name, lexer.Name
+			errors = e
+		}
+	}
+	return tokens, errors
+}
+
+func diff(ref, res Result) (tokens, errors *Divergence) {
+	tokens = diffTokens(valid(ref.Tokens, res.Tokens), valid(res.Tokens, ref.Tokens))
+	errors = diffTokens(illegal(ref.Tokens), illegal(res.Tokens))
+	if errors == nil {
+		switch {
+		case ref.ErrOffset == res.ErrOffset:
+		case res.ErrOffset < 0:
+			errors = &Divergence{Offset: ref.ErrOffset, Reason: "no error reported, want " + ref.ErrMsg}
+		case ref.ErrOffset < 0:
+			errors = &Divergence{Offset: res.ErrOffset, Reason: "unexpected error: " + res.ErrMsg}
+		default:
+			errors = &Divergence{
+				Offset: min(ref.ErrOffset, res.ErrOffset),
+				Reason: fmt.Sprintf("error at offset %d (%s), want offset %d (%s)", res.ErrOffset, res.ErrMsg, ref.ErrOffset, ref.ErrMsg),
+			}
+		}
+	}
+	if errors != nil {
+		errors.Errors = true
+	}
+	return tokens, errors
+}
+
+// diffTokens returns the first divergence of two token lists, or nil.
+func diffTokens(want, got []Token) *Divergence {
+	i := 0
+	for i < len(want) && i < len(got) && want[i] == got[i] {
+		i++
+	}
+	if i == len(want) && i == len(got) {
+		return nil
+	}
+	d := &Divergence{
+		Reason: fmt.Sprintf("token %d is %s, want %s", i, describe(got, i), describe(want, i)),
+		Want:   around(want, i),
+		Got:    around(got, i),
+	}
+	if i < len(want) {
+		d.Offset = want[i].Offset
+	} else {
+		d.Offset = got[i].Offset
+	}
+	return d
+}
+
+// valid returns the tokens that are not errors and don't overlap an error
+// token of the other lexer.
+func valid(tokens, other []Token) []Token {
+	errors := illegal(other)
+	var list []Token
+	for _, t := range tokens {
+		if t.Kind != Illegal && !slices.ContainsFunc(errors, t.overlaps) {
+			list = append(list, t)
+		}
+	}
+	return list
+}
+
+// illegal returns the error tokens.
+func illegal(tokens []Token) []Token {
+	var list []Token
+	for _, t := range tokens {
+		if t.Kind == Illegal {
+			list = append(list, t)
+		}
+	}
+	return list
+}
+
+func describe(tokens []Token, i int) string {
+	if i < len(tokens) {
+		return tokens[i].String()
+	}
+	return "missing"
+}
+
+func around(tokens []Token, i int) []Token {
+	return tokens[max(i-context, 0):min(i+context+1, len(tokens))]
+}
+
+// Format renders the divergence with the source line it is on, marking the
+// offending column, followed by the tokens of both lexers around it.
+func (d *Divergence) Format(file, src string) string {
+	pos := models.NewPosTable(src).Position(d.Offset)
+	line := src[pos.Offset-pos.Column+1:]
+	if end := strings.IndexByte(line, '\n'); end >= 0 {
+		line = line[:end]
+	}
+
+	var b strings.Builder
+	what := "diverges from"
+	if d.Errors {
+		what = "differs in errors from"
+	}
+	fmt.Fprintf(&b, "%s:%d:%d: %s %s %s: %s\n", file, pos.Line, pos.Column, d.Lexer, what, d.Ref, d.Reason)
+	// Keep tabs so that the marker lines up with the text
+	indent := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, line[:pos.Column-1])
+	fmt.Fprintf(&b, "\t%s\n\t%s^\n", line, indent)
+	if d.Want != nil || d.Got != nil {
+		fmt.Fprintf(&b, "\t%s: %v\n\t%s: %v\n", d.Ref, d.Want, d.Lexer, d.Got)
+	}
+	return b.String()
+}
@@ -0,0 +1,104 @@
+package compare
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"analyzer/fsmlex"
+	"analyzer/language"
+	"analyzer/models"
+	"analyzer/rxlex"
+)
+
+var languageSeeds = []struct {
+	lang *models.Language
+	src  string
+	want string // the tokens of both lexers, "type value" separated by spaces
+}{
+	{language.C, "int main(void) { return 0x1FUL; }\n",
+		"Keyword int Identifier main Separator ( Keyword void Separator ) Separator { Keyword return Int 0x1FUL Separator ; Separator }"},
+	{language.C, "#define MAX(a, b) \\\n\t((a) > (b) ? (a) : (b))\n",
+		"Operator # Identifier define Identifier MAX Separator ( Identifier a Separator , Identifier b Separator ) " +
+			"Separator ( Separator ( Identifier a Separator ) Operator > Separator ( Identifier b Separator ) Operator ? " +
+			"Separator ( Identifier a Separator ) Separator : Separator ( Identifier b Separator ) Separator )"},
+	{language.C, "p->x = 'a' + .5f; /* c */ s = \"\\x41\\101\";",
+		"Identifier p Operator -> Identifier x Operator = Rune 'a' Operator + Float .5f Separator ; " +
+			"Identifier s Operator = String \"\\x41\\101\" Separator ;"},
+	{language.C, "a ## b 08 1e 10lul `x` @",
+		"Identifier a Operator ## Identifier b ERROR 08 ERROR 1e ERROR 10lul ERROR ` Identifier x ERROR ` ERROR @"},
+	{language.JSON, `{"a": [1, -2.5e3, true, null], "b\u00e9": {}}`,
+		"Separator { String \"a\" Separator : Separator [ Int 1 Separator , Float -2.5e3 Separator , Boolean true " +
+			"Separator , Keyword null Separator ] Separator , String \"b\\u00e9\" Separator : Separator { Separator } Separator }"},
+	{language.JSON, `[01, 1., nope, "\x", 'a', /* c */]`,
+		"Separator [ ERROR 01 Separator , ERROR 1. Separator , ERROR nope Separator , ERROR \"\\x\" Separator , " +
+			"ERROR ' ERROR a ERROR ' Separator , ERROR / ERROR * ERROR c ERROR * ERROR / Separator ]"},
+	{language.JSON, "\"unterminated\n", "ERROR \"unterminated"},
+
+	// Every illegal character is an error of its own
+	{language.JSON, "[1 &^ 2]", "Separator [ Int 1 ERROR & ERROR ^ Int 2 Separator ]"},
+	{language.JSON, "[...]", "Separator [ ERROR . ERROR . ERROR . Separator ]"},
+	{language.C, "s = é\\\"ab\\\ncd\";",
+		"Identifier s Operator = ERROR é ERROR \\ ERROR \"ab\\ Identifier cd ERROR \";"},
+	{language.C, "/*\xd7", "ERROR /*\xd7"},
+}
+
+func TestLanguages(t *testing.T) {
+	for _, tt := range languageSeeds {
+		tokens, _ := fsmlex.LexOptions(tt.src, models.Options{Language: tt.lang})
+		var got []string
+		for _, token := range tokens {
+			got = append(got, fmt.Sprintf("%s %s", token.Type, token.Value))
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("%s %q:\ntokens %s\nwant   %s", tt.lang.Name, tt.src, strings.Join(got, " "), tt.want)
+		}
+		if d := diffLanguage(tt.lang, tt.src); d != "" {
+			t.Errorf("%s %q: %s", tt.lang.Name, tt.src, d)
+		}
+	}
+}
+
+// FuzzLanguages checks that fsmlex and rxlex agree on languages other than
+// Go, where go/scanner can't serve as the reference.
+func FuzzLanguages(f *testing.F) {
+	languages := []*models.Language{language.C, language.JSON}
+	for _, tt := range languageSeeds {
+		f.Add(uint8(slices.Index(languages, tt.lang)), tt.src)
+	}
+	f.Fuzz(func(t *testing.T, i uint8, src string) {
+		lang := languages[int(i)%len(languages)]
+		if d := diffLanguage(lang, src); d != "" {
+			t.Fatalf("%s %q: %s", lang.Name, src, d)
+		}
+	})
+}
+
+// diffLanguage describes the first difference between fsmlex and rxlex,
+// with and without trivia. Both recover from errors the same way, so the
+// Error tokens and the diagnostics must be the same as well.
+func diffLanguage(lang *models.Language, src string) string {
+	for _, trivia := range []bool{false, true} {
+		opts := models.Options{Language: lang, Trivia: trivia, Semicolons: true}
+		fsmTokens, fsmDiags := fsmlex.LexOptions(src, opts)
+		rxTokens, rxDiags := rxlex.LexOptions(src, opts)
+		for i := range max(len(fsmTokens), len(rxTokens)) {
+			if i >= len(fsmTokens) || i >= len(rxTokens) || fsmTokens[i] != rxTokens[i] {
+				return fmt.Sprintf("token %d differs with trivia %t:\nfsmlex %v\nrxlex  %v", i, trivia, fsmTokens, rxTokens)
+			}
+		}
+		if f, r := diagnostics(fsmDiags), diagnostics(rxDiags); !slices.Equal(f, r) {
+			return fmt.Sprintf("diagnostics differ:\nfsmlex %q\nrxlex  %q", f, r)
+		}
+	}
+	return ""
+}
+
+func diagnostics(diags []models.Diagnostic) []string {
+	var list []string
+	for _, d := range diags {
+		list = append(list, fmt.Sprintf("%d-%d: %s", d.Pos.Offset, d.End.Offset, d.Message))
+	}
+	return list
+}
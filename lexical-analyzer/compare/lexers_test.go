@@ -0,0 +1,287 @@
+package compare
+
+import (
+	"go/scanner"
+	"go/token"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"analyzer/language"
+	"analyzer/models"
+)
+
+// TestOptions checks the options every registered lexer supports, on top
+// of the tokens that Diff compares. Tests of what only one lexer does stay
+// in its package.
+func TestOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		lang *models.Language // the language the test needs, Go if nil
+		test func(t *testing.T, l models.Lexer)
+	}{
+		{"predeclared", nil, testPredeclared},
+		{"predeclared language", predeclaredLanguage, testPredeclaredLanguage},
+		{"decode", nil, testDecode},
+		{"bad escapes", nil, testBadEscapes},
+		{"undecoded", nil, testUndecoded},
+		{"semicolons", nil, testSemicolons},
+		{"trivia round trip", nil, testTriviaRoundTrip},
+		{"trivia tokens", nil, testTriviaTokens},
+	}
+	for _, name := range models.LexerNames() {
+		l := models.LookupLexer(name)
+		for _, tt := range tests {
+			lang := tt.lang
+			if lang == nil {
+				lang = language.Go
+			}
+			if !models.Knows(l, lang) {
+				continue
+			}
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				tt.test(t, l)
+			})
+		}
+	}
+}
+
+// lex lexes input and fails on diagnostics.
+func lex(t *testing.T, l models.Lexer, input string, opts models.Options) []models.Token {
+	t.Helper()
+	tokens, diags := l.Lex(input, opts)
+	if len(diags) > 0 {
+		t.Fatalf("Lex(%q) diagnostics: %v", input, diags)
+	}
+	return tokens
+}
+
+func testPredeclared(t *testing.T, l models.Lexer) {
+	input := "var err error = nil\nconst (a = iota; b)\nn := len(append(s, int8(x), true))\n"
+	want := []models.TokenType{
+		models.Keyword, models.Identifier, models.PredeclaredType, models.Operator, models.Nil, models.Separator,
+		models.Keyword, models.Separator, models.Identifier, models.Operator, models.Iota, models.Separator, models.Identifier, models.Separator, models.Separator,
+		models.Identifier, models.Operator, models.BuiltinFunc, models.Separator, models.BuiltinFunc, models.Separator, models.Identifier, models.Separator,
+		models.PredeclaredType, models.Separator, models.Identifier, models.Separator, models.Separator, models.BooleanLiteral, models.Separator, models.Separator, models.Separator,
+	}
+
+	for _, predeclared := range []bool{false, true} {
+		tokens := lex(t, l, input, models.Options{Semicolons: true, Predeclared: predeclared})
+		if len(tokens) != len(want) {
+			t.Fatalf("Lex(%q) returned %d tokens; want %d: %v", input, len(tokens), len(want), tokens)
+		}
+		for i, tok := range tokens {
+			typ := want[i]
+			switch typ {
+			case models.PredeclaredType, models.BuiltinFunc, models.Nil, models.Iota:
+				if !predeclared {
+					typ = models.Identifier
+				}
+			}
+			if tok.Type != typ {
+				t.Errorf("predeclared %t: token %d %q is %s; want %s", predeclared, i, tok.Value, tok.Type, typ)
+			}
+		}
+	}
+}
+
+// predeclaredLanguage has no predeclared identifiers.
+var predeclaredLanguage = &models.Language{
+	Identifiers: models.ASCIIIdentifiers,
+	Separators:  "()",
+	Whitespace:  " ",
+}
+
+// testPredeclaredLanguage checks that languages without predeclared
+// identifiers lex them as identifiers.
+func testPredeclaredLanguage(t *testing.T, l models.Lexer) {
+	tokens := lex(t, l, "int len(nil)", models.Options{Language: predeclaredLanguage, Predeclared: true})
+	for _, tok := range tokens {
+		if tok.Type != models.Identifier && tok.Type != models.Separator {
+			t.Errorf("token %q is %s; want an identifier", tok.Value, tok.Type)
+		}
+	}
+}
+
+func testDecode(t *testing.T, l models.Lexer) {
+	input := "f(0x1F, 1.5, 2i, \"a\\tb\", `c`, '\\x41', true, x)"
+	tokens := lex(t, l, input, models.Options{Decode: true})
+
+	var values []any
+	for _, tok := range tokens {
+		if tok.Decoded != nil {
+			values = append(values, tok.Decoded)
+		}
+	}
+	if len(values) != 7 {
+		t.Fatalf("decoded values of %q = %v; want 7", input, values)
+	}
+
+	if x := values[0].(*big.Int); x.Int64() != 31 {
+		t.Errorf("0x1F decoded to %v", x)
+	}
+	if x, _ := values[1].(*big.Float).Float64(); x != 1.5 {
+		t.Errorf("1.5 decoded to %v", x)
+	}
+	if x, _ := values[2].(*big.Float).Float64(); x != 2 {
+		t.Errorf("2i decoded to %v", x)
+	}
+	if values[3] != "a\tb" || values[4] != "c" || values[5] != 'A' || values[6] != true {
+		t.Errorf("decoded values = %v", values[3:])
+	}
+	if tokens[0].Decoded != nil {
+		t.Errorf("identifier decoded to %v", tokens[0].Decoded)
+	}
+}
+
+func testBadEscapes(t *testing.T, l models.Lexer) {
+	for _, input := range []string{`"\q"`, `'ab'`, "\"a\nb\"", `"\x4"`} {
+		tokens, diags := l.Lex(input, models.Options{Decode: true})
+		if len(tokens) == 0 || tokens[0].Type != models.Error || tokens[0].Decoded != nil {
+			t.Errorf("Lex(%q) = %v; want an error first", input, tokens)
+		}
+		if len(diags) == 0 || diags[0].Severity != models.SeverityError {
+			t.Errorf("Lex(%q) diagnostics = %v; want an error", input, diags)
+		}
+	}
+}
+
+// testUndecoded checks that a float too big to decode is a warning.
+func testUndecoded(t *testing.T, l models.Lexer) {
+	input := "x := 1e10001"
+	tokens, diags := l.Lex(input, models.Options{Decode: true})
+	if len(tokens) != 3 || tokens[2].Type != models.FloatLiteral || tokens[2].Decoded != nil {
+		t.Fatalf("Lex(%q) = %v", input, tokens)
+	}
+	if len(diags) != 1 || diags[0].Severity != models.SeverityWarning || diags[0].Code != models.ErrUndecoded || diags[0].Pos.Column != 7 {
+		t.Errorf("Lex(%q) diagnostics = %v; want a warning at column 7", input, diags)
+	}
+}
+
+var semicolonInputs = []string{
+	"x\n",
+	"x",
+	"return\n",
+	"break; continue\n",
+	"f(a, b)\n}\n",
+	"x++\ny--\n",
+	"a[1]\n",
+	"a /* c */\nb",
+	"a // c\nb",
+	"a /* multi\nline */ b",
+	"a /* one */ /* two */\n",
+	"a /* one */ b\n",
+	"a /* x\n*/ /* y\n*/\n",
+	"x /* c */",
+	"s := `raw` + \"str\" + 'r'\n",
+	"if x {\n\treturn 1.5\n}\n",
+	"package p\n\nimport \"fmt\"\n\nfunc f() {\n\tfmt.Println(true)\n}\n",
+}
+
+// semicolonErrorInputs have lexical errors. A malformed literal ends a
+// line like the literal, an illegal character leaves the line as it was.
+var semicolonErrorInputs = []string{
+	"x := 08\ny",
+	"x := 1e\ny",
+	"x := \"ab\ny",
+	"x := '\\q'\ny",
+	"x := 'ab'\ny",
+	"x := \"a\xffb\"\ny",
+	"x := `ab",
+	"a @\nd",
+	"a \"bc\nd",
+	"a \xff\nb",
+	"a := @\nb",
+	"x /* a",
+	"a /* open\n",
+}
+
+// scannerSemicolons returns the offsets at which go/scanner inserts
+// semicolons into src.
+func scannerSemicolons(src string) []int {
+	var s scanner.Scanner
+	file := token.NewFileSet().AddFile("", -1, len(src))
+	s.Init(file, []byte(src), nil, 0)
+
+	var offsets []int
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return offsets
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			offsets = append(offsets, file.Offset(pos))
+		}
+	}
+}
+
+func testSemicolons(t *testing.T, l models.Lexer) {
+	for i, input := range append(semicolonInputs, semicolonErrorInputs...) {
+		tokens, diags := l.Lex(input, models.Options{Semicolons: true})
+		if len(diags) > 0 && i < len(semicolonInputs) {
+			t.Fatalf("Lex(%q) diagnostics: %v", input, diags)
+		}
+
+		var got []int
+		for _, tok := range tokens {
+			if tok.Implicit {
+				if tok.Type != models.Separator || tok.Value != ";" || tok.Pos != tok.End {
+					t.Errorf("%q: bad implicit token %+v", input, tok)
+				}
+				got = append(got, tok.Pos.Offset)
+			}
+		}
+
+		if want := scannerSemicolons(input); !reflect.DeepEqual(got, want) {
+			t.Errorf("semicolons in %q at %v; want %v", input, got, want)
+		}
+	}
+}
+
+func testTriviaRoundTrip(t *testing.T, l models.Lexer) {
+	example, err := os.ReadFile("../examples/example.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := append([]string{
+		string(example),
+		"a // c\nb",
+		"x /* block\n comment */ y\r\n",
+		"\t \n\n  z  ",
+		"s := `raw` + \"q\\\"\" + '\\''",
+	}, semicolonInputs...)
+
+	for _, opts := range []models.Options{{Trivia: true}, {Trivia: true, Semicolons: true}} {
+		for _, input := range inputs {
+			tokens := lex(t, l, input, opts)
+
+			var text strings.Builder
+			for _, tok := range tokens {
+				if !tok.Implicit {
+					text.WriteString(tok.Value)
+				}
+			}
+			if text.String() != input {
+				t.Errorf("%+v: tokens of %q add up to %q", opts, input, text.String())
+			}
+		}
+	}
+}
+
+func testTriviaTokens(t *testing.T, l models.Lexer) {
+	input := "a  // c\n/* d */"
+	tokens := lex(t, l, input, models.Options{Trivia: true})
+
+	var got []models.TokenType
+	for _, tok := range tokens {
+		got = append(got, tok.Type)
+	}
+
+	want := []models.TokenType{models.Identifier, models.Whitespace, models.Comment, models.Newline, models.Comment}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("types of %q = %v; want %v", input, got, want)
+	}
+}
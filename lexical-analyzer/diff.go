@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"analyzer/compare"
+	"analyzer/driver"
+	"analyzer/language"
+	"analyzer/models"
+)
+
+const diffUsage = "diff [FLAGS] PATH..."
+
+// diffCommand compares the registered lexers of Go with go/scanner on the
+// given files and on the Go files below the given directories. It returns
+// the exit code.
+func diffCommand(args []string) int {
+	fs := newFlagSet("diff", diffUsage)
+	s := selection{fallback: func(path string) bool { return strings.HasSuffix(path, ".go") }}
+	s.addFlags(fs)
+	workers := fs.Int("j", 0, "compare `N` files at once (default the number of CPUs)")
+	names := fs.String("lexers", "", "compare only the comma-separated `NAMES` of lexers (default all that lex go)")
+	if code, stop := parseFlags(fs, args); stop {
+		return code
+	}
+	lexers := compare.Lexers()
+	if *names != "" {
+		lexers = []compare.Lexer{compare.Scanner}
+		for _, name := range strings.Split(*names, ",") {
+			l, ok := compare.Registered(name)
+			if !ok {
+				return usageError(fs, "unknown lexer %q, want %s", name, oneOf(models.LexerNames()))
+			}
+			if !models.Knows(models.LookupLexer(name), language.Go) {
+				return usageError(fs, "the %s lexer doesn't lex go", name)
+			}
+			lexers = append(lexers, l)
+		}
+	}
+	if *workers < 0 {
+		return usageError(fs, "-j must not be negative")
+	}
+	if fs.NArg() == 0 {
+		return usageError(fs, "no files to compare")
+	}
+	paths, err := s.files(fs.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+
+	// The divergences are formatted by the workers, who have the source
+	type result struct {
+		name, tokens, errors string
+		err                  error
+	}
+	compareFile := func(path string) result {
+		name, src, err := readFile(path)
+		if err != nil {
+			return result{err: err}
+		}
+		r := result{name: name}
+		tokens, errors := compare.Diff(string(src), lexers...)
+		if tokens != nil {
+			r.tokens = tokens.Format(name, string(src))
+		}
+		if errors != nil {
+			r.errors = errors.Format(name, string(src))
+		}
+		return r
+	}
+
+	ctx, stop := interruptible()
+	defer stop()
+	files, diverged, errors := 0, 0, 0
+	err = driver.Map(ctx, *workers, paths, compareFile, func(r result) error {
+		if r.err != nil {
+			return r.err
+		}
+		files++
+		if r.tokens != "" {
+			diverged++
+			fmt.Fprint(stdout, r.tokens)
+		}
+		if r.errors != "" {
+			errors++
+			fmt.Fprint(stdout, r.errors)
+		}
+		return nil
+	})
+
+	fmt.Fprintf(stderr, "%d files, %d diverge, %d differ in errors\n", files, diverged, errors)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+	if diverged > 0 || errors > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,175 @@
+// Package driver lexes many files at once. A bounded pool of workers reads
+// and lexes the files while the results come back in the order the files
+// were given, so the output doesn't depend on the number of workers or on
+// timing.
+package driver
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"analyzer/models"
+)
+
+// File is the result of lexing one file.
+type File struct {
+	Path        string // as given to Run
+	Name        string // the name to show, from Config.Read
+	Src         []byte // the contents of the file
+	Tokens      []models.Token
+	Diagnostics []models.Diagnostic
+	Err         error // reading the file failed, the file isn't lexed
+}
+
+// Config configures Run.
+type Config struct {
+	// Workers is the number of files lexed at the same time, by default
+	// runtime.GOMAXPROCS(0).
+	Workers int
+
+	// Read reads a file and returns the name to show for it. By default
+	// the file is read with os.ReadFile and the name is the path.
+	Read func(path string) (name string, src []byte, err error)
+
+	// Lex lexes a file. It is called from several goroutines at once;
+	// fsmlex.LexOptions and rxlex.LexOptions are safe for that.
+	Lex func(name string, src []byte) ([]models.Token, []models.Diagnostic)
+}
+
+// Summary adds up the files of a run.
+type Summary struct {
+	Files      int // files read and lexed
+	Failed     int // files that couldn't be read
+	WithErrors int // files with at least one error
+	Bytes      int
+	Tokens     int
+	Errors     int // diagnostics that are errors
+	Warnings   int
+	Elapsed    time.Duration
+}
+
+func (s *Summary) add(f File) {
+	if f.Err != nil {
+		s.Failed++
+		return
+	}
+	s.Files++
+	s.Bytes += len(f.Src)
+	s.Tokens += len(f.Tokens)
+	for _, d := range f.Diagnostics {
+		if d.Severity == models.SeverityError {
+			s.Errors++
+		} else {
+			s.Warnings++
+		}
+	}
+	if models.HasErrors(f.Diagnostics) {
+		s.WithErrors++
+	}
+}
+
+// Run lexes the files named by paths and calls emit with each of them, in
+// the order of paths and from the goroutine Run was called on. Run stops at
+// the first error emit returns or when ctx is done, and returns that error.
+// The summary covers the files emitted until then.
+func (c Config) Run(ctx context.Context, paths []string, emit func(File) error) (Summary, error) {
+	read := c.Read
+	if read == nil {
+		read = func(path string) (string, []byte, error) {
+			src, err := os.ReadFile(path)
+			return path, src, err
+		}
+	}
+	lex := func(path string) File {
+		name, src, err := read(path)
+		if err != nil {
+			return File{Path: path, Name: name, Err: err}
+		}
+		tokens, diags := c.Lex(name, src)
+		return File{Path: path, Name: name, Src: src, Tokens: tokens, Diagnostics: diags}
+	}
+
+	var summary Summary
+	start := time.Now()
+	err := Map(ctx, c.Workers, paths, lex, func(f File) error {
+		summary.add(f)
+		return emit(f)
+	})
+	summary.Elapsed = time.Since(start)
+	return summary, err
+}
+
+// Map calls f with each of the inputs on up to workers goroutines at once,
+// by default runtime.GOMAXPROCS(0), and emit with the results in the order
+// of the inputs on the calling goroutine. At most twice as many results as
+// workers wait for emit, so slow consumers hold the workers back. Map
+// stops at the first error emit returns or when ctx is done, and returns
+// that error; the goroutines have finished when it returns.
+func Map[In, Out any](ctx context.Context, workers int, inputs []In, f func(In) Out, emit func(Out) error) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type job struct {
+		in   In
+		done chan Out
+	}
+	jobs := make(chan job)
+	// pending holds the channels of the results in input order
+	pending := make(chan chan Out, 2*workers)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		defer close(pending)
+		for _, in := range inputs {
+			done := make(chan Out, 1)
+			select {
+			case pending <- done:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- job{in, done}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if ctx.Err() == nil {
+					j.done <- f(j.in)
+				}
+			}
+		}()
+	}
+
+	emitted := 0
+	for done := range pending {
+		select {
+		case out := <-done:
+			if err := emit(out); err != nil {
+				return err
+			}
+			emitted++
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if emitted < len(inputs) {
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"analyzer/fsmlex"
+	"analyzer/language"
+	"analyzer/models"
+	"analyzer/rxlex"
+)
+
+func TestMapOrder(t *testing.T) {
+	inputs := make([]int, 200)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	for _, workers := range []int{0, 1, 3, 16} {
+		var running, most atomic.Int32
+		f := func(i int) int {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+			}
+			// Later inputs finish first
+			time.Sleep(time.Duration(len(inputs)-i) * time.Microsecond)
+			return i * i
+		}
+		var got []int
+		err := Map(context.Background(), workers, inputs, f, func(out int) error {
+			got = append(got, out)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%d workers: %v", workers, err)
+		}
+		for i, out := range got {
+			if out != i*i {
+				t.Fatalf("%d workers: result %d is %d; want %d", workers, i, out, i*i)
+			}
+		}
+		if len(got) != len(inputs) {
+			t.Errorf("%d workers: %d results; want %d", workers, len(got), len(inputs))
+		}
+		if workers > 0 && int(most.Load()) > workers {
+			t.Errorf("%d workers: %d calls at once", workers, most.Load())
+		}
+	}
+}
+
+func TestMapStop(t *testing.T) {
+	inputs := make([]int, 1000)
+	stop := errors.New("stop")
+	var calls atomic.Int32
+	f := func(i int) int { calls.Add(1); return i }
+
+	emitted := 0
+	err := Map(context.Background(), 4, inputs, f, func(int) error {
+		if emitted++; emitted == 10 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || emitted != 10 {
+		t.Errorf("emit error: got %v after %d results; want %v after 10", err, emitted, stop)
+	}
+	// The results waiting for emit are bounded
+	if n := calls.Load(); n > 10+3*4 {
+		t.Errorf("emit error: %d calls; want at most %d", n, 10+3*4)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	emitted = 0
+	err = Map(ctx, 4, inputs, f, func(int) error {
+		if emitted++; emitted == 10 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled || emitted >= len(inputs) {
+		t.Errorf("cancel: got %v after %d results; want %v before the end", err, emitted, context.Canceled)
+	}
+
+	if err := Map(ctx, 4, inputs, f, func(int) error { return nil }); err != context.Canceled {
+		t.Errorf("cancelled context: got %v; want %v", err, context.Canceled)
+	}
+	if err := Map(ctx, 4, nil, f, func(int) error { return nil }); err != nil {
+		t.Errorf("no inputs: got %v; want nil", err)
+	}
+}
+
+// sources returns the Go sources of this module and sources of the other
+// languages, so that runs mix the grammars the lexers cache.
+func sources(t testing.TB) map[string]string {
+	files, err := filepath.Glob("../*/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcs := map[string]string{
+		"x.c":    "int main(void) { return 0x1Fu + 'a'; } /* done */",
+		"x.json": `{"a": [1, 2.5e3, true, null], "b": "é"}`,
+		"bad.go": "x := 08 + '",
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srcs[file] = string(src)
+	}
+	return srcs
+}
+
+func TestRun(t *testing.T) {
+	srcs := sources(t)
+	var paths []string
+	for path := range srcs {
+		paths = append(paths, path)
+	}
+	paths = append(paths, "missing.go")
+	read := func(path string) (string, []byte, error) {
+		src, ok := srcs[path]
+		if !ok {
+			return path, nil, fmt.Errorf("open %s: no such file", path)
+		}
+		return strings.ToUpper(path), []byte(src), nil
+	}
+
+	lexers := []struct {
+		name string
+		lex  func(string, models.Options) ([]models.Token, []models.Diagnostic)
+	}{
+		{"fsmlex", fsmlex.LexOptions},
+		{"rxlex", rxlex.LexOptions},
+	}
+	for _, lexer := range lexers {
+		lex := func(name string, src []byte) ([]models.Token, []models.Diagnostic) {
+			opts := models.Options{Semicolons: true, Trivia: true, Decode: true}
+			if lang := language.ForFile(strings.ToLower(name)); lang != nil {
+				opts.Language = lang
+			}
+			return lexer.lex(string(src), opts)
+		}
+
+		var want []File
+		var wantSummary Summary
+		for _, path := range paths {
+			name, src, err := read(path)
+			if err != nil {
+				want = append(want, File{Path: path, Name: name, Err: err})
+				wantSummary.add(want[len(want)-1])
+				continue
+			}
+			tokens, diags := lex(name, src)
+			want = append(want, File{Path: path, Name: name, Src: src, Tokens: tokens, Diagnostics: diags})
+			wantSummary.add(want[len(want)-1])
+		}
+
+		config := Config{Workers: 2 * runtime.GOMAXPROCS(0), Read: read, Lex: lex}
+		var got []File
+		summary, err := config.Run(context.Background(), paths, func(f File) error {
+			got = append(got, f)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", lexer.name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: the concurrent run differs from lexing one file after the other", lexer.name)
+		}
+		if summary.Elapsed <= 0 {
+			t.Errorf("%s: elapsed time %v", lexer.name, summary.Elapsed)
+		}
+		summary.Elapsed = 0
+		if summary != wantSummary {
+			t.Errorf("%s: summary %+v; want %+v", lexer.name, summary, wantSummary)
+		}
+		if summary.Failed != 1 || summary.WithErrors != 1 || summary.Files != len(paths)-1 {
+			t.Errorf("%s: summary %+v; want 1 failed file and 1 with errors", lexer.name, summary)
+		}
+	}
+}
+
+// BenchmarkRun lexes the sources of this module with more and more
+// workers. The throughput should grow about linearly up to the number of
+// cores.
+func BenchmarkRun(b *testing.B) {
+	srcs := sources(b)
+	var paths []string
+	size := 0
+	for path, src := range srcs {
+		paths = append(paths, path)
+		size += len(src)
+	}
+	config := Config{
+		Read: func(path string) (string, []byte, error) { return path, []byte(srcs[path]), nil },
+		Lex: func(name string, src []byte) ([]models.Token, []models.Diagnostic) {
+			return fsmlex.LexOptions(string(src), models.Options{Semicolons: true})
+		},
+	}
+	for workers := 1; workers <= runtime.GOMAXPROCS(0); workers *= 2 {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			config.Workers = workers
+			b.SetBytes(int64(size))
+			for b.Loop() {
+				config.Run(context.Background(), paths, func(File) error { return nil })
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package fsmlex
+
+import (
+	"go/scanner"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"analyzer/models"
+)
+
+var diagnosticInputs = []struct {
+	input string
+	code  models.Code
+}{
+	{"a # b", models.ErrIllegalCharacter},
+	{"x := 08 + y", models.ErrInvalidNumber},
+	{"x := 1__0", models.ErrInvalidNumber},
+	{`s := "a\qb"`, models.ErrInvalidEscape},
+	{`s := "\u12"`, models.ErrInvalidEscape},
+	{"s := \"abc\nx", models.ErrUnterminated},
+	{"r := 'ab'", models.ErrInvalidRune},
+	{"x /* open", models.ErrUnterminated},
+	{"s := `open", models.ErrUnterminated},
+	{"s := \"a\xffb\"", models.ErrInvalidEncoding},
+	{"a \uFEFF b", models.ErrInvalidEncoding},
+	{"s := \"a\x00b\"", models.ErrIllegalCharacter},
+}
+
+func TestDiagnostics(t *testing.T) {
+	for _, tt := range diagnosticInputs {
+		var s scanner.Scanner
+		var first token.Position
+		file := token.NewFileSet().AddFile("", -1, len(tt.input))
+		s.Init(file, []byte(tt.input), func(pos token.Position, _ string) {
+			if !first.IsValid() {
+				first = pos
+			}
+		}, 0)
+		for _, tok, _ := s.Scan(); tok != token.EOF; _, tok, _ = s.Scan() {
+		}
+
+		tokens, diags := LexOptions(tt.input, models.Options{})
+		if len(diags) == 0 {
+			t.Errorf("LexOptions(%q) reports no diagnostics", tt.input)
+			continue
+		}
+		d := diags[0]
+		if d.Code != tt.code || d.Severity != models.SeverityError {
+			t.Errorf("LexOptions(%q) diagnostic = %v %v; want %v", tt.input, d.Severity, d.Code, tt.code)
+		}
+		if d.Pos.Offset != first.Offset || d.Pos.Line != first.Line || d.Pos.Column != first.Column {
+			t.Errorf("LexOptions(%q) diagnostic at %d:%d; go/scanner reports %d:%d", tt.input, d.Pos.Line, d.Pos.Column, first.Line, first.Column)
+		}
+
+		// Lexing goes on past the error
+		var errors int
+		for _, token := range tokens {
+			if token.Type == models.Error {
+				errors++
+			}
+		}
+		if errors != 1 {
+			t.Errorf("LexOptions(%q) = %v; want one error token", tt.input, tokens)
+		}
+	}
+}
+
+func TestDiagnosticsAfterError(t *testing.T) {
+	_, diags := LexOptions("a # b\nc := 08 @", models.Options{})
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.Format("f.go"))
+	}
+	want := []string{
+		"f.go:1:3: error: illegal character U+0023 '#' [L0001]",
+		"f.go:2:7: error: invalid digit '8' in octal literal [L0004]\n\thint: remove the leading zero for a decimal literal",
+		"f.go:2:9: error: illegal character U+0040 '@' [L0001]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diagnostics = %q; want %q", got, want)
+	}
+}
+
+func TestLexerDiagnostics(t *testing.T) {
+	for _, tt := range diagnosticInputs {
+		_, want := LexOptions(tt.input, models.Options{})
+		l := NewLexerSize(strings.NewReader(tt.input), 2)
+		collect(t, l)
+		if got := l.Diagnostics(); !reflect.DeepEqual(got, want) {
+			t.Errorf("diagnostics of %q = %v; want %v", tt.input, got, want)
+		}
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	input := "a # b # c # d"
+	opts := models.Options{MaxErrors: 2}
+	tokens, diags := LexOptions(input, opts)
+	if len(tokens) != 4 || tokens[3].Type != models.Error {
+		t.Errorf("tokens = %v; want lexing to stop after the second error", tokens)
+	}
+	if len(diags) != 3 || diags[2].Code != models.ErrTooManyErrors || diags[2].Pos.Column != 8 {
+		t.Errorf("diagnostics = %v; want two errors and too many errors at 1:8", diags)
+	}
+
+	l := NewLexerSize(strings.NewReader(input), 3)
+	l.SetOptions(opts)
+	if got := collect(t, l); !reflect.DeepEqual(got, tokens) {
+		t.Errorf("streamed tokens = %v; want %v", got, tokens)
+	}
+	if got := l.Diagnostics(); !reflect.DeepEqual(got, diags) {
+		t.Errorf("streamed diagnostics = %v; want %v", got, diags)
+	}
+}
+
+// TestLexerTakeDiagnostics checks that taking the diagnostics as they come
+// hands out each once and leaves MaxErrors counting them.
+func TestLexerTakeDiagnostics(t *testing.T) {
+	input := "a # b # c # d"
+	for _, opts := range []models.Options{{}, {MaxErrors: 2}} {
+		tokens, diags := LexOptions(input, opts)
+		l := NewLexerSize(strings.NewReader(input), 3)
+		l.SetOptions(opts)
+		var got []models.Token
+		var taken []models.Diagnostic
+		for token, err := range l.All() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, token)
+			taken = append(taken, l.TakeDiagnostics()...)
+			if kept := l.Diagnostics(); len(kept) > 0 {
+				t.Errorf("%+v: diagnostics %v kept after TakeDiagnostics", opts, kept)
+			}
+		}
+		taken = append(taken, l.TakeDiagnostics()...)
+		if !reflect.DeepEqual(got, tokens) {
+			t.Errorf("%+v: tokens = %v; want %v", opts, got, tokens)
+		}
+		if !reflect.DeepEqual(taken, diags) {
+			t.Errorf("%+v: taken diagnostics = %v; want %v", opts, taken, diags)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package fsmlex
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"analyzer/language"
+	"analyzer/models"
+)
+
+// grammar holds the tables the machine looks up the lexemes of a language
+// in.
+type grammar struct {
+	lang      *models.Language
+	words     map[string]models.TokenType // keywords and literals
+	operators map[string]bool
+	prefixes  map[string]bool // all prefixes of operators
+	longest   int             // length of the longest operator
+	quotes    map[rune]models.Quote
+}
+
+// grammars caches the grammar of each language.
+var grammars sync.Map
+
+func grammarOf(opts models.Options) *grammar {
+	lang := language.Of(opts)
+	if g, ok := grammars.Load(lang); ok {
+		return g.(*grammar)
+	}
+
+	g := &grammar{
+		lang:      lang,
+		words:     make(map[string]models.TokenType),
+		operators: make(map[string]bool),
+		prefixes:  make(map[string]bool),
+		quotes:    make(map[rune]models.Quote),
+	}
+	for _, word := range lang.Keywords {
+		g.words[word] = models.Keyword
+	}
+	for word, typ := range lang.Literals {
+		g.words[word] = typ
+	}
+	for _, op := range lang.Operators {
+		g.operators[op] = true
+		g.longest = max(g.longest, len(op))
+		for i := 1; i <= len(op); i++ {
+			g.prefixes[op[:i]] = true
+		}
+	}
+	for _, q := range lang.Quotes {
+		g.quotes[rune(q.Delim)] = q
+	}
+	actual, _ := grammars.LoadOrStore(lang, g)
+	return actual.(*grammar)
+}
+
+func (g *grammar) isWhitespace(ch rune) bool {
+	return ch == '\n' || ch != 0 && strings.ContainsRune(g.lang.Whitespace, ch)
+}
+
+func (g *grammar) isSeparator(ch rune) bool {
+	return ch != 0 && strings.ContainsRune(g.lang.Separators, ch)
+}
+
+func (g *grammar) isLetter(ch rune) bool {
+	if g.lang.Identifiers == models.UnicodeIdentifiers {
+		return unicode.IsLetter(ch) || ch == '_'
+	}
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
+
+func (g *grammar) isIdentifierPart(ch rune) bool {
+	if g.lang.Identifiers == models.UnicodeIdentifiers {
+		return unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == '_'
+	}
+	return g.isLetter(ch) || isDecimal(ch)
+}
@@ -1,12 +1,17 @@
 package fsmlex
 
 import (
+	"fmt"
 	"strings"
-	"unicode"
+	"unicode/utf8"
+	"unsafe"
 
+	"analyzer/literal"
 	"analyzer/models"
 )
 
+const bom = 0xFEFF
+
 type State int
 
 const (
@@ -17,338 +22,738 @@ const (
 	InOctalNumber
 	InBinaryNumber
 	InFloat
+	InHexFloat
 	InExponent
 	InExponentDigits
+	InPPNumber
 	InString
 	InRawString
 	InRune
 	InOperator
 	InLineComment
 	InBlockComment
+	InWhitespace
 )
 
-var keywords = map[string]bool{
-	"break": true, "case": true, "chan": true, "const": true, "continue": true,
-	"default": true, "defer": true, "else": true, "fallthrough": true,
-	"for": true, "func": true, "go": true, "goto": true, "if": true,
-	"import": true, "interface": true, "map": true, "package": true,
-	"range": true, "return": true, "select": true, "struct": true,
-	"switch": true, "type": true, "var": true,
+// machine holds the lexer state between steps. It works on a window of the
+// input that can be extended by feed, so tokens may span several chunks.
+type machine struct {
+	src      string
+	buf      []byte // backing array of src when the input is fed
+	base     int    // offset of src[0] in the whole input
+	pos      int
+	start    int // start of the current lexeme in src
+	line     int
+	col      int
+	startPos models.Position
+	state    State
+	strDelim rune
+	opts     models.Options
+	g        *grammar
+
+	// insertSemi is set when a line break after the last token would
+	// terminate the statement. A line break inside a block comment does
+	// it as well, but the semicolon is held back until the comment ends.
+	insertSemi  bool
+	pendingSemi bool
+	semiPos     models.Position
+
+	// invalid is set when the current lexeme is malformed, such as a
+	// literal with illegal characters or a bad escape
+	invalid bool
+
+	// diags grows with the problems in the input, taken counts those
+	// handed out by Lexer.TakeDiagnostics for Options.MaxErrors
+	diags []models.Diagnostic
+	taken int
+	done  bool // set when Options.MaxErrors is reached
 }
 
-var operators = map[string]bool{
-	"+": true, "-": true, "*": true, "/": true, "%": true,
-	"&": true, "|": true, "^": true, "<<": true, ">>": true,
-	"&^": true, "+=": true, "-=": true, "*=": true, "/=": true,
-	"%=": true, "&=": true, "|=": true, "^=": true, "<<=": true,
-	">>=": true, "&^=": true, "&&": true, "||": true, "<-": true,
-	"++": true, "--": true, "==": true, "<": true, ">": true,
-	"=": true, "!": true, "!=": true, "<=": true, ">=": true,
-	":=": true, "...": true,
+func newMachine(src string, opts models.Options) *machine {
+	m := &machine{src: src, line: 1, col: 1}
+	m.setOptions(opts)
+	return m
 }
 
-var separators = map[rune]bool{
-	'(': true, ')': true, '[': true, ']': true, '{': true,
-	'}': true, ',': true, ';': true, ':': true, '.': true,
+func (m *machine) setOptions(opts models.Options) {
+	m.g = grammarOf(opts)
+	if !m.g.lang.Semicolons {
+		opts.Semicolons = false
+	}
+	m.opts = opts
+}
+
+func init() {
+	models.RegisterLexer("fsm", models.LexerFunc(LexOptions))
 }
 
 func Lex(input string) []models.Token {
+	tokens, _ := LexOptions(input, models.Options{})
+	return tokens
+}
+
+// LexOptions lexes the whole input. Problems are reported as diagnostics,
+// the offending text becomes an Error token and lexing goes on after it.
+// It is safe for concurrent use.
+func LexOptions(input string, opts models.Options) ([]models.Token, []models.Diagnostic) {
 	var tokens []models.Token
-	state := Start
-	var buffer strings.Builder
-	var strDelim rune
-	pos := 0
+	m := newMachine(input, opts)
+
+	for {
+		token, ok := m.next(true)
+		if !ok {
+			return tokens, m.diags
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// LexSpans lexes the whole input like LexOptions and appends the tokens to
+// dst as spans, which point into src. With a dst that has room for them,
+// lexing allocates nothing per token. Options.Decode is ignored, spans have
+// no values.
+func LexSpans(src []byte, opts models.Options, dst []models.Span) ([]models.Span, []models.Diagnostic) {
+	opts.Decode = false
+	m := newMachine(string(src), opts)
+	for {
+		token, ok := m.next(true)
+		if !ok {
+			return dst, m.diags
+		}
+		dst = append(dst, token.Span())
+	}
+}
 
-	for pos < len(input) {
-		ch := rune(input[pos])
+// next runs the machine until it produces a token. It returns false when the
+// window is exhausted, which means end of input if atEOF is set and a need
+// for more text otherwise.
+func (m *machine) next(atEOF bool) (models.Token, bool) {
+	for m.pos < len(m.src) && !m.done {
+		if m.state == Start && m.opts.MaxErrors > 0 && m.taken+len(m.diags) >= m.opts.MaxErrors {
+			m.diags = append(m.diags, models.TooManyErrors(m.position()))
+			m.done = true
+			return models.Token{}, false
+		}
+
+		if m.pendingSemi && m.state == Start {
+			return m.semicolon(), true
+		}
 
-		switch state {
+		if !utf8.FullRuneInString(m.src[m.pos:]) && !atEOF {
+			return models.Token{}, false
+		}
+		ch, size := utf8.DecodeRuneInString(m.src[m.pos:])
+		bad := m.illegal(ch, size, m.pos)
+
+		// Some transitions look one byte ahead, which may not be read yet
+		needMore := m.pos+1 >= len(m.src) && !atEOF
+		hasNext := m.pos+1 < len(m.src)
+
+		// Literals and comments swallow illegal characters, the whole
+		// lexeme is reported as an error then
+		switch m.state {
+		case InString, InRune, InRawString, InLineComment, InBlockComment:
+			if bad {
+				m.badEncoding(ch, m.pos, size)
+			}
+		}
+
+		switch m.state {
 		case Start:
-			if unicode.IsSpace(ch) {
-				pos++
+			if ch == '\n' && m.insertSemi && m.opts.Semicolons {
+				return m.semicolon(), true
+			}
+
+			if ch == bom && m.base+m.pos == 0 {
+				// Byte order mark is allowed only at the start of the file
+				m.begin(Start)
+				m.advance(size)
+				if m.opts.Trivia {
+					return m.emit(models.Whitespace, m.lexeme()), true
+				}
 				continue
 			}
 
-			if ch == '/' && pos+1 < len(input) {
-				next := input[pos+1]
-				if next == '/' {
-					state = InLineComment
-					pos += 2
+			if bad {
+				m.begin(Start)
+				m.badEncoding(ch, m.pos, size)
+				m.advance(size)
+				return m.emit(models.Error, m.lexeme()), true
+			}
+
+			if m.g.isWhitespace(ch) {
+				if !m.opts.Trivia {
+					m.advance(size)
 					continue
 				}
-				if next == '*' {
-					state = InBlockComment
-					pos += 2
+				if ch == '\n' {
+					m.begin(Start)
+					m.advance(size)
+					return m.emit(models.Newline, m.lexeme()), true
+				}
+				m.begin(InWhitespace)
+				m.advance(size)
+				continue
+			}
+
+			if ch == '\\' && m.g.lang.Splice {
+				// A backslash before a newline joins the lines
+				crlf, more := m.at("\\\r\n", atEOF)
+				lf, moreLF := m.at("\\\n", atEOF)
+				if more || moreLF {
+					return models.Token{}, false
+				}
+				if crlf || lf {
+					m.begin(Start)
+					m.advance(2)
+					if crlf {
+						m.advance(1)
+					}
+					if m.opts.Trivia {
+						return m.emit(models.Whitespace, m.lexeme()), true
+					}
 					continue
 				}
 			}
 
-			if isOperatorStart(ch) {
-				state = InOperator
-				buffer.WriteRune(ch)
-				pos++
+			if ok, more := m.at(m.g.lang.LineComment, atEOF); more {
+				return models.Token{}, false
+			} else if ok {
+				m.begin(InLineComment)
+				m.advance(len(m.g.lang.LineComment))
 				continue
 			}
-
-			if separators[ch] {
-				tokens = append(tokens, models.Token{Type: models.Separator, Value: string(ch)})
-				pos++
+			if ok, more := m.at(m.g.lang.BlockComment[0], atEOF); more {
+				return models.Token{}, false
+			} else if ok {
+				m.begin(InBlockComment)
+				m.advance(len(m.g.lang.BlockComment[0]))
 				continue
 			}
 
-			if ch == '`' {
-				state = InRawString
-				pos++
+			// Numbers may start with a dot, or a minus sign in JSON
+			json := m.g.lang.Numbers == models.JSONNumbers
+			if ch == '.' && !json || ch == '-' && json {
+				if needMore {
+					return models.Token{}, false
+				}
+				if hasNext && isDecimal(rune(m.src[m.pos+1])) {
+					m.begin(InPPNumber)
+					if m.g.lang.Numbers == models.GoNumbers {
+						m.state = InFloat
+					}
+					m.advance(size)
+					continue
+				}
+			}
+
+			if m.g.prefixes[m.src[m.pos:m.pos+size]] {
+				m.begin(InOperator)
+				m.advance(size)
 				continue
 			}
 
-			if ch == '"' || ch == '\'' {
-				state = InString
-				strDelim = ch
-				if ch == '\'' {
-					state = InRune
+			if m.g.isSeparator(ch) {
+				m.begin(Start)
+				m.advance(size)
+				return m.emit(models.Separator, m.lexeme()), true
+			}
+
+			if q, ok := m.g.quotes[ch]; ok {
+				m.begin(InString)
+				m.strDelim = ch
+				switch {
+				case q.Raw:
+					m.state = InRawString
+				case q.Type == models.RuneLiteral:
+					m.state = InRune
 				}
-				pos++
+				m.advance(size)
 				continue
 			}
 
-			if unicode.IsDigit(ch) {
-				state = InNumber
-				buffer.WriteRune(ch)
-				pos++
+			if isDecimal(ch) {
+				m.begin(InPPNumber)
+				if m.g.lang.Numbers == models.GoNumbers {
+					m.state = InNumber
+				}
+				m.advance(size)
 				continue
 			}
 
-			if unicode.IsLetter(ch) || ch == '_' {
-				state = InIdentifier
-				buffer.WriteRune(ch)
-				pos++
+			if m.g.isLetter(ch) {
+				m.begin(InIdentifier)
+				m.advance(size)
 				continue
 			}
 
-			tokens = append(tokens, models.Token{Type: models.Error, Value: string(ch)})
-			pos++
+			m.begin(Start)
+			m.advance(size)
+			return m.fail(models.ErrIllegalCharacter, fmt.Sprintf("illegal character %#U", ch), ""), true
 
 		case InIdentifier:
-			if unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == '_' {
-				buffer.WriteRune(ch)
-				pos++
+			if m.g.isIdentifierPart(ch) {
+				m.advance(size)
 			} else {
-				value := buffer.String()
-				if keywords[value] {
-					tokens = append(tokens, models.Token{Type: models.Keyword, Value: value})
-				} else if value == "true" || value == "false" {
-					tokens = append(tokens, models.Token{Type: models.BooleanLiteral, Value: value})
-				} else {
-					tokens = append(tokens, models.Token{Type: models.Identifier, Value: value})
-				}
-				buffer.Reset()
-				state = Start
+				return m.identifier(), true
 			}
 
 		case InNumber:
 			switch {
-			case ch == '_':
-				buffer.WriteRune(ch)
-				pos++
-			case ch == '.':
-				buffer.WriteRune(ch)
-				state = InFloat
-				pos++
-			case ch == 'e' || ch == 'E':
-				buffer.WriteRune(ch)
-				state = InExponent
-				pos++
-			case ch == 'x' || ch == 'X':
-				buffer.WriteRune(ch)
-				state = InHexNumber
-				pos++
-			case ch == 'o' || ch == 'O':
-				buffer.WriteRune(ch)
-				state = InOctalNumber
-				pos++
-			case ch == 'b' || ch == 'B':
-				buffer.WriteRune(ch)
-				state = InBinaryNumber
-				pos++
-			case unicode.IsDigit(ch):
-				buffer.WriteRune(ch)
-				pos++
+			case ch == '_' || isDecimal(ch):
+				m.advance(size)
+			case m.lexeme() == "0" && (ch == 'x' || ch == 'X'):
+				m.state = InHexNumber
+				m.advance(size)
+			case m.lexeme() == "0" && (ch == 'o' || ch == 'O'):
+				m.state = InOctalNumber
+				m.advance(size)
+			case m.lexeme() == "0" && (ch == 'b' || ch == 'B'):
+				m.state = InBinaryNumber
+				m.advance(size)
 			default:
-				tokens = append(tokens, models.Token{Type: models.IntLiteral, Value: buffer.String()})
-				buffer.Reset()
-				state = Start
+				if token, ok := m.fraction(ch, size); ok {
+					return token, true
+				}
 			}
 
-		case InHexNumber:
-			if strings.ContainsRune("0123456789abcdefABCDEF_", ch) {
-				buffer.WriteRune(ch)
-				pos++
-			} else {
-				tokens = append(tokens, models.Token{Type: models.IntLiteral, Value: buffer.String()})
-				buffer.Reset()
-				state = Start
+		case InOctalNumber, InBinaryNumber:
+			// Digits out of range are part of the literal and reported later
+			if ch == '_' || isDecimal(ch) {
+				m.advance(size)
+			} else if token, ok := m.fraction(ch, size); ok {
+				return token, true
 			}
 
-		case InOctalNumber:
-			if strings.ContainsRune("01234567_", ch) {
-				buffer.WriteRune(ch)
-				pos++
-			} else {
-				tokens = append(tokens, models.Token{Type: models.IntLiteral, Value: buffer.String()})
-				buffer.Reset()
-				state = Start
+		case InHexNumber:
+			if ch == '_' || isHex(ch) {
+				m.advance(size)
+			} else if ch == '.' {
+				m.state = InHexFloat
+				m.advance(size)
+			} else if token, ok := m.exponent(ch, size); ok {
+				return token, true
 			}
 
-		case InBinaryNumber:
-			if strings.ContainsRune("01_", ch) {
-				buffer.WriteRune(ch)
-				pos++
-			} else {
-				tokens = append(tokens, models.Token{Type: models.IntLiteral, Value: buffer.String()})
-				buffer.Reset()
-				state = Start
+		case InFloat:
+			if ch == '_' || isDecimal(ch) {
+				m.advance(size)
+			} else if token, ok := m.exponent(ch, size); ok {
+				return token, true
 			}
 
-		case InFloat:
-			if ch == '_' || unicode.IsDigit(ch) {
-				buffer.WriteRune(ch)
-				pos++
-			} else if ch == 'e' || ch == 'E' {
-				buffer.WriteRune(ch)
-				state = InExponent
-				pos++
-			} else {
-				tokens = append(tokens, models.Token{Type: models.FloatLiteral, Value: buffer.String()})
-				buffer.Reset()
-				state = Start
+		case InHexFloat:
+			if ch == '_' || isHex(ch) {
+				m.advance(size)
+			} else if token, ok := m.exponent(ch, size); ok {
+				return token, true
 			}
 
 		case InExponent:
-			if ch == '+' || ch == '-' {
-				buffer.WriteRune(ch)
-				state = InExponentDigits
-				pos++
-			} else if unicode.IsDigit(ch) {
-				buffer.WriteRune(ch)
-				state = InExponentDigits
-				pos++
-			} else {
-				tokens = append(tokens, models.Token{Type: models.Error, Value: buffer.String() + string(ch)})
-				buffer.Reset()
-				state = Start
-				pos++
+			if ch == '+' || ch == '-' || ch == '_' || isDecimal(ch) {
+				m.state = InExponentDigits
+				m.advance(size)
+			} else if token, ok := m.imaginary(ch, size); ok {
+				return token, true
 			}
 
 		case InExponentDigits:
-			if unicode.IsDigit(ch) || ch == '_' {
-				buffer.WriteRune(ch)
-				pos++
-			} else {
-				tokens = append(tokens, models.Token{Type: models.FloatLiteral, Value: buffer.String()})
-				buffer.Reset()
-				state = Start
-			}
-
-		case InString:
-			if ch == strDelim {
-				tokens = append(tokens, models.Token{Type: models.StringLiteral, Value: buffer.String()})
-				buffer.Reset()
-				state = Start
-				pos++
-			} else if ch == '\\' {
-				if pos+1 < len(input) {
-					buffer.WriteRune(ch)
-					buffer.WriteRune(rune(input[pos+1]))
-					pos += 2
-				} else {
-					tokens = append(tokens, models.Token{Type: models.Error, Value: "unterminated escape"})
-					pos++
-				}
-			} else {
-				buffer.WriteRune(ch)
-				pos++
+			if ch == '_' || isDecimal(ch) {
+				m.advance(size)
+			} else if token, ok := m.imaginary(ch, size); ok {
+				return token, true
 			}
 
-		case InRawString:
-			if ch == '`' {
-				tokens = append(tokens, models.Token{Type: models.StringLiteral, Value: buffer.String()})
-				buffer.Reset()
-				state = Start
-				pos++
+		case InPPNumber:
+			// Like a C preprocessor number, checked afterwards
+			exp := lower(m.src[m.pos-1])
+			if ch == '_' || ch == '.' || isDecimal(ch) || 'a' <= lower(byte(ch)) && lower(byte(ch)) <= 'z' && ch < utf8.RuneSelf ||
+				(ch == '+' || ch == '-') && (exp == 'e' || exp == 'p' && m.g.lang.Numbers == models.CNumbers) {
+				m.advance(size)
 			} else {
-				buffer.WriteRune(ch)
-				pos++
+				return m.number(), true
 			}
 
-		case InRune:
-			if ch == strDelim {
-				if buffer.Len() > 0 {
-					tokens = append(tokens, models.Token{Type: models.RuneLiteral, Value: buffer.String()})
-				} else {
-					tokens = append(tokens, models.Token{Type: models.Error, Value: "empty rune"})
+		case InString, InRune:
+			if ch == m.strDelim {
+				m.advance(size)
+				if m.state == InString {
+					return m.quoted(models.StringLiteral), true
 				}
-				buffer.Reset()
-				state = Start
-				pos++
+				return m.quoted(models.RuneLiteral), true
+			} else if ch == '\n' {
+				return m.unterminated(), true
 			} else if ch == '\\' {
-				if pos+1 < len(input) {
-					buffer.WriteRune(ch)
-					buffer.WriteRune(rune(input[pos+1]))
-					pos += 2
-				} else {
-					tokens = append(tokens, models.Token{Type: models.Error, Value: "unterminated escape"})
-					pos++
+				if needMore {
+					return models.Token{}, false
+				}
+				if !hasNext || m.src[m.pos+1] == '\n' {
+					m.advance(size)
+					return m.unterminated(), true
+				}
+				escaped := m.src[m.pos+1:]
+				if !utf8.FullRuneInString(escaped) && !atEOF {
+					return models.Token{}, false
 				}
+				r, n := utf8.DecodeRuneInString(escaped)
+				if m.illegal(r, n, m.pos+1) {
+					m.badEncoding(r, m.pos+1, n)
+				}
+				m.advance(size + n)
 			} else {
-				buffer.WriteRune(ch)
-				pos++
+				m.advance(size)
+			}
+
+		case InRawString:
+			m.advance(size)
+			if ch == m.strDelim {
+				return m.quoted(models.StringLiteral), true
 			}
 
 		case InOperator:
-			possibleOp := buffer.String() + string(ch)
-			if operators[possibleOp] {
-				buffer.WriteRune(ch)
-				pos++
+			if m.g.prefixes[m.src[m.start:m.pos+size]] {
+				m.advance(size)
 			} else {
-				if operators[buffer.String()] {
-					tokens = append(tokens, models.Token{Type: models.Operator, Value: buffer.String()})
-					buffer.Reset()
-					state = Start
-				} else {
-					tokens = append(tokens, models.Token{Type: models.Error, Value: buffer.String()})
-					buffer.Reset()
-					state = Start
-					pos++
-				}
+				return m.operator(), true
 			}
 
 		case InLineComment:
-			if ch == '\n' {
-				state = Start
+			if ch != '\n' {
+				m.advance(size)
+			} else if m.opts.Trivia || m.invalid {
+				return m.emit(models.Comment, m.lexeme()), true
+			} else {
+				m.state = Start
 			}
-			pos++
 
 		case InBlockComment:
-			if ch == '*' && pos+1 < len(input) && input[pos+1] == '/' {
-				pos += 2
-				state = Start
+			end := m.g.lang.BlockComment[1]
+			ok, more := m.at(end, atEOF)
+			if more {
+				return models.Token{}, false
+			}
+			if ok {
+				m.advance(len(end))
+				if m.opts.Trivia || m.invalid {
+					return m.emit(models.Comment, m.lexeme()), true
+				}
+				m.state = Start
+				continue
+			}
+			if ch == '\n' && m.insertSemi && m.opts.Semicolons && !m.pendingSemi {
+				m.pendingSemi = true
+				m.semiPos = m.position()
+			}
+			m.advance(size)
+
+		case InWhitespace:
+			if ch != '\n' && m.g.isWhitespace(ch) {
+				m.advance(size)
 			} else {
-				pos++
+				return m.emit(models.Whitespace, m.lexeme()), true
 			}
 
 		default:
-			pos++
+			m.advance(size)
 		}
 	}
 
-	return tokens
+	if atEOF && !m.done {
+		return m.flush()
+	}
+	return models.Token{}, false
+}
+
+// flush finishes the lexeme that is still open at the end of the input.
+func (m *machine) flush() (models.Token, bool) {
+	switch m.state {
+	case InIdentifier:
+		return m.identifier(), true
+	case InNumber, InHexNumber, InOctalNumber, InBinaryNumber, InFloat, InHexFloat, InExponent, InExponentDigits, InPPNumber:
+		return m.number(), true
+	case InString, InRawString, InRune, InBlockComment:
+		return m.unterminated(), true
+	case InOperator:
+		return m.operator(), true
+	case InLineComment:
+		if m.opts.Trivia || m.invalid {
+			return m.emit(models.Comment, m.lexeme()), true
+		}
+	case InWhitespace:
+		return m.emit(models.Whitespace, m.lexeme()), true
+	}
+
+	m.state = Start
+	if m.pendingSemi || m.insertSemi && m.opts.Semicolons {
+		return m.semicolon(), true
+	}
+	return models.Token{}, false
+}
+
+// feed appends text to the window, dropping everything before the lexeme
+// that is currently being read. The window grows like a slice, so a lexeme
+// that spans many chunks is copied a constant number of times on average.
+func (m *machine) feed(text []byte) {
+	// Line comments are kept whole as well, an illegal character turns
+	// them into an Error token
+	keep := m.start
+	if m.state == Start {
+		keep = m.pos
+	}
+
+	// append writes only behind the bytes src has shown so far, so the
+	// strings taken from the window never change
+	m.buf = append(m.buf[keep:], text...)
+	m.src = unsafe.String(unsafe.SliceData(m.buf), len(m.buf))
+	m.base += keep
+	m.pos -= keep
+	m.start = max(m.start-keep, 0)
+}
+
+// begin marks the current position as the start of a lexeme.
+func (m *machine) begin(state State) {
+	m.state = state
+	m.start = m.pos
+	m.startPos = m.position()
 }
 
-func isOperatorStart(ch rune) bool {
+// emit finishes the current lexeme with a token that ends at the current
+// position and returns the machine to Start. A malformed lexeme becomes an
+// Error token, which ends a line like a token of typ would, as in
+// go/scanner. Illegal characters leave the line as it was.
+func (m *machine) emit(typ models.TokenType, value string) models.Token {
+	m.state = Start
+	if typ != models.Error && !typ.IsTrivia() {
+		m.insertSemi = models.InsertsSemicolon(models.Token{Type: typ, Value: value})
+	}
+	if m.invalid {
+		typ = models.Error
+		m.invalid = false
+	}
+	token := models.Token{Type: typ, Value: value, Pos: m.startPos, End: m.position()}
+	if m.opts.Decode && typ != models.Error {
+		token.Decoded = literal.DecodeIn(m.g.lang, typ, value)
+		if err := literal.Undecoded(typ, value); err != nil {
+			m.diags = append(m.diags, err.Diagnostic(token.Pos, token.End))
+		}
+	}
+	if m.opts.OmitPositions {
+		token.Pos, token.End = models.Position{}, models.Position{}
+	}
+	return token
+}
+
+// fail finishes the current lexeme as an Error token and reports it.
+func (m *machine) fail(code models.Code, msg, hint string) models.Token {
+	m.report(code, m.startPos, m.position(), msg, hint)
+	return m.emit(models.Error, m.lexeme())
+}
+
+func (m *machine) report(code models.Code, pos, end models.Position, msg, hint string) {
+	m.diags = append(m.diags, models.Diagnostic{
+		Severity: models.SeverityError,
+		Code:     code,
+		Pos:      pos,
+		End:      end,
+		Message:  msg,
+		Hint:     hint,
+	})
+}
+
+// badEncoding reports the illegal character of size bytes at offset i of
+// the window, which must not be behind a line break from the current
+// position. The current lexeme becomes an error.
+func (m *machine) badEncoding(ch rune, i, size int) {
+	pos := m.position()
+	pos.Offset += i - m.pos
+	pos.Column += i - m.pos
+	end := pos
+	end.Offset += size
+	end.Column += size
 	switch ch {
-	case '+', '-', '*', '/', '%', '&', '|', '^', '<', '>', '!', '=', ':', '~':
-		return true
+	case bom:
+		m.report(models.ErrInvalidEncoding, pos, end, "illegal byte order mark", "remove the byte order mark")
+	case 0:
+		m.report(models.ErrIllegalCharacter, pos, end, "illegal character NUL", "")
+	default:
+		m.report(models.ErrInvalidEncoding, pos, end, "invalid UTF-8 encoding", "")
+	}
+	m.invalid = true
+}
+
+// unterminated finishes a literal or comment that lacks its end.
+func (m *machine) unterminated() models.Token {
+	switch m.state {
+	case InBlockComment:
+		return m.fail(models.ErrUnterminated, "comment not terminated", "add "+m.g.lang.BlockComment[1])
+	case InRawString:
+		m.report(models.ErrUnterminated, m.startPos, m.position(), "raw string literal not terminated", fmt.Sprintf("add a closing %c", m.strDelim))
+	default:
+		for _, err := range literal.UnterminatedIn(m.g.lang, m.lexeme()) {
+			m.diags = append(m.diags, err.Diagnostic(m.startPos, m.position()))
+		}
+	}
+	m.invalid = true
+	if m.state == InRune {
+		return m.emit(models.RuneLiteral, m.lexeme())
+	}
+	return m.emit(models.StringLiteral, m.lexeme())
+}
+
+// operator finishes the longest operator in the lexeme read so far, which
+// is a prefix of operators. The lexeme may also end with a separator, such
+// as a colon that didn't become := or the dot of "..". The rest is read
+// again.
+func (m *machine) operator() models.Token {
+	value := m.lexeme()
+	for n := len(value); n > 0; n-- {
+		switch {
+		case m.g.operators[value[:n]]:
+			m.retreat(len(value) - n)
+			return m.emit(models.Operator, m.lexeme())
+		case n == 1 && m.g.isSeparator(rune(value[0])):
+			m.retreat(len(value) - n)
+			return m.emit(models.Separator, m.lexeme())
+		}
 	}
-	return false
+	_, size := utf8.DecodeRuneInString(value)
+	m.retreat(len(value) - size)
+	return m.fail(models.ErrIllegalOperator, fmt.Sprintf("invalid operator %q", m.lexeme()), "")
+}
+
+func (m *machine) semicolon() models.Token {
+	pos := m.position()
+	if m.pendingSemi {
+		pos = m.semiPos
+	}
+	m.insertSemi = false
+	m.pendingSemi = false
+	if m.opts.OmitPositions {
+		pos = models.Position{}
+	}
+	return models.Semicolon(pos)
+}
+
+// The number states share their tails: a fraction may be followed by an
+// exponent, and any number may end with the imaginary suffix. Each helper
+// moves to the matching state or finishes the number if ch continues
+// nothing.
+
+func (m *machine) fraction(ch rune, size int) (models.Token, bool) {
+	if ch != '.' {
+		return m.exponent(ch, size)
+	}
+	m.state = InFloat
+	m.advance(size)
+	return models.Token{}, false
+}
+
+func (m *machine) exponent(ch rune, size int) (models.Token, bool) {
+	if ch != 'e' && ch != 'E' && ch != 'p' && ch != 'P' {
+		return m.imaginary(ch, size)
+	}
+	m.state = InExponent
+	m.advance(size)
+	return models.Token{}, false
+}
+
+func (m *machine) imaginary(ch rune, size int) (models.Token, bool) {
+	if ch == 'i' {
+		m.advance(size)
+	}
+	return m.number(), true
+}
+
+// number finishes a numeric literal, which is checked against the spec.
+func (m *machine) number() models.Token {
+	typ, err := literal.NumberIn(m.g.lang, m.lexeme())
+	if err != nil {
+		m.invalid = true
+		m.diags = append(m.diags, err.Diagnostic(m.startPos, m.position()))
+	}
+	return m.emit(typ, m.lexeme())
+}
+
+// quoted finishes a string or rune literal, which is checked against the
+// spec.
+func (m *machine) quoted(typ models.TokenType) models.Token {
+	if err := literal.QuotedIn(m.g.lang, m.lexeme()); err != nil {
+		m.invalid = true
+		m.diags = append(m.diags, err.Diagnostic(m.startPos, m.position()))
+	}
+	return m.emit(typ, m.lexeme())
+}
+
+// identifier finishes a word, which is a keyword, a literal such as true, a
+// predeclared identifier if enabled or an identifier.
+func (m *machine) identifier() models.Token {
+	value := m.lexeme()
+	if typ, ok := m.g.words[value]; ok {
+		return m.emit(typ, value)
+	}
+	if typ, ok := m.g.lang.Predeclared[value]; ok && m.opts.Predeclared {
+		return m.emit(typ, value)
+	}
+	if m.g.lang.Identifiers == models.NoIdentifiers {
+		return m.fail(models.ErrIllegalCharacter, fmt.Sprintf("unexpected word %q", value), "")
+	}
+	return m.emit(models.Identifier, value)
+}
+
+func (m *machine) lexeme() string {
+	return m.src[m.start:m.pos]
+}
+
+func (m *machine) position() models.Position {
+	return models.Position{Offset: m.base + m.pos, Line: m.line, Column: m.col}
+}
+
+// at reports whether the window continues with s at the current position,
+// which is never the case for an empty s. more is set if the window ends
+// before that is known.
+func (m *machine) at(s string, atEOF bool) (ok, more bool) {
+	rest := m.src[m.pos:]
+	if s == "" {
+		return false, false
+	}
+	if len(rest) < len(s) {
+		return false, !atEOF && strings.HasPrefix(s, rest)
+	}
+	return strings.HasPrefix(rest, s), false
+}
+
+// retreat moves back by n bytes within a lexeme without line breaks.
+func (m *machine) retreat(n int) {
+	m.pos -= n
+	m.col -= n
+}
+
+func (m *machine) advance(n int) {
+	for ; n > 0; n-- {
+		if m.src[m.pos] == '\n' {
+			m.line++
+			m.col = 1
+		} else {
+			m.col++
+		}
+		m.pos++
+	}
+}
+
+// illegal reports whether the rune decoded at offset i of the window is
+// invalid UTF-8, a NUL byte or a byte order mark in the middle of the file.
+func (m *machine) illegal(ch rune, size int, i int) bool {
+	return ch == utf8.RuneError && size == 1 || ch == 0 || ch == bom && m.base+i > 0
+}
+
+func isDecimal(ch rune) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func isHex(ch rune) bool {
+	return isDecimal(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func lower(ch byte) byte {
+	return ('a' - 'A') | ch
 }
@@ -0,0 +1,83 @@
+package fsmlex
+
+import (
+	"reflect"
+	"testing"
+
+	"analyzer/models"
+)
+
+func TestLexPositions(t *testing.T) {
+	input := "x := \"hi\"\n\tf(1.5)\n"
+	tests := []struct {
+		typ   models.TokenType
+		value string
+		pos   models.Position
+		end   models.Position
+	}{
+		{models.Identifier, "x", models.Position{Offset: 0, Line: 1, Column: 1}, models.Position{Offset: 1, Line: 1, Column: 2}},
+		{models.Operator, ":=", models.Position{Offset: 2, Line: 1, Column: 3}, models.Position{Offset: 4, Line: 1, Column: 5}},
+		{models.StringLiteral, `"hi"`, models.Position{Offset: 5, Line: 1, Column: 6}, models.Position{Offset: 9, Line: 1, Column: 10}},
+		{models.Identifier, "f", models.Position{Offset: 11, Line: 2, Column: 2}, models.Position{Offset: 12, Line: 2, Column: 3}},
+		{models.Separator, "(", models.Position{Offset: 12, Line: 2, Column: 3}, models.Position{Offset: 13, Line: 2, Column: 4}},
+		{models.FloatLiteral, "1.5", models.Position{Offset: 13, Line: 2, Column: 4}, models.Position{Offset: 16, Line: 2, Column: 7}},
+		{models.Separator, ")", models.Position{Offset: 16, Line: 2, Column: 7}, models.Position{Offset: 17, Line: 2, Column: 8}},
+	}
+
+	tokens := Lex(input)
+	if len(tokens) != len(tests) {
+		t.Fatalf("Lex(%q) returned %d tokens; want %d: %v", input, len(tokens), len(tests), tokens)
+	}
+
+	for i, tt := range tests {
+		tok := tokens[i]
+		if tok.Type != tt.typ || tok.Value != tt.value || tok.Pos != tt.pos || tok.End != tt.end {
+			t.Errorf("token %d = %+v; want {%s %q %+v %+v}", i, tok, tt.typ, tt.value, tt.pos, tt.end)
+		}
+	}
+}
+
+// TestLexOmitPositions checks that OmitPositions changes nothing but the
+// positions of the tokens.
+func TestLexOmitPositions(t *testing.T) {
+	input := "x := 1e10001\ny := 08 // c\nz"
+	opts := models.Options{Semicolons: true, Trivia: true, Decode: true}
+	want, wantDiags := LexOptions(input, opts)
+	opts.OmitPositions = true
+	got, diags := LexOptions(input, opts)
+
+	for i := range want {
+		want[i].Pos, want[i].End = models.Position{}, models.Position{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens without positions\n%+v\nwant\n%+v", got, want)
+	}
+	if !reflect.DeepEqual(diags, wantDiags) {
+		t.Errorf("diagnostics without positions %v; want %v", diags, wantDiags)
+	}
+}
+
+func TestLexUnterminatedAtEOF(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []models.TokenType
+	}{
+		{"x := 1", []models.TokenType{models.Identifier, models.Operator, models.IntLiteral}},
+		{"a /* b", []models.TokenType{models.Identifier, models.Error}},
+		{`s = "abc`, []models.TokenType{models.Identifier, models.Operator, models.Error}},
+		{"x // done", []models.TokenType{models.Identifier}},
+	}
+
+	for _, tt := range tests {
+		tokens := Lex(tt.input)
+		if len(tokens) != len(tt.want) {
+			t.Errorf("Lex(%q) = %v; want types %v", tt.input, tokens, tt.want)
+			continue
+		}
+		for i, typ := range tt.want {
+			if tokens[i].Type != typ {
+				t.Errorf("Lex(%q)[%d].Type = %s; want %s", tt.input, i, tokens[i].Type, typ)
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package fsmlex
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"analyzer/models"
+)
+
+// Edit replaces Deleted bytes at Offset of an input by Inserted.
+type Edit struct {
+	Offset   int
+	Deleted  int
+	Inserted string
+}
+
+// Apply returns the input after the edit.
+func (e Edit) Apply(input string) string {
+	return input[:e.Offset] + e.Inserted + input[e.Offset+e.Deleted:]
+}
+
+// Relex lexes an input again after an edit. tokens and diags are what
+// LexOptions returned for the input before the edit, with the same options,
+// and input is the text after it. Relex starts again at the last token the
+// edit can't have changed and stops as soon as it reads a token that is
+// also in the old list and leaves the machine in the same state, the tokens
+// after it are moved by the edit. The result is the same as that of
+// LexOptions(input, opts).
+//
+// An edit that doesn't fit the input, a limit on the errors, which
+// depends on all of them, or tokens without positions make Relex lex the
+// whole input.
+func Relex(input string, tokens []models.Token, diags []models.Diagnostic, e Edit, opts models.Options) ([]models.Token, []models.Diagnostic) {
+	tokens, diags, _ = relex(input, tokens, diags, e, opts)
+	return tokens, diags
+}
+
+// relex is Relex that also returns the number of tokens it lexed.
+func relex(input string, tokens []models.Token, diags []models.Diagnostic, e Edit, opts models.Options) ([]models.Token, []models.Diagnostic, int) {
+	delta := len(e.Inserted) - e.Deleted
+	if e.Offset < 0 || e.Deleted < 0 || e.Offset+len(e.Inserted) > len(input) || opts.MaxErrors > 0 || opts.OmitPositions {
+		tokens, diags := LexOptions(input, opts)
+		return tokens, diags, len(tokens)
+	}
+
+	m := newMachine(input, opts)
+	k := restart(tokens, e.Offset, m.g)
+	if k > 0 {
+		start := tokens[k].Pos
+		m.pos, m.line, m.col = start.Offset, start.Line, start.Column
+		m.insertSemi = insertsSemicolon(tokens[:k])
+	}
+	// The tokens before k and their diagnostics stay
+	newTokens := make([]models.Token, k, len(tokens)+len(e.Inserted))
+	copy(newTokens, tokens)
+	var kept []models.Diagnostic
+	if k > 0 {
+		kept = diagnosticsBefore(diags, tokens[k].Pos.Offset)
+	}
+
+	editEnd := e.Offset + len(e.Inserted) // in the new input
+	j := k                                // the old token that may match
+	for relexed := 1; ; relexed++ {
+		t, ok := m.next(true)
+		if !ok {
+			if len(newTokens) == 0 {
+				newTokens = nil // as LexOptions returns it
+			}
+			return newTokens, append(kept, m.diags...), relexed - 1
+		}
+		newTokens = append(newTokens, t)
+		if t.Pos.Offset < editEnd || !significant(t) {
+			continue
+		}
+
+		// A token after the edit that was there before leaves the
+		// machine as it was then, the rest is the same
+		old := t.Pos.Offset - delta
+		for j < len(tokens) && tokens[j].Pos.Offset < old {
+			j++
+		}
+		if j == len(tokens) {
+			continue
+		}
+		if prev := tokens[j]; prev.Pos.Offset != old || !significant(prev) || prev.Type != t.Type || prev.Value != t.Value {
+			continue
+		}
+		from, to := tokens[j].End, t.End
+		for _, t := range tokens[j+1:] {
+			t.Pos, t.End = move(t.Pos, from, to), move(t.End, from, to)
+			newTokens = append(newTokens, t)
+		}
+		kept = append(kept, m.diags...)
+		for _, d := range diagnosticsAfter(diags, from.Offset) {
+			d.Pos, d.End = move(d.Pos, from, to), move(d.End, from, to)
+			kept = append(kept, d)
+		}
+		return newTokens, kept, relexed
+	}
+}
+
+// restart returns the index of the last token from which the machine can
+// lex again after an edit at offset, 0 to lex from the start. It stays far
+// enough from the edit that the tokens before it can't have changed: to end
+// a token the machine reads up to the longest operator and a rune beyond
+// it. Implicit semicolons don't mark where the machine was, those inserted
+// after block comments are placed inside them. Neither do Error tokens: a
+// malformed literal ends a line like the literal, an illegal character
+// leaves it as it was, so the machine lexes them again.
+func restart(tokens []models.Token, offset int, g *grammar) int {
+	lookahead := g.longest + utf8.UTFMax
+	k := sort.Search(len(tokens), func(i int) bool { return tokens[i].Pos.Offset+lookahead > offset }) - 1
+	for k > 0 && tokens[k].Implicit {
+		k--
+	}
+	for i := k - 1; i >= 0 && !tokens[i].Implicit; i-- {
+		if t := tokens[i]; t.Type == models.Error {
+			k = i
+		} else if !t.Type.IsTrivia() {
+			break
+		}
+	}
+	return max(k, 0)
+}
+
+// insertsSemicolon returns whether a line break after tokens would insert
+// a semicolon, the state the machine had after them. The last of them that
+// isn't trivia is no Error token, see restart.
+func insertsSemicolon(tokens []models.Token) bool {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		switch t := tokens[i]; {
+		case t.Implicit:
+			return false
+		case !t.Type.IsTrivia():
+			return models.InsertsSemicolon(t)
+		}
+	}
+	return false
+}
+
+// significant reports whether the state of the machine after the token
+// depends on the token alone: it decides on semicolons, and no semicolon is
+// pending from a comment.
+func significant(t models.Token) bool {
+	return !t.Implicit && t.Type != models.Error && !t.Type.IsTrivia()
+}
+
+// diagnosticsBefore returns the diagnostics of the tokens before offset.
+// The diagnostics of a token end inside it or at its end.
+func diagnosticsBefore(diags []models.Diagnostic, offset int) []models.Diagnostic {
+	var before []models.Diagnostic
+	for _, d := range diags {
+		if d.End.Offset <= offset {
+			before = append(before, d)
+		}
+	}
+	return before
+}
+
+// diagnosticsAfter returns the diagnostics of the tokens after offset.
+func diagnosticsAfter(diags []models.Diagnostic, offset int) []models.Diagnostic {
+	var after []models.Diagnostic
+	for _, d := range diags {
+		if d.End.Offset > offset {
+			after = append(after, d)
+		}
+	}
+	return after
+}
+
+// move moves a position at or after from, which is now at to. Positions on
+// the line of from move by its columns as well.
+func move(p, from, to models.Position) models.Position {
+	if p.Line == from.Line {
+		p.Column += to.Column - from.Column
+	}
+	p.Offset += to.Offset - from.Offset
+	p.Line += to.Line - from.Line
+	return p
+}
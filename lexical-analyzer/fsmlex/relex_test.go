@@ -0,0 +1,160 @@
+package fsmlex
+
+import (
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"analyzer/language"
+	"analyzer/models"
+)
+
+// checkRelex relexes src after the edit and compares with a full lex.
+func checkRelex(t *testing.T, src string, e Edit, opts models.Options) int {
+	t.Helper()
+	tokens, diags := LexOptions(src, opts)
+	edited := e.Apply(src)
+	got, gotDiags, relexed := relex(edited, tokens, diags, e, opts)
+	want, wantDiags := LexOptions(edited, opts)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("tokens of %q after %+v (%+v) differ\ngot:  %v\nwant: %v", src, e, opts, got, want)
+	}
+	if !reflect.DeepEqual(gotDiags, wantDiags) {
+		t.Fatalf("diagnostics of %q after %+v (%+v) differ\ngot:  %v\nwant: %v", src, e, opts, gotDiags, wantDiags)
+	}
+	return relexed
+}
+
+var relexOptions = []models.Options{
+	{},
+	{Trivia: true},
+	{Semicolons: true},
+	{Semicolons: true, Trivia: true, Decode: true, Predeclared: true},
+}
+
+func TestRelex(t *testing.T) {
+	tests := []struct {
+		src  string
+		edit Edit
+	}{
+		{"x := 1\ny := 2\n", Edit{Offset: 5, Deleted: 1, Inserted: "10"}},
+		{"x := 1\ny := 2\n", Edit{Offset: 0, Deleted: 0, Inserted: "var "}},
+		{"x := 1\ny := 2\n", Edit{Offset: 14, Deleted: 0, Inserted: "z"}},
+		{"x := 1\ny := 2\n", Edit{Offset: 0, Deleted: 14, Inserted: ""}},
+		{"", Edit{Offset: 0, Deleted: 0, Inserted: "a b"}},
+		// Opening a comment or a string changes everything after it
+		{"a := b\nc := d\n", Edit{Offset: 2, Deleted: 0, Inserted: "/*"}},
+		{"a := b\nc := d\n", Edit{Offset: 7, Deleted: 0, Inserted: "`"}},
+		{"a /* b */ c\nd", Edit{Offset: 7, Deleted: 2, Inserted: ""}},
+		// Closing one changes it back
+		{"a /* b c\nd */ e", Edit{Offset: 6, Deleted: 0, Inserted: "*/"}},
+		// An operator before the edit grows, a semicolon comes and goes
+		{"a .. b", Edit{Offset: 4, Deleted: 0, Inserted: "."}},
+		{"x\n+ y", Edit{Offset: 1, Deleted: 0, Inserted: "+"}},
+		{"return\nx", Edit{Offset: 0, Deleted: 6, Inserted: "go"}},
+		{"a /* x\ny */ b\nc", Edit{Offset: 9, Deleted: 0, Inserted: "z"}},
+		// Errors before and after the edit
+		{"a := 08\nb := '\\q'\nc := \"x", Edit{Offset: 10, Deleted: 1, Inserted: "\xff"}},
+		{"a := 08\nb := 1e\nc := 2\n", Edit{Offset: 16, Deleted: 1, Inserted: "3"}},
+		{"é := \"😀\"\nx", Edit{Offset: 9, Deleted: 0, Inserted: "ü"}},
+		// A malformed literal before the edit ends the line like the literal
+		{"a := b\nx := 0x /* \xff c\nd */ e\n", Edit{Offset: 22, Deleted: 0, Inserted: "z"}},
+		{"a := 08 @ /*\n*/ b", Edit{Offset: 17, Deleted: 0, Inserted: "c"}},
+		// Edits on the line of the tokens that are moved
+		{"a := 1; b := 2; c := 3\nd := 4", Edit{Offset: 5, Deleted: 1, Inserted: "100\n+1"}},
+	}
+	for _, tt := range tests {
+		for _, opts := range relexOptions {
+			checkRelex(t, tt.src, tt.edit, opts)
+		}
+	}
+
+	// Tokens without positions are lexed again as a whole
+	checkRelex(t, "x := 1\ny := 2\n", Edit{Offset: 5, Deleted: 1, Inserted: "10"}, models.Options{Semicolons: true, OmitPositions: true})
+}
+
+func TestRelexLanguages(t *testing.T) {
+	tests := []struct {
+		lang string
+		src  string
+		edit Edit
+	}{
+		{"c", "int a = 1;\n#define X \\\n 2\nint b;", Edit{Offset: 22, Deleted: 1, Inserted: ""}},
+		{"c", "a = b >> c;", Edit{Offset: 7, Deleted: 0, Inserted: "="}},
+		{"json", `{"a": [1, 2], "b": null}`, Edit{Offset: 7, Deleted: 1, Inserted: "-1e5"}},
+		{"json", `{"a": [1, 2], "b": null}`, Edit{Offset: 5, Deleted: 0, Inserted: `"`}},
+	}
+	for _, tt := range tests {
+		for _, opts := range relexOptions {
+			opts.Language = language.Lookup(tt.lang)
+			checkRelex(t, tt.src, tt.edit, opts)
+		}
+	}
+}
+
+// TestRelexRandom makes random edits of the example with text that is
+// likely to change the tokens around it. The edits pile up for a while, so
+// that they meet the errors of earlier ones.
+func TestRelexRandom(t *testing.T) {
+	src, err := os.ReadFile("../examples/example.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pieces := []string{"", "x", " ", "\n", "/*", "*/", "//", "\"", "`", "'", "\\", ".", "..", "0x", "1e", "i", "<", "=", "}", "é", "\xff", "\r\n"}
+	rng := rand.New(rand.NewSource(1))
+	var input string
+	for i := 0; i < 2000; i++ {
+		if i%50 == 0 {
+			input = string(src)
+		}
+		offset := rng.Intn(len(input) + 1)
+		e := Edit{Offset: offset, Deleted: rng.Intn(min(4, len(input)-offset) + 1), Inserted: pieces[rng.Intn(len(pieces))]}
+		checkRelex(t, input, e, relexOptions[i%len(relexOptions)])
+		input = e.Apply(input)
+	}
+}
+
+func TestRelexIsLocal(t *testing.T) {
+	src := strings.Repeat("x := y + 1 // z\n", 1000)
+	e := Edit{Offset: len(src) / 2, Deleted: 1, Inserted: "abc"}
+	for _, opts := range relexOptions {
+		if relexed := checkRelex(t, src, e, opts); relexed > 10 {
+			t.Errorf("%+v: relexed %d tokens for an edit of one identifier", opts, relexed)
+		}
+	}
+}
+
+func TestRelexFallback(t *testing.T) {
+	src := "a := 08 + 09\nb := 1"
+	opts := models.Options{MaxErrors: 1}
+	// Lexing stops at the first error, after three tokens
+	if relexed := checkRelex(t, src, Edit{Offset: 18, Deleted: 1, Inserted: "2"}, opts); relexed != 3 {
+		t.Errorf("relexed %d tokens with a limit on errors; want all 3", relexed)
+	}
+
+	// An edit that doesn't fit the input
+	tokens, diags := LexOptions(src, models.Options{})
+	got, _ := Relex("x", tokens, diags, Edit{Offset: 5, Inserted: "yy"}, models.Options{})
+	if want := Lex("x"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Relex with a bad edit = %v; want %v", got, want)
+	}
+}
+
+func BenchmarkRelex(b *testing.B) {
+	src := strings.Repeat("x := y + 1 // z\n", 10000)
+	tokens, diags := LexOptions(src, models.Options{})
+	e := Edit{Offset: len(src) / 2, Deleted: 1, Inserted: "abc"}
+	edited := e.Apply(src)
+	b.Run("full", func(b *testing.B) {
+		for b.Loop() {
+			LexOptions(edited, models.Options{})
+		}
+	})
+	b.Run("incremental", func(b *testing.B) {
+		for b.Loop() {
+			Relex(edited, tokens, diags, e, models.Options{})
+		}
+	})
+}
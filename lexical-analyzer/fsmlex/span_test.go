@@ -0,0 +1,96 @@
+package fsmlex
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"analyzer/language"
+	"analyzer/models"
+)
+
+func TestLexSpans(t *testing.T) {
+	src, err := os.ReadFile("../examples/example.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := append([]string{string(src), "", "x := 08 + \"\\q\" + \xff", "a /* b\nc */ d"}, lineInputs...)
+	for _, input := range inputs {
+		for _, opts := range relexOptions {
+			opts.Decode = false
+			want, wantDiags := LexOptions(input, opts)
+			spans, diags := LexSpans([]byte(input), opts, nil)
+			if got := models.Tokens(input, spans); !reflect.DeepEqual(got, want) {
+				t.Errorf("tokens of the spans of %q (%+v) differ\ngot:  %v\nwant: %v", input, opts, got, want)
+			}
+			if !reflect.DeepEqual(diags, wantDiags) {
+				t.Errorf("diagnostics of %q (%+v) = %v; want %v", input, opts, diags, wantDiags)
+			}
+		}
+	}
+
+	// Spans slice the source
+	src = []byte("var x = 1")
+	spans, _ := LexSpans(src, models.Options{}, nil)
+	if text := spans[1].Text(src); string(text) != "x" || &text[0] != &src[4] {
+		t.Errorf("Text of %v = %q; want x in the source", spans[1], text)
+	}
+}
+
+// TestLexSpansAllocs checks that lexing into a slice with room for the
+// spans allocates next to nothing per token.
+func TestLexSpansAllocs(t *testing.T) {
+	src, err := os.ReadFile("../examples/example.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		src  string
+		opts models.Options
+	}{
+		{string(src), models.Options{}},
+		{string(src), models.Options{Trivia: true, Semicolons: true, Predeclared: true}},
+		{"int main(void) { char *s = \"a\\n\"; return s[0] >> 1; }\n", models.Options{Language: language.C}},
+		{`{"a": [1, -2.5e3, true, null], "b": "\u00e9"}`, models.Options{Language: language.JSON}},
+	}
+	for _, tt := range tests {
+		src := []byte(strings.Repeat(tt.src, 100))
+		spans, _ := LexSpans(src, tt.opts, nil)
+		allocs := testing.AllocsPerRun(10, func() {
+			spans, _ = LexSpans(src, tt.opts, spans[:0])
+		})
+		if perToken := allocs / float64(len(spans)); perToken > 0.01 {
+			t.Errorf("%+v: %.0f allocations for %d tokens", tt.opts, allocs, len(spans))
+		}
+	}
+}
+
+// BenchmarkLex compares lexing to tokens with lexing to spans.
+func BenchmarkLex(b *testing.B) {
+	src, err := os.ReadFile("lexer.go")
+	if err != nil {
+		b.Fatal(err)
+	}
+	input := string(src)
+	opts := models.Options{Semicolons: true}
+	b.Run("tokens", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(src)))
+		var n int
+		for b.Loop() {
+			tokens, _ := LexOptions(input, opts)
+			n = len(tokens)
+		}
+		b.ReportMetric(float64(testing.AllocsPerRun(1, func() { LexOptions(input, opts) }))/float64(n), "allocs/token")
+	})
+	b.Run("spans", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(src)))
+		spans, _ := LexSpans(src, opts, nil)
+		for b.Loop() {
+			spans, _ = LexSpans(src, opts, spans[:0])
+		}
+		b.ReportMetric(float64(testing.AllocsPerRun(1, func() { LexSpans(src, opts, spans[:0]) }))/float64(len(spans)), "allocs/token")
+	})
+}
@@ -0,0 +1,106 @@
+package fsmlex
+
+import (
+	"io"
+	"iter"
+	"strings"
+
+	"analyzer/models"
+)
+
+const chunkSize = 64 << 10
+
+// Lexer reads Go source from an io.Reader in chunks and produces tokens on
+// demand. Only the unfinished lexeme is kept between reads, so memory use is
+// bounded by the chunk size and the longest token. Diagnostics are the
+// exception: they are kept until TakeDiagnostics takes them, so on long
+// input with many errors call it now and then or set Options.MaxErrors.
+type Lexer struct {
+	r     io.Reader
+	m     *machine
+	chunk []byte
+	eof   bool
+	err   error
+}
+
+func NewLexer(r io.Reader) *Lexer {
+	return NewLexerSize(r, chunkSize)
+}
+
+// NewLexerSize returns a Lexer that reads at most size bytes at a time.
+func NewLexerSize(r io.Reader, size int) *Lexer {
+	return &Lexer{r: r, m: newMachine("", models.Options{}), chunk: make([]byte, max(size, 1))}
+}
+
+// SetOptions changes the lexer options. It must be called before the first
+// call to Next.
+func (l *Lexer) SetOptions(opts models.Options) {
+	l.m.setOptions(opts)
+}
+
+// Next returns the next token. At the end of the input it returns io.EOF,
+// any other error comes from the underlying reader.
+func (l *Lexer) Next() (models.Token, error) {
+	for {
+		if l.err != nil {
+			return models.Token{}, l.err
+		}
+
+		token, ok := l.m.next(l.eof)
+		if ok {
+			// Values point into the window, don't let them pin it
+			token.Value = strings.Clone(token.Value)
+			return token, nil
+		}
+
+		if l.eof || l.m.done {
+			return models.Token{}, io.EOF
+		}
+		l.fill()
+	}
+}
+
+// Diagnostics returns the problems found in the input read so far, apart
+// from those already taken by TakeDiagnostics.
+func (l *Lexer) Diagnostics() []models.Diagnostic {
+	return l.m.diags
+}
+
+// TakeDiagnostics returns the problems found since the last call and lets
+// the lexer forget them. Options.MaxErrors still counts them.
+func (l *Lexer) TakeDiagnostics() []models.Diagnostic {
+	diags := l.m.diags
+	l.m.taken += len(diags)
+	l.m.diags = nil
+	return diags
+}
+
+// All returns an iterator over the remaining tokens. A read error is yielded
+// once and ends the iteration.
+func (l *Lexer) All() iter.Seq2[models.Token, error] {
+	return func(yield func(models.Token, error) bool) {
+		for {
+			token, err := l.Next()
+			if err == io.EOF {
+				return
+			}
+			if !yield(token, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (l *Lexer) fill() {
+	n, err := l.r.Read(l.chunk)
+	if n > 0 {
+		l.m.feed(l.chunk[:n])
+	}
+
+	switch {
+	case err == io.EOF:
+		l.eof = true
+	case err != nil:
+		l.err = err
+	}
+}
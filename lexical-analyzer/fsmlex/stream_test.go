@@ -0,0 +1,138 @@
+package fsmlex
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"analyzer/models"
+)
+
+func collect(t *testing.T, l *Lexer) []models.Token {
+	t.Helper()
+	var tokens []models.Token
+	for token, err := range l.All() {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+func TestLexerMatchesLex(t *testing.T) {
+	src, err := os.ReadFile("../examples/example.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := []string{
+		string(src),
+		"a /* long\ncomment */ b // tail",
+		"x := `raw\nstring` + \"esc\\\"aped\" + '\\n'",
+		"n := 0x1F + 1.5e-3 + 0b1_0",
+		"x <<= 1\n",
+	}
+
+	for _, input := range inputs {
+		want := Lex(input)
+		for _, size := range []int{1, 2, 3, 7, 64} {
+			got := collect(t, NewLexerSize(strings.NewReader(input), size))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("chunk size %d: tokens of %q differ\ngot:  %v\nwant: %v", size, input, got, want)
+			}
+		}
+
+		got := collect(t, NewLexer(iotest.OneByteReader(strings.NewReader(input))))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("one byte reader: tokens of %q differ", input)
+		}
+	}
+}
+
+func TestLexerNext(t *testing.T) {
+	l := NewLexer(strings.NewReader("a+b"))
+	for _, want := range []string{"a", "+", "b"} {
+		token, err := l.Next()
+		if err != nil || token.Value != want {
+			t.Fatalf("Next() = %v, %v; want %q", token, err, want)
+		}
+	}
+	if _, err := l.Next(); err != io.EOF {
+		t.Errorf("Next() at end = %v; want io.EOF", err)
+	}
+}
+
+func TestLexerReadError(t *testing.T) {
+	boom := errors.New("boom")
+	r := io.MultiReader(strings.NewReader("a b"), iotest.ErrReader(boom))
+
+	var tokens []models.Token
+	var got error
+	for token, err := range NewLexer(r).All() {
+		if err != nil {
+			got = err
+			break
+		}
+		tokens = append(tokens, token)
+	}
+
+	if got != boom {
+		t.Errorf("error = %v; want %v", got, boom)
+	}
+	if len(tokens) != 1 || tokens[0].Value != "a" {
+		t.Errorf("tokens before error = %v; want [a]", tokens)
+	}
+}
+
+// lineInputs end lines in the ways that decide on semicolons, which
+// streaming and spans must not change.
+var lineInputs = []string{
+	"x\n",
+	"x",
+	"return\n",
+	"f(a, b)\n}\n",
+	"a /* c */\nb",
+	"a // c\nb",
+	"a /* multi\nline */ b",
+	"a /* x\n*/ /* y\n*/\n",
+	"s := `raw` + \"str\" + 'r'\n",
+	"x := 08\ny",
+	"a \xff\nb",
+	"x /* a",
+}
+
+func TestLexerOptions(t *testing.T) {
+	opts := models.Options{Semicolons: true}
+	for _, input := range lineInputs {
+		want, _ := LexOptions(input, opts)
+		for _, size := range []int{1, 2, 5} {
+			l := NewLexerSize(strings.NewReader(input), size)
+			l.SetOptions(opts)
+			if got := collect(t, l); !reflect.DeepEqual(got, want) {
+				t.Errorf("chunk size %d: tokens of %q differ\ngot:  %v\nwant: %v", size, input, got, want)
+			}
+		}
+	}
+}
+
+// TestLexerLongToken checks that a lexeme spanning many chunks is not
+// copied again on every read, which made streaming it quadratic.
+func TestLexerLongToken(t *testing.T) {
+	src := "x := \"" + strings.Repeat("a", 4<<20) + "\"\ny"
+	allocs := testing.AllocsPerRun(1, func() {
+		l := NewLexerSize(strings.NewReader(src), 4<<10)
+		tokens := collect(t, l)
+		if len(tokens) != 4 || len(tokens[2].Value) != 4<<20+2 {
+			t.Errorf("tokens = %d, string of %d bytes", len(tokens), len(tokens[2].Value))
+		}
+	})
+	// 1024 reads, but the window only doubles a few times
+	if allocs > 100 {
+		t.Errorf("%.0f allocations for a string of 1024 chunks", allocs)
+	}
+}
@@ -0,0 +1,92 @@
+package fsmlex
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"analyzer/models"
+)
+
+func TestLexUnicode(t *testing.T) {
+	input := "имя := \"строка\" + 'ж' // комментарий\nπ2 = x·y"
+	want := []models.Token{
+		{Type: models.Identifier, Value: "имя"},
+		{Type: models.Operator, Value: ":="},
+		{Type: models.StringLiteral, Value: `"строка"`},
+		{Type: models.Operator, Value: "+"},
+		{Type: models.RuneLiteral, Value: "'ж'"},
+		{Type: models.Identifier, Value: "π2"},
+		{Type: models.Operator, Value: "="},
+		{Type: models.Identifier, Value: "x"},
+		{Type: models.Error, Value: "·"},
+		{Type: models.Identifier, Value: "y"},
+	}
+
+	tokens := Lex(input)
+	if len(tokens) != len(want) {
+		t.Fatalf("Lex(%q) = %v; want %d tokens", input, tokens, len(want))
+	}
+	for i, tok := range tokens {
+		if tok.Type != want[i].Type || tok.Value != want[i].Value {
+			t.Errorf("token %d = %s %q; want %s %q", i, tok.Type, tok.Value, want[i].Type, want[i].Value)
+		}
+	}
+
+	// Columns count bytes, as go/scanner does
+	if pos := tokens[1].Pos; pos.Offset != 7 || pos.Column != 8 {
+		t.Errorf("position of := is %+v; want offset 7, column 8", pos)
+	}
+
+	for _, size := range []int{1, 2, 3} {
+		got := collect(t, NewLexerSize(strings.NewReader(input), size))
+		if !reflect.DeepEqual(got, tokens) {
+			t.Errorf("chunk size %d: streamed tokens differ from Lex", size)
+		}
+	}
+}
+
+func TestLexIllegalEncoding(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []models.Token
+	}{
+		{"a \xff b", []models.Token{
+			{Type: models.Identifier, Value: "a"},
+			{Type: models.Error, Value: "\xff"},
+			{Type: models.Identifier, Value: "b"},
+		}},
+		{"s = \"a\xffb\"", []models.Token{
+			{Type: models.Identifier, Value: "s"},
+			{Type: models.Operator, Value: "="},
+			{Type: models.Error, Value: "\"a\xffb\""},
+		}},
+		{"a // \xfe\nb", []models.Token{
+			{Type: models.Identifier, Value: "a"},
+			{Type: models.Error, Value: "// \xfe"},
+			{Type: models.Identifier, Value: "b"},
+		}},
+		{"\uFEFFpackage p", []models.Token{
+			{Type: models.Keyword, Value: "package"},
+			{Type: models.Identifier, Value: "p"},
+		}},
+		{"a\uFEFFb", []models.Token{
+			{Type: models.Identifier, Value: "a"},
+			{Type: models.Error, Value: "\uFEFF"},
+			{Type: models.Identifier, Value: "b"},
+		}},
+	}
+
+	for _, tt := range tests {
+		tokens := Lex(tt.input)
+		if len(tokens) != len(tt.want) {
+			t.Errorf("Lex(%q) = %v; want %v", tt.input, tokens, tt.want)
+			continue
+		}
+		for i, tok := range tokens {
+			if tok.Type != tt.want[i].Type || tok.Value != tt.want[i].Value {
+				t.Errorf("Lex(%q)[%d] = %s %q; want %s %q", tt.input, i, tok.Type, tok.Value, tt.want[i].Type, tt.want[i].Value)
+			}
+		}
+	}
+}
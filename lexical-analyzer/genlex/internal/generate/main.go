@@ -0,0 +1,27 @@
+// Command generate writes match.go of genlex from the rules of rxlex.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"analyzer/lexgen"
+	"analyzer/rxlex"
+)
+
+func main() {
+	dfa, err := lexgen.Compile(rxlex.Rules)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	src, err := dfa.Generate(lexgen.Config{Package: "genlex"})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile("match.go", src, 0o666); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
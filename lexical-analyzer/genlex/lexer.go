@@ -0,0 +1,45 @@
+// Package genlex is the Go lexer generated by lexgen from the rules of
+// rxlex. Lexemes are matched by a minimised DFA compiled into Go code,
+// everything else is shared with rxlex, so both give the same tokens.
+package genlex
+
+import (
+	"analyzer/language"
+	"analyzer/models"
+	"analyzer/rxlex"
+)
+
+//go:generate go run ./internal/generate
+
+func init() {
+	models.RegisterLexer("gen", lexer{})
+}
+
+// lexer is the registered Lexer, which knows only Go.
+type lexer struct{}
+
+func (lexer) Lex(input string, opts models.Options) ([]models.Token, []models.Diagnostic) {
+	return LexOptions(input, opts)
+}
+
+func (lexer) Languages() []*models.Language {
+	return []*models.Language{language.Go}
+}
+
+// Lex returns the tokens of input and the first error found in it.
+func Lex(input string) ([]models.Token, error) {
+	tokens, diags := LexOptions(input, models.Options{})
+	for _, d := range diags {
+		if d.Severity == models.SeverityError {
+			return tokens, d
+		}
+	}
+	return tokens, nil
+}
+
+// LexOptions lexes the whole input, see rxlex.LexOptions. The matcher is
+// generated for Go, so Options.Language is ignored.
+func LexOptions(input string, opts models.Options) ([]models.Token, []models.Diagnostic) {
+	opts.Language = language.Go
+	return rxlex.LexWith(input, opts, match)
+}
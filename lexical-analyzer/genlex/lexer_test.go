@@ -0,0 +1,76 @@
+package genlex
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"analyzer/lexgen"
+	"analyzer/models"
+	"analyzer/rxlex"
+)
+
+// TestGenerated checks that match.go is up to date with the rules of rxlex.
+func TestGenerated(t *testing.T) {
+	dfa, err := lexgen.Compile(rxlex.Rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := dfa.Generate(lexgen.Config{Package: "genlex"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("match.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("match.go is out of date, run go generate")
+	}
+}
+
+var options = []models.Options{
+	{},
+	{Semicolons: true, Predeclared: true},
+	{Trivia: true, Decode: true},
+	{Semicolons: true, Trivia: true, Decode: true, MaxErrors: 3},
+}
+
+// TestSources lexes the sources of this module with genlex and rxlex.
+func TestSources(t *testing.T) {
+	files, err := filepath.Glob("../*/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, opts := range options {
+			check(t, string(src), opts)
+		}
+	}
+}
+
+func FuzzRX(f *testing.F) {
+	for _, seed := range []string{"x := 1.5e3i + 'a'", "\"\\x\n`raw", "/* *", "0x_1p", "\xff\x00ж"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		for _, opts := range options {
+			check(t, src, opts)
+		}
+	})
+}
+
+func check(t *testing.T, src string, opts models.Options) {
+	t.Helper()
+	tokens, diags := LexOptions(src, opts)
+	wantTokens, wantDiags := rxlex.LexOptions(src, opts)
+	if !reflect.DeepEqual(tokens, wantTokens) || !reflect.DeepEqual(diags, wantDiags) {
+		t.Fatalf("LexOptions(%.40q, %+v) differs from rxlex:\n%v\n%v\nwant\n%v\n%v", src, opts, tokens, diags, wantTokens, wantDiags)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+
+	"analyzer/driver"
+	"analyzer/highlight"
+	"analyzer/models"
+)
+
+const highlightUsage = "highlight [FLAGS] PATH..."
+
+// highlightCommand writes files coloured by token type. Diagnostics go to
+// stderr as well.
+func highlightCommand(args []string) int {
+	fs := newFlagSet("highlight", highlightUsage)
+	var f lexFlags
+	f.add(fs)
+	var formats []string
+	for _, format := range highlight.Formats {
+		formats = append(formats, string(format))
+	}
+	format := fs.String("format", "ansi", "output `FORMAT`: "+oneOf(formats))
+	themeFile := fs.String("theme", "", "read the colours from a JSON theme `FILE`")
+	if code, stop := parseFlags(fs, args); stop {
+		return code
+	}
+	if msg := f.check(); msg != "" {
+		return usageError(fs, "%s", msg)
+	}
+	if fs.NArg() == 0 {
+		return usageError(fs, "no files to highlight")
+	}
+	opts := highlight.Options{Theme: highlight.Default}
+	if *themeFile != "" {
+		theme, err := highlight.LoadTheme(*themeFile)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return 2
+		}
+		opts.Theme = theme
+	}
+	files, err := f.files(fs.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+	opts.Names = len(files) > 1
+	out, err := highlight.NewWriter(stdout, highlight.Format(*format), opts)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	ctx, stop := interruptible()
+	defer stop()
+	config := driver.Config{
+		Workers: f.workers,
+		Read:    readFile,
+		Lex: func(name string, src []byte) ([]models.Token, []models.Diagnostic) {
+			return f.lex(name, src, models.Options{Trivia: true})
+		},
+	}
+	summary, err := config.Run(ctx, files, func(file driver.File) error {
+		if file.Err != nil {
+			return file.Err
+		}
+		for _, d := range file.Diagnostics {
+			fmt.Fprintln(stderr, d.Format(file.Name))
+		}
+		return out.WriteFile(highlight.File{
+			Name:        file.Name,
+			Src:         file.Src,
+			Tokens:      file.Tokens,
+			Diagnostics: file.Diagnostics,
+		})
+	})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+	if summary.WithErrors > 0 {
+		return 1
+	}
+	return 0
+}
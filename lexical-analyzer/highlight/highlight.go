@@ -0,0 +1,238 @@
+// Package highlight colours source code by token type, with ANSI escapes
+// for terminals or as a self-contained HTML page with a CSS class per token
+// type. Lexical errors are marked.
+package highlight
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"analyzer/models"
+)
+
+// Format names an output format.
+type Format string
+
+const (
+	ANSI Format = "ansi" // 24-bit colour escapes for terminals
+	HTML Format = "html" // a page with the styles in a <style> element
+)
+
+// Formats lists the formats in the order they are documented.
+var Formats = []Format{ANSI, HTML}
+
+// File is a file to highlight. The tokens should be lexed with
+// Options.Trivia so that comments are styled too; the text between tokens
+// is written as it is.
+type File struct {
+	Name        string
+	Src         []byte
+	Tokens      []models.Token
+	Diagnostics []models.Diagnostic
+}
+
+// Options configures a Writer.
+type Options struct {
+	Theme *Theme // Default if nil
+	Names bool   // write the name of each file before its source
+}
+
+// Writer writes highlighted files. Close finishes the output, such as the
+// end of the HTML page.
+type Writer interface {
+	WriteFile(f File) error
+	Close() error
+}
+
+// NewWriter returns a Writer for format f.
+func NewWriter(w io.Writer, f Format, opts Options) (Writer, error) {
+	if opts.Theme == nil {
+		opts.Theme = Default
+	}
+	switch f {
+	case ANSI:
+		return &ansiWriter{w: bufio.NewWriter(w), opts: opts}, nil
+	case HTML:
+		return &htmlWriter{w: bufio.NewWriter(w), opts: opts}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", f)
+}
+
+// A segment is a token, or the text between tokens if typ is empty.
+type segment struct {
+	text   string
+	typ    models.TokenType
+	marked bool     // an error token or a diagnostic points into it
+	errors []string // the messages of the diagnostics
+}
+
+// segments cuts the source of f into segments. Implicit tokens and tokens
+// out of order or beyond the source are skipped.
+func segments(f File) []segment {
+	diags := slices.Clone(f.Diagnostics)
+	diags = slices.DeleteFunc(diags, func(d models.Diagnostic) bool { return d.Severity != models.SeverityError })
+	slices.SortStableFunc(diags, func(a, b models.Diagnostic) int { return a.Pos.Offset - b.Pos.Offset })
+
+	var segs []segment
+	var active []models.Diagnostic // diagnostics that may reach into the next segment
+	add := func(start, end int, typ models.TokenType) {
+		s := segment{text: string(f.Src[start:end]), typ: typ, marked: typ == models.Error}
+		for len(diags) > 0 && diags[0].Pos.Offset < end {
+			active = append(active, diags[0])
+			diags = diags[1:]
+		}
+		active = slices.DeleteFunc(active, func(d models.Diagnostic) bool {
+			return d.End.Offset <= start && d.Pos.Offset < start
+		})
+		for _, d := range active {
+			s.marked = true
+			s.errors = append(s.errors, d.Message)
+		}
+		segs = append(segs, s)
+	}
+
+	pos := 0
+	for _, t := range f.Tokens {
+		start, end := t.Pos.Offset, t.End.Offset
+		if t.Implicit || start < pos || end <= start || end > len(f.Src) {
+			continue
+		}
+		if start > pos {
+			add(pos, start, models.NoType)
+		}
+		add(start, end, t.Type)
+		pos = end
+	}
+	if pos < len(f.Src) {
+		add(pos, len(f.Src), models.NoType)
+	}
+	return segs
+}
+
+func (o Options) style(s segment) Style {
+	style := o.Theme.Styles[s.typ]
+	if s.marked {
+		style = style.over(o.Theme.Error)
+	}
+	return style
+}
+
+type ansiWriter struct {
+	w       *bufio.Writer
+	opts    Options
+	written bool // a file is written
+}
+
+func (a *ansiWriter) WriteFile(f File) error {
+	if a.opts.Names {
+		if a.written {
+			a.w.WriteString("\n")
+		}
+		fmt.Fprintf(a.w, "\x1b[1m%s\x1b[0m\n", f.Name)
+	}
+	a.written = true
+	for _, s := range segments(f) {
+		sgr := a.opts.style(s).sgr()
+		if sgr == "" {
+			a.w.WriteString(s.text)
+			continue
+		}
+		// Styles end at line ends, so that pagers showing some of the
+		// lines get them right
+		for i, line := range strings.Split(s.text, "\n") {
+			if i > 0 {
+				a.w.WriteString("\n")
+			}
+			if line != "" {
+				a.w.WriteString(sgr + line + "\x1b[0m")
+			}
+		}
+	}
+	return a.w.Flush()
+}
+
+func (a *ansiWriter) Close() error { return a.w.Flush() }
+
+type htmlWriter struct {
+	w       *bufio.Writer
+	opts    Options
+	started bool // the head of the page is written
+}
+
+// className returns the CSS class of a token type: "t-" and the name in
+// lower case with other characters than letters and digits replaced by
+// "-".
+func className(typ models.TokenType) string {
+	return "t-" + strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9':
+			return r
+		case 'A' <= r && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, typ.String())
+}
+
+// start writes the head of the page with the styles of the theme.
+func (h *htmlWriter) start(title string) {
+	h.started = true
+	theme := h.opts.Theme
+	fmt.Fprintf(h.w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n", html.EscapeString(title))
+	body := Style{Color: theme.Foreground, Background: theme.Background}.css()
+	fmt.Fprintf(h.w, "body { %s }\n", body)
+	fmt.Fprintf(h.w, "pre { font-family: ui-monospace, Menlo, Consolas, monospace; }\n")
+	for _, typ := range slices.Sorted(maps.Keys(theme.Styles)) {
+		if css := theme.Styles[typ].css(); css != "" {
+			fmt.Fprintf(h.w, ".%s { %s }\n", className(typ), css)
+		}
+	}
+	if css := theme.Error.css(); css != "" {
+		fmt.Fprintf(h.w, ".error { %s }\n", css)
+	}
+	fmt.Fprintf(h.w, "</style>\n</head>\n<body>\n")
+}
+
+func (h *htmlWriter) WriteFile(f File) error {
+	if !h.started {
+		h.start(f.Name)
+	}
+	if h.opts.Names {
+		fmt.Fprintf(h.w, "<h2>%s</h2>\n", html.EscapeString(f.Name))
+	}
+	h.w.WriteString("<pre>")
+	for _, s := range segments(f) {
+		text := html.EscapeString(s.text)
+		if !s.marked && (s.typ == models.NoType || s.typ == models.Whitespace || s.typ == models.Newline) {
+			h.w.WriteString(text)
+			continue
+		}
+		var classes []string
+		if s.typ != models.NoType {
+			classes = append(classes, className(s.typ))
+		}
+		if s.marked {
+			classes = append(classes, "error")
+		}
+		fmt.Fprintf(h.w, `<span class="%s"`, strings.Join(classes, " "))
+		if len(s.errors) > 0 {
+			fmt.Fprintf(h.w, ` title="%s"`, html.EscapeString(strings.Join(s.errors, "\n")))
+		}
+		fmt.Fprintf(h.w, ">%s</span>", text)
+	}
+	h.w.WriteString("</pre>\n")
+	return h.w.Flush()
+}
+
+func (h *htmlWriter) Close() error {
+	if !h.started {
+		h.start("")
+	}
+	h.w.WriteString("</body>\n</html>\n")
+	return h.w.Flush()
+}
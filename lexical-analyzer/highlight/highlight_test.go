@@ -0,0 +1,192 @@
+package highlight
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"analyzer/fsmlex"
+	"analyzer/models"
+)
+
+func file(src string, opts models.Options) File {
+	tokens, diags := fsmlex.LexOptions(src, opts)
+	return File{Name: "x.go", Src: []byte(src), Tokens: tokens, Diagnostics: diags}
+}
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		src  string
+		opts models.Options
+		want []segment
+	}{
+		{"a /* c */ 1", models.Options{Trivia: true}, []segment{
+			{text: "a", typ: models.Identifier},
+			{text: " ", typ: models.Whitespace},
+			{text: "/* c */", typ: models.Comment},
+			{text: " ", typ: models.Whitespace},
+			{text: "1", typ: models.IntLiteral},
+		}},
+		// Without trivia, the text between tokens fills the gaps
+		{"a /* c */ 1\n", models.Options{Semicolons: true}, []segment{
+			{text: "a", typ: models.Identifier},
+			{text: " /* c */ "},
+			{text: "1", typ: models.IntLiteral},
+			{text: "\n"},
+		}},
+		{`x := "a\q" + 08`, models.Options{Trivia: true}, []segment{
+			{text: "x", typ: models.Identifier},
+			{text: " ", typ: models.Whitespace},
+			{text: ":=", typ: models.Operator},
+			{text: " ", typ: models.Whitespace},
+			{text: `"a\q"`, typ: models.Error, marked: true, errors: []string{"unknown escape sequence"}},
+			{text: " ", typ: models.Whitespace},
+			{text: "+", typ: models.Operator},
+			{text: " ", typ: models.Whitespace},
+			{text: "08", typ: models.Error, marked: true, errors: []string{"invalid digit '8' in octal literal"}},
+		}},
+		{"", models.Options{}, nil},
+	}
+
+	for _, tt := range tests {
+		if got := segments(file(tt.src, tt.opts)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("segments(%q) =\n%+v\nwant\n%+v", tt.src, got, tt.want)
+		}
+	}
+
+	// Diagnostics mark the tokens they reach into, warnings don't
+	f := File{
+		Src: []byte("abc def"),
+		Tokens: []models.Token{
+			{Type: models.Identifier, Value: "abc", Pos: models.Position{Offset: 0}, End: models.Position{Offset: 3}},
+			{Type: models.Identifier, Value: "def", Pos: models.Position{Offset: 4}, End: models.Position{Offset: 7}},
+		},
+		Diagnostics: []models.Diagnostic{
+			{Message: "wide", Pos: models.Position{Offset: 2}, End: models.Position{Offset: 5}},
+			{Message: "empty", Pos: models.Position{Offset: 4}, End: models.Position{Offset: 4}},
+			{Message: "warning", Severity: models.SeverityWarning, Pos: models.Position{Offset: 0}, End: models.Position{Offset: 7}},
+		},
+	}
+	want := []segment{
+		{text: "abc", typ: models.Identifier, marked: true, errors: []string{"wide"}},
+		{text: " ", marked: true, errors: []string{"wide"}},
+		{text: "def", typ: models.Identifier, marked: true, errors: []string{"wide", "empty"}},
+	}
+	if got := segments(f); !reflect.DeepEqual(got, want) {
+		t.Errorf("segments with diagnostics =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestANSI(t *testing.T) {
+	var b strings.Builder
+	w, err := NewWriter(&b, ANSI, Options{Names: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteFile(file("/* a\nb */ x 08", models.Options{Trivia: true}))
+	w.WriteFile(file("go", models.Options{Trivia: true}))
+	w.Close()
+
+	want := "\x1b[1mx.go\x1b[0m\n" +
+		"\x1b[3;38;2;127;132;142m/* a\x1b[0m\n\x1b[3;38;2;127;132;142mb */\x1b[0m x \x1b[4;38;2;224;108;117m08\x1b[0m" +
+		"\n\x1b[1mx.go\x1b[0m\n" +
+		"\x1b[38;2;198;120;221mgo\x1b[0m"
+	if b.String() != want {
+		t.Errorf("ANSI output %q; want %q", b.String(), want)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var b strings.Builder
+	theme, err := ParseTheme([]byte(`{"styles": {"Identifier": {"bold": true}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWriter(&b, HTML, Options{Theme: theme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteFile(file("a < 'b' // <c>\n'", models.Options{Trivia: true}))
+	w.Close()
+
+	for _, want := range []string{
+		"<title>x.go</title>",
+		"body { color: #abb2bf; background-color: #282c34 }\n",
+		".t-identifier { font-weight: bold }\n",
+		".t-keyword { color: #c678dd }\n",
+		".error { color: #e06c75; text-decoration: underline }\n",
+		`<pre><span class="t-identifier">a</span> <span class="t-operator">&lt;</span> <span class="t-rune">&#39;b&#39;</span> <span class="t-comment">// &lt;c&gt;</span>` + "\n",
+		`<span class="t-error error" title="rune literal not terminated">&#39;</span></pre>`,
+		"</body>\n</html>\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("HTML output\n%s\nlacks %q", b.String(), want)
+		}
+	}
+	if strings.Contains(b.String(), "<h2>") {
+		t.Errorf("HTML output has file names without Options.Names")
+	}
+
+	b.Reset()
+	w, _ = NewWriter(&b, HTML, Options{})
+	w.Close()
+	if !strings.HasPrefix(b.String(), "<!DOCTYPE html>") || !strings.HasSuffix(b.String(), "<body>\n</body>\n</html>\n") {
+		t.Errorf("HTML output without files:\n%s", b.String())
+	}
+
+	if _, err := NewWriter(&b, "svg", Options{}); err == nil {
+		t.Errorf("NewWriter(svg) succeeded")
+	}
+}
+
+func TestClassName(t *testing.T) {
+	tests := map[models.TokenType]string{
+		models.Keyword:                         "t-keyword",
+		models.Error:                           "t-error",
+		models.PredeclaredType:                 "t-predeclaredtype",
+		models.NewTokenType("Block Comment/2"): "t-block-comment-2",
+	}
+	for typ, want := range tests {
+		if got := className(typ); got != want {
+			t.Errorf("className(%q) = %q; want %q", typ, got, want)
+		}
+	}
+}
+
+func TestParseTheme(t *testing.T) {
+	models.NewTokenType("Name")
+	theme, err := ParseTheme([]byte(`{"background": "#fff", "styles": {"Keyword": {"color": "#123", "bold": true}, "Name": {"color": "#abcdef"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if theme.Background != "#fff" || theme.Foreground != Default.Foreground || theme.Error != Default.Error {
+		t.Errorf("theme colours %q, %q and %+v", theme.Background, theme.Foreground, theme.Error)
+	}
+	if s := theme.Styles[models.Keyword]; s != (Style{Color: "#123", Bold: true}) || s.sgr() != "\x1b[1;38;2;17;34;51m" {
+		t.Errorf("Keyword style %+v, %q", s, s.sgr())
+	}
+	if theme.Styles[models.NewTokenType("Name")].Color != "#abcdef" || theme.Styles[models.Comment] != Default.Styles[models.Comment] {
+		t.Errorf("styles %+v", theme.Styles)
+	}
+	if Default.Styles[models.Keyword].Bold || Default.Styles[models.NewTokenType("Name")] != (Style{}) {
+		t.Errorf("ParseTheme changed Default")
+	}
+
+	errors := map[string]string{
+		`{"styles": {"Int": {"color": "red"}}}`:      `Int color: invalid color "red"`,
+		`{"error": {"background": "#12345g"}}`:       `error background: invalid color "#12345g"`,
+		`{"foreground": "#1234"}`:                    `foreground: invalid color "#1234"`,
+		`{"styles": {"Int": {"colour": "#123456"}}}`: `unknown field "colour"`,
+		`{"styles": [`:                               "unexpected EOF",
+		`{"styles": {"Identifer": {"bold": true}}}`:  `unknown token type "Identifer"`,
+	}
+	for src, want := range errors {
+		if _, err := ParseTheme([]byte(src)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseTheme(%s) error %v; want %q", src, err, want)
+		}
+	}
+
+	if _, err := LoadTheme("themes/light.json"); err != nil {
+		t.Errorf("LoadTheme: %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package highlight
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"analyzer/models"
+)
+
+// Style is how the tokens of a type look. Colors are "#rgb" or "#rrggbb",
+// empty for the default of the terminal or the page.
+type Style struct {
+	Color      string `json:"color,omitempty"`
+	Background string `json:"background,omitempty"`
+	Bold       bool   `json:"bold,omitempty"`
+	Italic     bool   `json:"italic,omitempty"`
+	Underline  bool   `json:"underline,omitempty"`
+}
+
+// Theme gives the token types their styles.
+type Theme struct {
+	// Background and Foreground are the colors of the HTML page, the
+	// terminal keeps its own.
+	Background string `json:"background,omitempty"`
+	Foreground string `json:"foreground,omitempty"`
+
+	// Styles maps token types to their style. Types without one, such as
+	// Identifier by default, are in the foreground color.
+	Styles map[models.TokenType]Style `json:"styles"`
+
+	// Error marks lexical errors: error tokens and tokens a diagnostic
+	// points into. It goes on top of the style of the token.
+	Error Style `json:"error"`
+}
+
+// Default is the theme without a theme file, light text on a dark
+// background.
+var Default = &Theme{
+	Background: "#282c34",
+	Foreground: "#abb2bf",
+	Styles: map[models.TokenType]Style{
+		models.Keyword:          {Color: "#c678dd"},
+		models.IntLiteral:       {Color: "#d19a66"},
+		models.FloatLiteral:     {Color: "#d19a66"},
+		models.ImaginaryLiteral: {Color: "#d19a66"},
+		models.StringLiteral:    {Color: "#98c379"},
+		models.RuneLiteral:      {Color: "#98c379"},
+		models.BooleanLiteral:   {Color: "#d19a66"},
+		models.Operator:         {Color: "#56b6c2"},
+		models.Comment:          {Color: "#7f848e", Italic: true},
+		models.PredeclaredType:  {Color: "#e5c07b"},
+		models.BuiltinFunc:      {Color: "#61afef"},
+		models.Nil:              {Color: "#d19a66"},
+		models.Iota:             {Color: "#d19a66"},
+	},
+	Error: Style{Color: "#e06c75", Underline: true},
+}
+
+// ParseTheme parses a theme in JSON. What the theme leaves out comes from
+// Default, so a theme file only needs the styles it changes; a style in the
+// file replaces that of Default for its type. Unknown fields and malformed
+// colors are errors.
+func ParseTheme(data []byte) (*Theme, error) {
+	t := *Default
+	t.Styles = maps.Clone(Default.Styles)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&t); err != nil {
+		return nil, fmt.Errorf("theme: %v", err)
+	}
+	colors := map[string]string{"background": t.Background, "foreground": t.Foreground}
+	for typ, s := range t.Styles {
+		colors[typ.String()+" color"] = s.Color
+		colors[typ.String()+" background"] = s.Background
+	}
+	colors["error color"] = t.Error.Color
+	colors["error background"] = t.Error.Background
+	for _, what := range slices.Sorted(maps.Keys(colors)) {
+		if c := colors[what]; c != "" && !validColor(c) {
+			return nil, fmt.Errorf("theme: %s: invalid color %q, want #rgb or #rrggbb", what, c)
+		}
+	}
+	return &t, nil
+}
+
+// LoadTheme reads a theme file, see ParseTheme.
+func LoadTheme(path string) (*Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := ParseTheme(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return t, nil
+}
+
+func validColor(c string) bool {
+	_, ok := rgb(c)
+	return ok
+}
+
+// rgb returns the components of a color "#rgb" or "#rrggbb".
+func rgb(c string) ([3]uint8, bool) {
+	hex, ok := strings.CutPrefix(c, "#")
+	if !ok || len(hex) != 3 && len(hex) != 6 {
+		return [3]uint8{}, false
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return [3]uint8{}, false
+	}
+	return [3]uint8{uint8(n >> 16), uint8(n >> 8), uint8(n)}, true
+}
+
+// sgr returns the ANSI escape sequence that switches to the style, empty
+// for the plain style. Colors are 24-bit.
+func (s Style) sgr() string {
+	var params []string
+	if s.Bold {
+		params = append(params, "1")
+	}
+	if s.Italic {
+		params = append(params, "3")
+	}
+	if s.Underline {
+		params = append(params, "4")
+	}
+	if c, ok := rgb(s.Color); ok {
+		params = append(params, fmt.Sprintf("38;2;%d;%d;%d", c[0], c[1], c[2]))
+	}
+	if c, ok := rgb(s.Background); ok {
+		params = append(params, fmt.Sprintf("48;2;%d;%d;%d", c[0], c[1], c[2]))
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// css returns the declarations of the style. Malformed colors are left
+// out.
+func (s Style) css() string {
+	var decls []string
+	if validColor(s.Color) {
+		decls = append(decls, "color: "+s.Color)
+	}
+	if validColor(s.Background) {
+		decls = append(decls, "background-color: "+s.Background)
+	}
+	if s.Bold {
+		decls = append(decls, "font-weight: bold")
+	}
+	if s.Italic {
+		decls = append(decls, "font-style: italic")
+	}
+	if s.Underline {
+		decls = append(decls, "text-decoration: underline")
+	}
+	return strings.Join(decls, "; ")
+}
+
+// over returns the style with the parts top sets replaced.
+func (s Style) over(top Style) Style {
+	if top.Color != "" {
+		s.Color = top.Color
+	}
+	if top.Background != "" {
+		s.Background = top.Background
+	}
+	s.Bold = s.Bold || top.Bold
+	s.Italic = s.Italic || top.Italic
+	s.Underline = s.Underline || top.Underline
+	return s
+}
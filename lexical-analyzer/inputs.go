@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stdinName is the file name of the standard input in output and messages.
+const stdinName = "<stdin>"
+
+// globs is a flag that can be given several times, each time with one or
+// more comma-separated glob patterns.
+type globs []string
+
+func (g *globs) String() string { return strings.Join(*g, ",") }
+
+func (g *globs) Set(s string) error {
+	for _, pattern := range strings.Split(s, ",") {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob %q", pattern)
+		}
+		*g = append(*g, pattern)
+	}
+	return nil
+}
+
+// match reports whether a pattern matches the base name of path or the
+// path relative to the directory it was found in.
+func (g globs) match(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range g {
+		pattern = filepath.ToSlash(pattern)
+		if ok, _ := filepath.Match(pattern, filepath.Base(rel)); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// selection picks the files to process below directories.
+type selection struct {
+	include globs
+	exclude globs
+
+	// fallback decides about files no include glob names
+	fallback func(path string) bool
+}
+
+// addFlags registers -include and -exclude.
+func (s *selection) addFlags(fs *flag.FlagSet) {
+	fs.Var(&s.include, "include", "below directories, process only the files that match `GLOB`, repeatable")
+	fs.Var(&s.exclude, "exclude", "skip files and directories that match `GLOB`, repeatable")
+}
+
+// files returns the files named by paths in order: "-" for stdin, files
+// as they are, whatever their name, and the selected files below
+// directories in lexical order.
+func (s *selection) files(paths []string) ([]string, error) {
+	var files []string
+	for _, root := range paths {
+		if root == "-" {
+			files = append(files, root)
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == root {
+				if !entry.IsDir() {
+					files = append(files, path)
+				}
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			switch {
+			case s.exclude.match(rel) && entry.IsDir():
+				return filepath.SkipDir
+			case s.exclude.match(rel) || entry.IsDir():
+				return nil
+			case len(s.include) > 0 && s.include.match(rel), len(s.include) == 0 && s.fallback(path):
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// readFile reads a file, or stdin for "-". It returns the name to show for
+// the file as well.
+func readFile(path string) (name string, src []byte, err error) {
+	if path == "-" {
+		src, err := io.ReadAll(stdin)
+		return stdinName, src, err
+	}
+	src, err = os.ReadFile(path)
+	return path, src, err
+}
@@ -0,0 +1,157 @@
+// Package language holds the built-in language definitions of the lexers.
+package language
+
+import (
+	"path/filepath"
+	"strings"
+
+	"analyzer/models"
+)
+
+// Go is the language the lexers default to, see
+// https://go.dev/ref/spec#Lexical_elements.
+var Go = &models.Language{
+	Name:       "go",
+	Extensions: []string{".go"},
+	Keywords: []string{
+		"break", "case", "chan", "const", "continue", "default", "defer",
+		"else", "fallthrough", "for", "func", "go", "goto", "if", "import",
+		"interface", "map", "package", "range", "return", "select", "struct",
+		"switch", "type", "var",
+	},
+	Literals: map[string]models.TokenType{
+		"true":  models.BooleanLiteral,
+		"false": models.BooleanLiteral,
+	},
+	Identifiers: models.UnicodeIdentifiers,
+	Predeclared: predeclared(map[models.TokenType][]string{
+		models.PredeclaredType: {
+			"any", "bool", "byte", "comparable", "complex64", "complex128",
+			"error", "float32", "float64", "int", "int8", "int16", "int32",
+			"int64", "rune", "string", "uint", "uint8", "uint16", "uint32",
+			"uint64", "uintptr",
+		},
+		models.BuiltinFunc: {
+			"append", "cap", "clear", "close", "complex", "copy", "delete",
+			"imag", "len", "make", "max", "min", "new", "panic", "print",
+			"println", "real", "recover",
+		},
+		models.Nil:  {"nil"},
+		models.Iota: {"iota"},
+	}),
+	Operators: []string{
+		"+", "-", "*", "/", "%", "&", "|", "^", "<<", ">>", "&^",
+		"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<=", ">>=", "&^=",
+		"&&", "||", "<-", "++", "--", "==", "<", ">", "=", "!", "~",
+		"!=", "<=", ">=", ":=", "...",
+	},
+	Separators:   "()[]{},;:.",
+	Whitespace:   " \t\r",
+	LineComment:  "//",
+	BlockComment: [2]string{"/*", "*/"},
+	Quotes: []models.Quote{
+		{Delim: '`', Type: models.StringLiteral, Raw: true},
+		{Delim: '"', Type: models.StringLiteral},
+		{Delim: '\'', Type: models.RuneLiteral},
+	},
+	Escapes:    models.GoEscapes,
+	Numbers:    models.GoNumbers,
+	Semicolons: true,
+}
+
+// C is C11 with the preprocessor operators. Preprocessor directives are
+// lexed like any other line.
+var C = &models.Language{
+	Name:       "c",
+	Extensions: []string{".c", ".h"},
+	Keywords: []string{
+		"auto", "break", "case", "char", "const", "continue", "default", "do",
+		"double", "else", "enum", "extern", "float", "for", "goto", "if",
+		"inline", "int", "long", "register", "restrict", "return", "short",
+		"signed", "sizeof", "static", "struct", "switch", "typedef", "union",
+		"unsigned", "void", "volatile", "while", "_Alignas", "_Alignof",
+		"_Atomic", "_Bool", "_Complex", "_Generic", "_Imaginary", "_Noreturn",
+		"_Static_assert", "_Thread_local",
+	},
+	Identifiers: models.ASCIIIdentifiers,
+	Operators: []string{
+		"+", "-", "*", "/", "%", "&", "|", "^", "~", "!", "<<", ">>",
+		"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<=", ">>=",
+		"&&", "||", "++", "--", "==", "!=", "<", ">", "<=", ">=", "=",
+		"->", "?", "...", "#", "##",
+	},
+	Separators:   "()[]{},;:.",
+	Whitespace:   " \t\r\f\v",
+	Splice:       true,
+	LineComment:  "//",
+	BlockComment: [2]string{"/*", "*/"},
+	Quotes: []models.Quote{
+		{Delim: '"', Type: models.StringLiteral},
+		{Delim: '\'', Type: models.RuneLiteral},
+	},
+	Escapes: models.CEscapes,
+	Numbers: models.CNumbers,
+}
+
+// JSON is RFC 8259. null is a keyword.
+var JSON = &models.Language{
+	Name:       "json",
+	Extensions: []string{".json"},
+	Keywords:   []string{"null"},
+	Literals: map[string]models.TokenType{
+		"true":  models.BooleanLiteral,
+		"false": models.BooleanLiteral,
+	},
+	Identifiers: models.NoIdentifiers,
+	Separators:  "[]{},:",
+	Whitespace:  " \t\r",
+	Quotes: []models.Quote{
+		{Delim: '"', Type: models.StringLiteral},
+	},
+	Escapes: models.JSONEscapes,
+	Numbers: models.JSONNumbers,
+}
+
+func predeclared(words map[models.TokenType][]string) map[string]models.TokenType {
+	types := make(map[string]models.TokenType)
+	for typ, names := range words {
+		for _, name := range names {
+			types[name] = typ
+		}
+	}
+	return types
+}
+
+// Languages lists the built-in languages.
+var Languages = []*models.Language{Go, C, JSON}
+
+// Lookup returns the built-in language with the given name, or nil.
+func Lookup(name string) *models.Language {
+	for _, lang := range Languages {
+		if strings.EqualFold(lang.Name, name) {
+			return lang
+		}
+	}
+	return nil
+}
+
+// ForFile returns the built-in language of a file by its extension, or nil.
+func ForFile(path string) *models.Language {
+	ext := filepath.Ext(path)
+	for _, lang := range Languages {
+		for _, e := range lang.Extensions {
+			if strings.EqualFold(e, ext) {
+				return lang
+			}
+		}
+	}
+	return nil
+}
+
+// Of returns the language selected by opts, Go by default.
+func Of(opts models.Options) *models.Language {
+	if opts.Language == nil {
+		return Go
+	}
+	return opts.Language
+}
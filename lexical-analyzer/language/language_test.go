@@ -0,0 +1,57 @@
+package language
+
+import (
+	"strings"
+	"testing"
+
+	"analyzer/models"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		want *models.Language
+	}{
+		{"go", Go},
+		{"C", C},
+		{"Json", JSON},
+		{"rust", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := Lookup(tt.name); got != tt.want {
+			t.Errorf("Lookup(%q) = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestForFile(t *testing.T) {
+	tests := []struct {
+		path string
+		want *models.Language
+	}{
+		{"main.go", Go},
+		{"src/lib.c", C},
+		{"include/lib.H", C},
+		{"config.json", JSON},
+		{"README", nil},
+		{"archive.tar.gz", nil},
+	}
+	for _, tt := range tests {
+		if got := ForFile(tt.path); got != tt.want {
+			t.Errorf("ForFile(%q) = %v; want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestOperators checks that the operators and separators of each language
+// don't overlap, which the lexers rely on to classify punctuation.
+func TestOperators(t *testing.T) {
+	for _, lang := range Languages {
+		for _, op := range lang.Operators {
+			if len(op) == 1 && strings.Contains(lang.Separators, op) {
+				t.Errorf("%s: %q is an operator and a separator", lang.Name, op)
+			}
+		}
+	}
+}
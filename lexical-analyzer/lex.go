@@ -0,0 +1,203 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"analyzer/driver"
+	"analyzer/language"
+	"analyzer/models"
+	"analyzer/output"
+)
+
+// lexFlags are the flags of the commands that lex files.
+type lexFlags struct {
+	lexer       string
+	engine      models.Lexer // the lexer named by -lexer, set by check
+	lang        string
+	predeclared bool
+	maxErrors   int
+	workers     int
+	selection
+}
+
+func (f *lexFlags) add(fs *flag.FlagSet) {
+	fs.StringVar(&f.lexer, "lexer", models.DefaultLexer, "the lexer: "+oneOf(models.LexerNames()))
+	fs.StringVar(&f.lang, "lang", "", "language of the files: go, c or json (default by the file extension, else go)")
+	fs.BoolVar(&f.predeclared, "predeclared", false, "give predeclared identifiers such as int and len their own token types")
+	fs.IntVar(&f.maxErrors, "max-errors", 0, "stop lexing a file after `N` errors, 0 for no limit")
+	fs.IntVar(&f.workers, "j", 0, "lex `N` files at once (default the number of CPUs)")
+	f.selection.addFlags(fs)
+}
+
+// check validates the flags after parsing and sets up the selection of
+// files. It returns a message for usage errors.
+func (f *lexFlags) check() string {
+	if f.engine = models.LookupLexer(f.lexer); f.engine == nil {
+		return fmt.Sprintf("unknown lexer %q, want %s", f.lexer, oneOf(models.LexerNames()))
+	}
+	if f.lang != "" && language.Lookup(f.lang) == nil {
+		return fmt.Sprintf("unknown language %q", f.lang)
+	}
+	if f.lang != "" && !models.Knows(f.engine, language.Lookup(f.lang)) {
+		return fmt.Sprintf("the %s lexer doesn't lex %s, only %s", f.lexer, f.lang, languageNames(f.engine.Languages()))
+	}
+	if f.workers < 0 {
+		return "-j must not be negative"
+	}
+	if f.maxErrors < 0 {
+		return "-max-errors must not be negative"
+	}
+	// Below directories, the files of the language or of all languages
+	// the lexer knows are lexed
+	f.fallback = func(path string) bool {
+		switch lang := language.ForFile(path); {
+		case f.lang != "":
+			return lang == language.Lookup(f.lang)
+		default:
+			return lang != nil && models.Knows(f.engine, lang)
+		}
+	}
+	return ""
+}
+
+// files lists the files of paths like selection.files. Files that were
+// named or included and whose extension names a language the lexer doesn't
+// know are an error rather than lexed as another language.
+func (f *lexFlags) files(paths []string) ([]string, error) {
+	files, err := f.selection.files(paths)
+	if err != nil || f.lang != "" {
+		return files, err
+	}
+	for _, file := range files {
+		if lang := language.ForFile(file); lang != nil && !models.Knows(f.engine, lang) {
+			return nil, fmt.Errorf("%s: the %s lexer doesn't lex %s, only %s", file, f.lexer, lang.Name, languageNames(f.engine.Languages()))
+		}
+	}
+	return files, nil
+}
+
+// language returns the language of a file. Files without a known
+// extension are Go, or the first language of a lexer that doesn't know Go.
+func (f *lexFlags) language(name string) *models.Language {
+	if f.lang != "" {
+		return language.Lookup(f.lang)
+	}
+	if lang := language.ForFile(name); lang != nil {
+		return lang
+	}
+	if !models.Knows(f.engine, language.Go) {
+		return f.engine.Languages()[0]
+	}
+	return language.Go
+}
+
+// languageNames lists the names of languages for messages.
+func languageNames(langs []*models.Language) string {
+	var names []string
+	for _, lang := range langs {
+		names = append(names, lang.Name)
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return oneOf(names)
+}
+
+// lex lexes a file with the options of the flags.
+func (f *lexFlags) lex(name string, src []byte, opts models.Options) ([]models.Token, []models.Diagnostic) {
+	opts.Language = f.language(name)
+	opts.Predeclared = f.predeclared
+	opts.MaxErrors = f.maxErrors
+	return f.engine.Lex(string(src), opts)
+}
+
+const lexUsage = "lex [FLAGS] PATH..."
+
+// lexCommand prints the tokens of files. Diagnostics go to stderr.
+func lexCommand(args []string) int {
+	fs := newFlagSet("lex", lexUsage)
+	var f lexFlags
+	f.add(fs)
+	format := fs.String("format", "text", "output `FORMAT`: "+oneOf(formatNames()))
+	trivia := fs.Bool("trivia", false, "emit comments, whitespace and newlines as tokens")
+	semicolons := fs.Bool("semicolons", false, "insert semicolons like the Go spec does (always on for go-scanner)")
+	printSummary := fs.Bool("summary", false, "print the number of files, bytes, tokens and errors to stderr")
+	if code, stop := parseFlags(fs, args); stop {
+		return code
+	}
+	if msg := f.check(); msg != "" {
+		return usageError(fs, "%s", msg)
+	}
+	if fs.NArg() == 0 {
+		return usageError(fs, "no files to lex")
+	}
+	out, err := output.NewWriter(stdout, output.Format(*format))
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	files, err := f.files(fs.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+
+	// Machine-readable formats carry the decoded values and positions, and
+	// go/scanner inserts semicolons
+	opts := models.Options{
+		Trivia:        *trivia,
+		Semicolons:    *semicolons || *format == string(output.GoScanner),
+		Decode:        *format != string(output.Text),
+		OmitPositions: *format == string(output.Text),
+	}
+	ctx, stop := interruptible()
+	defer stop()
+	config := driver.Config{
+		Workers: f.workers,
+		Read:    readFile,
+		Lex: func(name string, src []byte) ([]models.Token, []models.Diagnostic) {
+			return f.lex(name, src, opts)
+		},
+	}
+	summary, err := config.Run(ctx, files, func(file driver.File) error {
+		if file.Err != nil {
+			return file.Err
+		}
+		if err := out.WriteFile(file.Name, file.Tokens); err != nil {
+			return err
+		}
+		for _, d := range file.Diagnostics {
+			fmt.Fprintln(stderr, d.Format(file.Name))
+		}
+		return nil
+	})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if *printSummary {
+		fmt.Fprintln(stderr, formatSummary(summary))
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+	if summary.WithErrors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// formatSummary formats the summary of a run for -summary.
+func formatSummary(s driver.Summary) string {
+	return fmt.Sprintf("%d files, %d bytes, %d tokens, %d errors in %d files, %d warnings, %v",
+		s.Files, s.Bytes, s.Tokens, s.Errors, s.WithErrors, s.Warnings, s.Elapsed.Round(time.Millisecond))
+}
+
+func formatNames() []string {
+	var names []string
+	for _, f := range output.Formats {
+		names = append(names, string(f))
+	}
+	return names
+}
@@ -0,0 +1,9 @@
+package main
+
+// The lexers the commands know. They register themselves, so a new engine
+// only needs an import here.
+import (
+	_ "analyzer/fsmlex"
+	_ "analyzer/genlex"
+	_ "analyzer/rxlex"
+)
@@ -0,0 +1,282 @@
+package lexgen
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DFA is the minimised deterministic automaton of a list of rules. It
+// matches the longest lexeme at the start of the input, ties go to the
+// rule listed first.
+type DFA struct {
+	Rules []Rule
+
+	// The alphabet is split into intervals of runes that no rule tells
+	// apart. bounds holds the first rune of each interval, class the
+	// class of the runes in it.
+	bounds  []rune
+	class   []int
+	ascii   [utf8.RuneSelf]int
+	classes int
+
+	next   []int // next[state*classes+class], -1 is the dead state
+	accept []int // rule accepted in each state, or -1
+}
+
+// Compile builds the DFA for the rules via Thompson construction and the
+// subset construction, and minimises it.
+func Compile(rules []Rule) (*DFA, error) {
+	n, err := newNFA(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DFA{Rules: rules}
+	edgeClasses := d.partition(n)
+
+	start := n.closure([]int{0})
+	for _, i := range start {
+		if r := n.nodes[i].rule; r >= 0 {
+			return nil, fmt.Errorf("rule %d (%s): matches the empty string", r, rules[r].Type)
+		}
+	}
+
+	// Subset construction, states are sets of NFA nodes
+	states := [][]int{start}
+	index := map[string]int{key(start): 0}
+	for s := 0; s < len(states); s++ {
+		rule := -1
+		moves := make([][]int, d.classes)
+		for _, i := range states[s] {
+			nd := n.nodes[i]
+			if nd.rule >= 0 && (rule < 0 || nd.rule < rule) {
+				rule = nd.rule
+			}
+			for j, e := range nd.edges {
+				for _, c := range edgeClasses[i][j] {
+					moves[c] = append(moves[c], e.to)
+				}
+			}
+		}
+		d.accept = append(d.accept, rule)
+
+		row := slices.Repeat([]int{-1}, d.classes)
+		for c, targets := range moves {
+			if targets == nil {
+				continue
+			}
+			set := n.closure(targets)
+			k := key(set)
+			t, ok := index[k]
+			if !ok {
+				t = len(states)
+				index[k] = t
+				states = append(states, set)
+			}
+			row[c] = t
+		}
+		d.next = append(d.next, row...)
+	}
+
+	d.prune()
+	d.minimise()
+	return d, nil
+}
+
+// key identifies a set of NFA nodes.
+func key(set []int) string {
+	set = slices.Clone(set)
+	slices.Sort(set)
+	var b strings.Builder
+	for _, i := range set {
+		b.WriteString(strconv.Itoa(i))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// partition splits the alphabet into classes of runes that no node of the
+// NFA tells apart. It returns the classes each edge covers.
+func (d *DFA) partition(n *nfa) [][][]int {
+	points := []rune{0}
+	for _, nd := range n.nodes {
+		for _, e := range nd.edges {
+			points = append(points, e.lo, e.hi+1)
+		}
+	}
+	slices.Sort(points)
+	points = slices.Compact(points)
+	if points[len(points)-1] > unicode.MaxRune {
+		points = points[:len(points)-1]
+	}
+	d.bounds = points
+
+	// The edges that cover each interval
+	type ref struct{ node, edge int }
+	covers := make([][]ref, len(points))
+	for i, nd := range n.nodes {
+		for j, e := range nd.edges {
+			lo, _ := slices.BinarySearch(points, e.lo)
+			hi, _ := slices.BinarySearch(points, e.hi+1)
+			for k := lo; k < hi; k++ {
+				covers[k] = append(covers[k], ref{i, j})
+			}
+		}
+	}
+
+	edgeClasses := make([][][]int, len(n.nodes))
+	for i, nd := range n.nodes {
+		edgeClasses[i] = make([][]int, len(nd.edges))
+	}
+	// Runes are in the same class if they lead from the same nodes to
+	// the same nodes, whichever edges they take
+	ids := make(map[string]int)
+	d.class = make([]int, len(points))
+	for k, refs := range covers {
+		moves := make([][2]int, len(refs))
+		for i, r := range refs {
+			moves[i] = [2]int{r.node, n.nodes[r.node].edges[r.edge].to}
+		}
+		slices.SortFunc(moves, func(a, b [2]int) int {
+			return cmp.Or(a[0]-b[0], a[1]-b[1])
+		})
+		sig := fmt.Sprint(slices.Compact(moves))
+		c, ok := ids[sig]
+		if !ok {
+			c = len(ids)
+			ids[sig] = c
+			for _, r := range refs {
+				edgeClasses[r.node][r.edge] = append(edgeClasses[r.node][r.edge], c)
+			}
+		}
+		d.class[k] = c
+	}
+	d.classes = len(ids)
+
+	for r := range d.ascii {
+		d.ascii[r] = d.lookup(rune(r))
+	}
+	return edgeClasses
+}
+
+func (d *DFA) lookup(r rune) int {
+	i := sort.Search(len(d.bounds), func(i int) bool { return d.bounds[i] > r }) - 1
+	return d.class[i]
+}
+
+func (d *DFA) classOf(r rune) int {
+	if r < utf8.RuneSelf {
+		return d.ascii[r]
+	}
+	return d.lookup(r)
+}
+
+// prune turns states that can't reach an accepting state into the dead
+// state.
+func (d *DFA) prune() {
+	live := make([]bool, len(d.accept))
+	for changed := true; changed; {
+		changed = false
+		for s := range d.accept {
+			if live[s] {
+				continue
+			}
+			if d.accept[s] >= 0 || slices.ContainsFunc(d.row(s), func(t int) bool { return t >= 0 && live[t] }) {
+				live[s] = true
+				changed = true
+			}
+		}
+	}
+	for i, t := range d.next {
+		if t >= 0 && !live[t] {
+			d.next[i] = -1
+		}
+	}
+}
+
+// minimise merges equivalent states by partition refinement. States start
+// out split by the rule they accept, then by the blocks their transitions
+// lead to, until the partition is stable.
+func (d *DFA) minimise() {
+	n := len(d.accept)
+	block := make([]int, n)
+	count := 0
+	for {
+		ids := make(map[string]int)
+		next := make([]int, n)
+		for s := range n {
+			var b strings.Builder
+			if count == 0 {
+				fmt.Fprint(&b, d.accept[s])
+			} else {
+				fmt.Fprint(&b, block[s])
+			}
+			for _, t := range d.row(s) {
+				if t >= 0 {
+					t = block[t]
+				}
+				fmt.Fprintf(&b, ",%d", t)
+			}
+			id, ok := ids[b.String()]
+			if !ok {
+				id = len(ids)
+				ids[b.String()] = id
+			}
+			next[s] = id
+		}
+		block = next
+		if len(ids) == count {
+			break
+		}
+		count = len(ids)
+	}
+
+	// Blocks are numbered by their first state, so the start stays 0
+	rows := make([]int, count*d.classes)
+	accept := make([]int, count)
+	for s := range n {
+		b := block[s]
+		accept[b] = d.accept[s]
+		for c, t := range d.row(s) {
+			if t >= 0 {
+				t = block[t]
+			}
+			rows[b*d.classes+c] = t
+		}
+	}
+	d.next, d.accept = rows, accept
+}
+
+func (d *DFA) row(s int) []int {
+	return d.next[s*d.classes : (s+1)*d.classes]
+}
+
+// States returns the number of states of the DFA.
+func (d *DFA) States() int {
+	return len(d.accept)
+}
+
+// Match returns the rule of the longest lexeme at the start of input and
+// its size in bytes. The rule is -1 if no lexeme matches.
+func (d *DFA) Match(input string) (rule, size int) {
+	rule = -1
+	state := 0
+	for pos := 0; pos < len(input); {
+		ch, n := utf8.DecodeRuneInString(input[pos:])
+		state = d.next[state*d.classes+d.classOf(ch)]
+		if state < 0 {
+			break
+		}
+		pos += n
+		if r := d.accept[state]; r >= 0 {
+			rule, size = r, pos
+		}
+	}
+	return rule, size
+}
@@ -0,0 +1,254 @@
+package lexgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"analyzer/models"
+)
+
+// Config controls the generated Go source.
+type Config struct {
+	Package string
+
+	// Func names the match function, "match" if empty. Its token types
+	// are in a variable named after it with the suffix Types.
+	Func string
+
+	// Lex adds a function Lex that lexes with the match function, see
+	// the function Lex of this package.
+	Lex bool
+}
+
+// typeNames are the names of the token types declared in models.
+var typeNames = map[models.TokenType]string{
+	models.Keyword:          "Keyword",
+	models.Identifier:       "Identifier",
+	models.IntLiteral:       "IntLiteral",
+	models.FloatLiteral:     "FloatLiteral",
+	models.ImaginaryLiteral: "ImaginaryLiteral",
+	models.StringLiteral:    "StringLiteral",
+	models.RuneLiteral:      "RuneLiteral",
+	models.BooleanLiteral:   "BooleanLiteral",
+	models.Operator:         "Operator",
+	models.Separator:        "Separator",
+	models.Comment:          "Comment",
+	models.Whitespace:       "Whitespace",
+	models.Newline:          "Newline",
+	models.Error:            "Error",
+	models.PredeclaredType:  "PredeclaredType",
+	models.BuiltinFunc:      "BuiltinFunc",
+	models.Nil:              "Nil",
+	models.Iota:             "Iota",
+}
+
+// TypeExpr returns the Go expression for a token type in generated code.
+func TypeExpr(t models.TokenType) string {
+	if name, ok := typeNames[t]; ok {
+		return "models." + name
+	}
+	return fmt.Sprintf("models.NewTokenType(%q)", t)
+}
+
+// span is an interval of runes.
+type span struct{ lo, hi rune }
+
+// Generate writes the DFA as a Go match function, a Matcher that walks the
+// states in a switch like fsmlex does. Large sets of non-ASCII runes become
+// unicode.RangeTables.
+func (d *DFA) Generate(cfg Config) ([]byte, error) {
+	name := cfg.Func
+	if name == "" {
+		name = "match"
+	}
+	g := &generator{name: name, tableIndex: make(map[string]string)}
+
+	fmt.Fprintf(&g.body, "// %sTypes holds the token type of each rule.\n", name)
+	fmt.Fprintf(&g.body, "var %sTypes = [...]models.TokenType{\n", name)
+	for _, rule := range d.Rules {
+		fmt.Fprintf(&g.body, "\t%s,\n", TypeExpr(rule.Type))
+	}
+	g.body.WriteString("}\n\n")
+
+	fmt.Fprintf(&g.body, "// %s returns the rule of the longest lexeme at the start of input and\n", name)
+	g.body.WriteString("// its size in bytes, or -1 if no rule matches.\n")
+	fmt.Fprintf(&g.body, "func %s(input string) (rule, size int) {\n", name)
+	g.body.WriteString("rule = -1\nstate := 0\n")
+	g.body.WriteString("for pos := 0; pos < len(input); {\n")
+	g.body.WriteString("ch, n := utf8.DecodeRuneInString(input[pos:])\n")
+	g.body.WriteString("switch state {\n")
+	for s := range d.States() {
+		g.state(d, s)
+	}
+	g.body.WriteString("}\n")
+	g.body.WriteString("pos += n\n")
+	g.accepts(d)
+	g.body.WriteString("}\nreturn rule, size\n}\n")
+
+	if cfg.Lex {
+		fmt.Fprintf(&g.body, "\n// Lex lexes input with %s, see lexgen.Lex.\n", name)
+		g.body.WriteString("func Lex(input string, opts models.Options) ([]models.Token, []models.Diagnostic) {\n")
+		fmt.Fprintf(&g.body, "return lexgen.Lex(input, opts, %s, %sTypes[:])\n}\n", name, name)
+	}
+	g.body.Write(g.tables.Bytes())
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by lexgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", cfg.Package)
+	if g.tables.Len() > 0 {
+		out.WriteString("\"unicode\"\n")
+	}
+	out.WriteString("\"unicode/utf8\"\n\n")
+	if cfg.Lex {
+		out.WriteString("\"analyzer/lexgen\"\n")
+	}
+	out.WriteString("\"analyzer/models\"\n)\n\n")
+	out.Write(g.body.Bytes())
+	return format.Source(out.Bytes())
+}
+
+type generator struct {
+	name       string
+	body       bytes.Buffer
+	tables     bytes.Buffer
+	tableIndex map[string]string // names of the tables by content
+}
+
+// state writes the transitions out of state s, ordered by their lowest
+// rune so that ASCII is tested first.
+func (g *generator) state(d *DFA, s int) {
+	spans := make(map[int][]span)
+	var targets []int
+	for k, lo := range d.bounds {
+		hi := rune(unicode.MaxRune)
+		if k+1 < len(d.bounds) {
+			hi = d.bounds[k+1] - 1
+		}
+		t := d.next[s*d.classes+d.class[k]]
+		if t < 0 {
+			continue
+		}
+		if _, ok := spans[t]; !ok {
+			targets = append(targets, t)
+		}
+		if list := spans[t]; len(list) > 0 && list[len(list)-1].hi+1 == lo {
+			list[len(list)-1].hi = hi
+		} else {
+			spans[t] = append(list, span{lo, hi})
+		}
+	}
+
+	fmt.Fprintf(&g.body, "case %d:\n", s)
+	if len(targets) == 0 {
+		g.body.WriteString("return rule, size\n")
+		return
+	}
+	g.body.WriteString("switch {\n")
+	for _, t := range targets {
+		fmt.Fprintf(&g.body, "case %s:\nstate = %d\n", g.condition(spans[t]), t)
+	}
+	g.body.WriteString("default:\nreturn rule, size\n}\n")
+}
+
+// condition returns the test for ch being in one of the spans. Spans
+// above ASCII go into a table if there are many of them.
+func (g *generator) condition(spans []span) string {
+	var terms []string
+	var wide []span
+	for _, sp := range spans {
+		if sp.lo >= utf8.RuneSelf && len(spans) > 8 {
+			wide = append(wide, sp)
+			continue
+		}
+		terms = append(terms, spanTest(sp))
+	}
+	if len(wide) > 0 {
+		terms = append(terms, fmt.Sprintf("ch >= utf8.RuneSelf && unicode.Is(%s, ch)", g.table(wide)))
+	}
+	return strings.Join(terms, " || ")
+}
+
+func spanTest(sp span) string {
+	switch {
+	case sp.lo == sp.hi:
+		return "ch == " + runeLit(sp.lo)
+	case sp.hi == unicode.MaxRune:
+		return "ch >= " + runeLit(sp.lo)
+	case sp.lo == 0:
+		return "ch <= " + runeLit(sp.hi)
+	}
+	return fmt.Sprintf("%s <= ch && ch <= %s", runeLit(sp.lo), runeLit(sp.hi))
+}
+
+func runeLit(r rune) string {
+	if r < utf8.RuneSelf && strconv.IsPrint(r) {
+		return strconv.QuoteRune(r)
+	}
+	return fmt.Sprintf("%#x", r)
+}
+
+// table returns the name of a unicode.RangeTable holding the spans.
+func (g *generator) table(spans []span) string {
+	var b strings.Builder
+	for _, sp := range spans {
+		fmt.Fprintf(&b, "%#x, %#x, 1,\n", sp.lo, sp.hi)
+	}
+	if name, ok := g.tableIndex[b.String()]; ok {
+		return name
+	}
+	name := fmt.Sprintf("%sTable%d", g.name, len(g.tableIndex))
+	g.tableIndex[b.String()] = name
+
+	var r16, r32 strings.Builder
+	for _, sp := range spans {
+		if sp.lo <= 0xFFFF && sp.hi > 0xFFFF {
+			fmt.Fprintf(&r16, "{%#x, 0xffff, 1},\n", sp.lo)
+			sp.lo = 0x10000
+		}
+		if sp.hi <= 0xFFFF {
+			fmt.Fprintf(&r16, "{%#x, %#x, 1},\n", sp.lo, sp.hi)
+		} else {
+			fmt.Fprintf(&r32, "{%#x, %#x, 1},\n", sp.lo, sp.hi)
+		}
+	}
+	fmt.Fprintf(&g.tables, "\nvar %s = &unicode.RangeTable{\n", name)
+	if r16.Len() > 0 {
+		fmt.Fprintf(&g.tables, "R16: []unicode.Range16{\n%s},\n", r16.String())
+	}
+	if r32.Len() > 0 {
+		fmt.Fprintf(&g.tables, "R32: []unicode.Range32{\n%s},\n", r32.String())
+	}
+	g.tables.WriteString("}\n")
+	return name
+}
+
+// accepts writes the switch that records the rule accepted in each state.
+func (g *generator) accepts(d *DFA) {
+	states := make(map[int][]string)
+	var rules []int
+	for s, r := range d.accept {
+		if r < 0 {
+			continue
+		}
+		if _, ok := states[r]; !ok {
+			rules = append(rules, r)
+		}
+		states[r] = append(states[r], strconv.Itoa(s))
+	}
+	if len(rules) == 0 {
+		return
+	}
+	slices.Sort(rules)
+
+	g.body.WriteString("switch state {\n")
+	for _, r := range rules {
+		fmt.Fprintf(&g.body, "case %s:\nrule, size = %d, pos\n", strings.Join(states[r], ", "), r)
+	}
+	g.body.WriteString("}\n")
+}
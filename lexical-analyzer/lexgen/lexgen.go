@@ -0,0 +1,133 @@
+// Package lexgen generates lexers from an ordered list of rules. Each rule
+// pairs a token type with a regular expression. The rules are compiled via
+// a Thompson NFA and the subset construction into a minimised DFA, which
+// either lexes at runtime from its tables or is written out as Go code.
+package lexgen
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"analyzer/literal"
+	"analyzer/models"
+)
+
+// Rule is a token type with the pattern of its lexemes. Patterns use the
+// syntax of the regexp package, except for anchors and word boundaries.
+type Rule struct {
+	Type    models.TokenType
+	Pattern string
+}
+
+// Matcher returns the rule of the longest lexeme at the start of input and
+// its size in bytes, or -1 if no rule matches. Both DFA.Match and generated
+// match functions are Matchers.
+type Matcher func(input string) (rule, size int)
+
+// Step matches the lexeme at the start of input. It returns its size in
+// bytes, or -1 if no rule matches, the token type of the rule and whether
+// the lexeme is dropped. A Step may keep state between lexemes, such as the
+// start condition of a lexer with several.
+type Step func(input string) (size int, typ models.TokenType, skip bool)
+
+// Lex lexes the whole input with match, see LexSteps.
+func Lex(input string, opts models.Options, match Matcher, types []models.TokenType) ([]models.Token, []models.Diagnostic) {
+	return LexSteps(input, opts, func(input string) (int, models.TokenType, bool) {
+		rule, size := match(input)
+		if rule < 0 {
+			return -1, models.Error, false
+		}
+		return size, types[rule], false
+	})
+}
+
+// LexSteps lexes the whole input with step. Lexemes of trivia types are
+// only kept with Options.Trivia, and with Options.Semicolons semicolons are
+// inserted after the rule of Go at trivia or dropped lexemes holding a
+// newline. Rules of type models.Error report their lexemes as errors.
+// Where no rule matches, a single character becomes an Error token.
+func LexSteps(input string, opts models.Options, step Step) ([]models.Token, []models.Diagnostic) {
+	var tokens []models.Token
+	var diags []models.Diagnostic
+	table := models.NewPosTable(input)
+	insertSemi := false
+
+	// position is the position of a token at offset, if tokens have them
+	position := func(offset int) models.Position {
+		if opts.OmitPositions {
+			return models.Position{}
+		}
+		return table.Position(offset)
+	}
+
+	for pos := 0; pos < len(input); {
+		if opts.MaxErrors > 0 && len(diags) >= opts.MaxErrors {
+			diags = append(diags, models.TooManyErrors(table.Position(pos)))
+			return tokens, diags
+		}
+
+		size, typ, skip := step(input[pos:])
+		matched := size >= 0
+		if !matched {
+			r, n := utf8.DecodeRuneInString(input[pos:])
+			size, typ, skip = n, models.Error, false
+			if r == utf8.RuneError && n == 1 {
+				diags = append(diags, diagnostic(table, pos, n, models.ErrInvalidEncoding, "invalid UTF-8 encoding"))
+			} else {
+				diags = append(diags, diagnostic(table, pos, n, models.ErrIllegalCharacter, fmt.Sprintf("illegal character %#U", r)))
+			}
+		}
+
+		value := input[pos : pos+size]
+		if matched && typ == models.Error {
+			diags = append(diags, diagnostic(table, pos, size, models.ErrIllegalCharacter, fmt.Sprintf("invalid token %q", value)))
+		}
+		if nl := strings.IndexByte(value, '\n'); opts.Semicolons && insertSemi && nl >= 0 && (skip || typ.IsTrivia()) {
+			tokens = append(tokens, models.Semicolon(position(pos+nl)))
+			insertSemi = false
+		}
+		if !skip && (opts.Trivia || !typ.IsTrivia()) {
+			token := models.Token{Type: typ, Value: value, Pos: position(pos), End: position(pos + size)}
+			if opts.Decode && typ != models.Error {
+				token.Decoded = literal.Decode(typ, value)
+				if err := literal.Undecoded(typ, value); err != nil {
+					diags = append(diags, err.Diagnostic(table.Position(pos), table.Position(pos+size)))
+				}
+			}
+			tokens = append(tokens, token)
+			if !typ.IsTrivia() {
+				insertSemi = models.InsertsSemicolon(token)
+			}
+		}
+		pos += size
+	}
+
+	if opts.Semicolons && insertSemi {
+		tokens = append(tokens, models.Semicolon(position(len(input))))
+	}
+	return tokens, diags
+}
+
+func diagnostic(table *models.PosTable, pos, size int, code models.Code, msg string) models.Diagnostic {
+	return models.Diagnostic{
+		Severity: models.SeverityError,
+		Code:     code,
+		Pos:      table.Position(pos),
+		End:      table.Position(pos + size),
+		Message:  msg,
+	}
+}
+
+// Lex lexes input with the tables of the DFA, see the function Lex.
+func (d *DFA) Lex(input string, opts models.Options) ([]models.Token, []models.Diagnostic) {
+	return Lex(input, opts, d.Match, d.types())
+}
+
+func (d *DFA) types() []models.TokenType {
+	types := make([]models.TokenType, len(d.Rules))
+	for i, rule := range d.Rules {
+		types[i] = rule.Type
+	}
+	return types
+}
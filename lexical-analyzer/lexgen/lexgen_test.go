@@ -0,0 +1,156 @@
+package lexgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"analyzer/models"
+)
+
+var testRules = []Rule{
+	{models.Whitespace, `[ \t\n]+`},
+	{models.Keyword, `if|else`},
+	{models.Identifier, `[\pL_][\pL\pN_]*`},
+	{models.IntLiteral, `[0-9]+`},
+	{models.FloatLiteral, `[0-9]+\.[0-9]*`},
+	{models.Operator, `=|==|\+`},
+}
+
+func TestMatch(t *testing.T) {
+	d, err := Compile(testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input string
+		rule  int
+		size  int
+	}{
+		{"if x", 1, 2},    // keyword listed before identifier
+		{"iffy", 2, 4},    // longest match wins over the keyword
+		{"élan+", 2, 5},   // non-ASCII letters
+		{"12.5+", 4, 4},   // longest match over the int
+		{"12+", 3, 2},     // back off to the last accepting state
+		{"===", 5, 2},     // longest operator
+		{"  \n\tx", 0, 4}, // whitespace
+		{"@", -1, 0},      // no rule
+		{"", -1, 0},       // nothing to match
+		{"x\xffy", 2, 1},  // stops at invalid UTF-8
+	}
+	for _, tt := range tests {
+		rule, size := d.Match(tt.input)
+		if rule != tt.rule || size != tt.size {
+			t.Errorf("Match(%q) = %d, %d; want %d, %d", tt.input, rule, size, tt.rule, tt.size)
+		}
+	}
+}
+
+func TestMinimise(t *testing.T) {
+	tests := []struct {
+		pattern string
+		states  int
+	}{
+		{`(a|b)*abb`, 4},
+		{`a+|aa+`, 2},
+		{`ab|cb`, 3},
+		{`(?i)if`, 3},
+	}
+	for _, tt := range tests {
+		d, err := Compile([]Rule{{models.Identifier, tt.pattern}})
+		if err != nil {
+			t.Errorf("Compile(%q) error: %v", tt.pattern, err)
+			continue
+		}
+		if d.States() != tt.states {
+			t.Errorf("Compile(%q) has %d states; want %d", tt.pattern, d.States(), tt.states)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		err     string
+	}{
+		{`a*`, "matches the empty string"},
+		{`^a`, "unsupported"},
+		{`a\b`, "unsupported"},
+		{`a(`, "missing closing )"},
+	}
+	for _, tt := range tests {
+		_, err := Compile([]Rule{{models.Identifier, "x"}, {models.Operator, tt.pattern}})
+		if err == nil || !strings.Contains(err.Error(), tt.err) || !strings.HasPrefix(err.Error(), "rule 1 (Operator)") {
+			t.Errorf("Compile(%q) error = %v; want rule 1 and %q", tt.pattern, err, tt.err)
+		}
+	}
+}
+
+func TestLex(t *testing.T) {
+	d, err := Compile(append(testRules, Rule{models.Error, `\.[0-9]+`}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, diags := d.Lex("if x== 1.5 @ .5\xff", models.Options{})
+	var got []string
+	for _, tok := range tokens {
+		got = append(got, tok.Type.String()+" "+tok.Value)
+	}
+	want := []string{"Keyword if", "Identifier x", "Operator ==", "Float 1.5", "ERROR @", "ERROR .5", "ERROR \xff"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Lex tokens = %q; want %q", got, want)
+	}
+	codes := []models.Code{models.ErrIllegalCharacter, models.ErrIllegalCharacter, models.ErrInvalidEncoding}
+	if len(diags) != len(codes) {
+		t.Fatalf("Lex returned %d diagnostics; want %d: %v", len(diags), len(codes), diags)
+	}
+	for i, code := range codes {
+		if diags[i].Code != code {
+			t.Errorf("diagnostic %d = %v; want code %s", i, diags[i], code)
+		}
+	}
+
+	tokens, _ = d.Lex("a b", models.Options{Trivia: true})
+	if len(tokens) != 3 || tokens[1].Type != models.Whitespace {
+		t.Errorf("Lex with trivia = %v; want whitespace between a and b", tokens)
+	}
+
+	// Semicolons go at the newline of the whitespace after an identifier
+	tokens, _ = d.Lex("a =\nb\n", models.Options{Semicolons: true})
+	got = got[:0]
+	for _, tok := range tokens {
+		got = append(got, tok.Value+"@"+fmt.Sprint(tok.Pos.Offset))
+	}
+	if want := "a@0 =@2 b@4 ;@5"; strings.Join(got, " ") != want {
+		t.Errorf("Lex with semicolons = %q; want %q", got, want)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	d, err := Compile(testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := d.Generate(Config{Package: "gen", Func: "scan", Lex: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err = Compile(testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := d.Generate(Config{Package: "gen", Func: "scan", Lex: true}); !bytes.Equal(src, again) {
+		t.Error("Generate output differs between compilations")
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "scan.go", src, 0); err != nil {
+		t.Fatalf("generated source doesn't parse: %v\n%s", err, src)
+	}
+	for _, want := range []string{"func scan(input string) (rule, size int)", "var scanTypes", "func Lex(", "unicode.Is(scanTable0, ch)"} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source lacks %q:\n%s", want, src)
+		}
+	}
+}
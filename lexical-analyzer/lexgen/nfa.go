@@ -0,0 +1,173 @@
+package lexgen
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"unicode"
+)
+
+// edge is a transition on the runes lo to hi.
+type edge struct {
+	lo, hi rune
+	to     int
+}
+
+type node struct {
+	eps   []int
+	edges []edge
+	rule  int // index of the rule accepted here, or -1
+}
+
+// nfa is a Thompson automaton for all rules. Node 0 is the start, it has
+// an epsilon transition to the start of each rule.
+type nfa struct {
+	nodes []node
+}
+
+func newNFA(rules []Rule) (*nfa, error) {
+	n := &nfa{}
+	start := n.add()
+	for i, rule := range rules {
+		re, err := syntax.Parse(rule.Pattern, syntax.Perl)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, rule.Type, err)
+		}
+		s, e, err := n.build(re.Simplify())
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, rule.Type, err)
+		}
+		n.nodes[e].rule = i
+		n.nodes[start].eps = append(n.nodes[start].eps, s)
+	}
+	return n, nil
+}
+
+func (n *nfa) add() int {
+	n.nodes = append(n.nodes, node{rule: -1})
+	return len(n.nodes) - 1
+}
+
+func (n *nfa) epsilon(from, to int) {
+	n.nodes[from].eps = append(n.nodes[from].eps, to)
+}
+
+// build adds the fragment for re and returns its start and end node.
+// Greediness doesn't matter, the lexer always takes the longest match.
+func (n *nfa) build(re *syntax.Regexp) (start, end int, err error) {
+	start, end = n.add(), n.add()
+
+	switch re.Op {
+	case syntax.OpNoMatch:
+		// No way from start to end
+
+	case syntax.OpEmptyMatch:
+		n.epsilon(start, end)
+
+	case syntax.OpLiteral:
+		at := start
+		for i, r := range re.Rune {
+			to := end
+			if i < len(re.Rune)-1 {
+				to = n.add()
+			}
+			for _, f := range fold(r, re.Flags&syntax.FoldCase != 0) {
+				n.nodes[at].edges = append(n.nodes[at].edges, edge{f, f, to})
+			}
+			at = to
+		}
+		if len(re.Rune) == 0 {
+			n.epsilon(start, end)
+		}
+
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			n.nodes[start].edges = append(n.nodes[start].edges, edge{re.Rune[i], re.Rune[i+1], end})
+		}
+
+	case syntax.OpAnyCharNotNL:
+		n.nodes[start].edges = append(n.nodes[start].edges,
+			edge{0, '\n' - 1, end}, edge{'\n' + 1, unicode.MaxRune, end})
+
+	case syntax.OpAnyChar:
+		n.nodes[start].edges = append(n.nodes[start].edges, edge{0, unicode.MaxRune, end})
+
+	case syntax.OpCapture:
+		s, e, err := n.build(re.Sub[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		n.epsilon(start, s)
+		n.epsilon(e, end)
+
+	case syntax.OpConcat:
+		at := start
+		for _, sub := range re.Sub {
+			s, e, err := n.build(sub)
+			if err != nil {
+				return 0, 0, err
+			}
+			n.epsilon(at, s)
+			at = e
+		}
+		n.epsilon(at, end)
+
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			s, e, err := n.build(sub)
+			if err != nil {
+				return 0, 0, err
+			}
+			n.epsilon(start, s)
+			n.epsilon(e, end)
+		}
+
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest:
+		s, e, err := n.build(re.Sub[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		n.epsilon(start, s)
+		n.epsilon(e, end)
+		if re.Op != syntax.OpPlus {
+			n.epsilon(start, end)
+		}
+		if re.Op != syntax.OpQuest {
+			n.epsilon(e, s)
+		}
+
+	default:
+		// Anchors and word boundaries need context a lexeme doesn't have
+		return 0, 0, fmt.Errorf("unsupported %s", re)
+	}
+	return start, end, nil
+}
+
+// fold returns r and, if foldCase is set, the runes equal to it under
+// simple case folding.
+func fold(r rune, foldCase bool) []rune {
+	runes := []rune{r}
+	if foldCase {
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			runes = append(runes, f)
+		}
+	}
+	return runes
+}
+
+// closure adds the nodes reachable from set by epsilon transitions.
+func (n *nfa) closure(set []int) []int {
+	seen := make(map[int]bool, len(set))
+	stack := append([]int(nil), set...)
+	var out []int
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[i] {
+			continue
+		}
+		seen[i] = true
+		out = append(out, i)
+		stack = append(stack, n.nodes[i].eps...)
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package literal
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"analyzer/language"
+	"analyzer/models"
+)
+
+// FloatPrec is the mantissa precision of decoded floating-point values.
+const FloatPrec = 512
+
+// MaxExp bounds the decimal exponents of decoded floats. Parsing a decimal
+// float takes time that grows with its exponent, minutes for 1e100000000.
+const MaxExp = 10000
+
+// Decode returns the value of a valid literal of the given type: *big.Int
+// for integers, *big.Float for floats and for the imaginary part of
+// imaginary literals, string for strings, rune for runes and bool for
+// booleans. It returns nil for other tokens, for invalid literals and for
+// decimal floats with an exponent beyond ±MaxExp, see Undecoded.
+func Decode(typ models.TokenType, lit string) any {
+	return DecodeIn(language.Go, typ, lit)
+}
+
+// DecodeIn is like Decode for the literals of lang. Suffixes of C numbers
+// don't change the value.
+func DecodeIn(lang *models.Language, typ models.TokenType, lit string) any {
+	if lang.Numbers == models.CNumbers {
+		switch typ {
+		case models.IntLiteral:
+			lit = strings.TrimRight(lit, "uUlL")
+		case models.FloatLiteral:
+			lit = strings.TrimRight(lit, "fFlL")
+		}
+	}
+
+	switch typ {
+	case models.IntLiteral:
+		if x, ok := new(big.Int).SetString(lit, 0); ok {
+			return x
+		}
+	case models.FloatLiteral, models.ImaginaryLiteral:
+		if decimalExp(lit) > MaxExp {
+			return nil
+		}
+		// Leading zeros are decimal here, as the spec requires for
+		// imaginary literals
+		x, _, err := new(big.Float).SetPrec(FloatPrec).Parse(strings.TrimSuffix(lit, "i"), 0)
+		if err == nil {
+			return x
+		}
+	case models.StringLiteral:
+		var buf strings.Builder
+		if _, err := unquote(lit, lang.Escapes, &buf); err == nil {
+			return buf.String()
+		}
+	case models.RuneLiteral:
+		if r, err := unquote(lit, lang.Escapes, nil); err == nil {
+			return r
+		}
+	case models.BooleanLiteral:
+		return lit == "true"
+	}
+	return nil
+}
+
+// Undecoded returns a warning for a valid literal that Decode leaves
+// without a value: a decimal float with an exponent beyond ±MaxExp. It
+// returns nil for all other literals.
+func Undecoded(typ models.TokenType, lit string) *Error {
+	if typ != models.FloatLiteral && typ != models.ImaginaryLiteral || decimalExp(lit) <= MaxExp {
+		return nil
+	}
+	return &Error{
+		Severity: models.SeverityWarning,
+		Code:     models.ErrUndecoded,
+		Offset:   strings.IndexAny(lit, "eE"),
+		Message:  fmt.Sprintf("exponent beyond ±%d, the value is not decoded", MaxExp),
+	}
+}
+
+// decimalExp returns the magnitude of the exponent of a decimal float
+// literal, 0 for hexadecimal floats, which parse fast whatever their
+// exponent.
+func decimalExp(lit string) int {
+	if strings.HasPrefix(lit, "0x") || strings.HasPrefix(lit, "0X") {
+		return 0
+	}
+	i := strings.IndexAny(lit, "eE")
+	if i < 0 {
+		return 0
+	}
+	exp := strings.ReplaceAll(strings.TrimRight(lit[i+1:], "i"), "_", "")
+	n, err := strconv.Atoi(strings.TrimLeft(exp, "+-"))
+	if err != nil {
+		return math.MaxInt
+	}
+	return n
+}
@@ -0,0 +1,32 @@
+package literal
+
+import "analyzer/models"
+
+// Error describes the first problem found in a literal.
+type Error struct {
+	Severity models.Severity
+	Code     models.Code
+	Offset   int // byte offset of the problem within the literal
+	Message  string
+	Hint     string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Diagnostic turns the error into a diagnostic for the literal between pos
+// and end. Literals with errors never span lines, so the problem is on the
+// line of pos.
+func (e *Error) Diagnostic(pos, end models.Position) models.Diagnostic {
+	pos.Offset += e.Offset
+	pos.Column += e.Offset
+	return models.Diagnostic{
+		Severity: e.Severity,
+		Code:     e.Code,
+		Pos:      pos,
+		End:      end,
+		Message:  e.Message,
+		Hint:     e.Hint,
+	}
+}
@@ -0,0 +1,125 @@
+package literal
+
+import (
+	"math/big"
+	"testing"
+
+	"analyzer/language"
+	"analyzer/models"
+)
+
+func TestNumberIn(t *testing.T) {
+	tests := []struct {
+		lang   *models.Language
+		lit    string
+		typ    models.TokenType
+		offset int // of the error, -1 for valid literals
+	}{
+		{language.C, "42", models.IntLiteral, -1},
+		{language.C, "017", models.IntLiteral, -1},
+		{language.C, "0x1Fu", models.IntLiteral, -1},
+		{language.C, "0b101", models.IntLiteral, -1},
+		{language.C, "10ULL", models.IntLiteral, -1},
+		{language.C, "1.5f", models.FloatLiteral, -1},
+		{language.C, ".5", models.FloatLiteral, -1},
+		{language.C, "1e-3L", models.FloatLiteral, -1},
+		{language.C, "0x1.8p1", models.FloatLiteral, -1},
+		{language.C, "09.5", models.FloatLiteral, -1},
+		{language.C, "08", models.IntLiteral, 1},
+		{language.C, "0x", models.IntLiteral, 2},
+		{language.C, "0b12", models.IntLiteral, 3},
+		{language.C, "1e", models.FloatLiteral, 2},
+		{language.C, "0x1.8", models.FloatLiteral, 5},
+		{language.C, "10lul", models.IntLiteral, 2},
+		{language.C, "1.5u", models.FloatLiteral, 3},
+		{language.C, "1_000", models.IntLiteral, 1},
+
+		{language.JSON, "0", models.IntLiteral, -1},
+		{language.JSON, "-12", models.IntLiteral, -1},
+		{language.JSON, "1.25", models.FloatLiteral, -1},
+		{language.JSON, "-0.5e+10", models.FloatLiteral, -1},
+		{language.JSON, "1E3", models.FloatLiteral, -1},
+		{language.JSON, "01", models.IntLiteral, 1},
+		{language.JSON, "1.", models.FloatLiteral, 2},
+		{language.JSON, "1e", models.FloatLiteral, 2},
+		{language.JSON, "0x1", models.IntLiteral, 1},
+		{language.JSON, "1_0", models.IntLiteral, 1},
+	}
+
+	for _, tt := range tests {
+		typ, err := NumberIn(tt.lang, tt.lit)
+		if typ != tt.typ {
+			t.Errorf("NumberIn(%s, %q) type = %s; want %s", tt.lang.Name, tt.lit, typ, tt.typ)
+		}
+		switch {
+		case err == nil && tt.offset >= 0:
+			t.Errorf("NumberIn(%s, %q) = no error; want one at %d", tt.lang.Name, tt.lit, tt.offset)
+		case err != nil && err.Offset != tt.offset:
+			t.Errorf("NumberIn(%s, %q) error = %v at %d; want offset %d", tt.lang.Name, tt.lit, err, err.Offset, tt.offset)
+		}
+	}
+}
+
+func TestQuotedIn(t *testing.T) {
+	tests := []struct {
+		lang   *models.Language
+		lit    string
+		want   any // decoded value, nil if the literal is invalid
+		offset int // of the error
+	}{
+		{language.C, `"a\tb\?"`, "a\tb?", 0},
+		{language.C, `"\0\101\x41"`, "\x00AA", 0},
+		{language.C, `"\x00041"`, "A", 0},
+		{language.C, `'\''`, '\'', 0},
+		{language.C, `'é'`, 'é', 0},
+		{language.C, `"\400"`, nil, 2},
+		{language.C, `"\x100"`, nil, 2},
+		{language.C, `"\q"`, nil, 2},
+		{language.C, `"\x"`, nil, 3},
+
+		{language.JSON, `"a\/bé"`, "a/bé", 0},
+		{language.JSON, `"😀"`, "😀", 0},
+		{language.JSON, `"\ud83d"`, "�", 0},
+		{language.JSON, `"\x41"`, nil, 2},
+		{language.JSON, `"\u12"`, nil, 5},
+		{language.JSON, "\"a\x01\"", nil, 2},
+		{language.JSON, "\"a\tb\"", "a\tb", 0},
+	}
+
+	for _, tt := range tests {
+		typ := models.StringLiteral
+		if tt.lit[0] == '\'' {
+			typ = models.RuneLiteral
+		}
+		err := QuotedIn(tt.lang, tt.lit)
+		got := DecodeIn(tt.lang, typ, tt.lit)
+		if got != tt.want {
+			t.Errorf("DecodeIn(%s, %q) = %q; want %q", tt.lang.Name, tt.lit, got, tt.want)
+		}
+		switch {
+		case tt.want == nil && err == nil:
+			t.Errorf("QuotedIn(%s, %q) = no error; want one at %d", tt.lang.Name, tt.lit, tt.offset)
+		case tt.want != nil && err != nil:
+			t.Errorf("QuotedIn(%s, %q) = %v; want no error", tt.lang.Name, tt.lit, err)
+		case err != nil && err.Offset != tt.offset:
+			t.Errorf("QuotedIn(%s, %q) error offset = %d; want %d", tt.lang.Name, tt.lit, err.Offset, tt.offset)
+		}
+	}
+}
+
+func TestDecodeInSuffixes(t *testing.T) {
+	ints := map[string]int64{"10UL": 10, "0x1Fu": 31, "017L": 15, "0b11ll": 3}
+	for lit, want := range ints {
+		x, ok := DecodeIn(language.C, models.IntLiteral, lit).(*big.Int)
+		if !ok || x.Int64() != want {
+			t.Errorf("DecodeIn(c, %q) = %v; want %d", lit, x, want)
+		}
+	}
+	floats := map[string]float64{"1.5f": 1.5, "1e2L": 100, "0x1p-2F": 0.25}
+	for lit, want := range floats {
+		x, ok := DecodeIn(language.C, models.FloatLiteral, lit).(*big.Float)
+		if f, _ := x.Float64(); !ok || f != want {
+			t.Errorf("DecodeIn(c, %q) = %v; want %g", lit, x, want)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package literal
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"analyzer/models"
+)
+
+// Number checks a numeric literal against the Go spec and returns its token
+// type. The literal must span exactly what the lexers scan as one number:
+// mantissa with an optional base prefix, fraction, exponent and 'i' suffix.
+// A non-nil error describes the first problem in the literal.
+func Number(lit string) (models.TokenType, *Error) {
+	typ := models.IntLiteral
+	var err *Error
+	fail := func(offset int, msg, hint string) {
+		// The problem that comes first in the literal wins
+		if err == nil || offset < err.Offset {
+			err = &Error{Code: models.ErrInvalidNumber, Offset: offset, Message: msg, Hint: hint}
+		}
+	}
+
+	at := func(i int) byte {
+		if i < len(lit) {
+			return lit[i]
+		}
+		return 0
+	}
+
+	i := 0
+	base, prefix := 10, byte(0)
+	digsep := 0 // bit 0: digit present, bit 1: '_' present
+	invalid := -1
+
+	// Integer part
+	if at(0) != '.' {
+		if at(0) == '0' {
+			i++
+			switch lower(at(i)) {
+			case 'x':
+				i++
+				base, prefix = 16, 'x'
+			case 'o':
+				i++
+				base, prefix = 8, 'o'
+			case 'b':
+				i++
+				base, prefix = 2, 'b'
+			default:
+				base, prefix = 8, '0'
+				digsep = 1 // leading 0
+			}
+		}
+		i = digits(lit, i, base, &invalid, &digsep)
+	}
+
+	// Fractional part
+	if at(i) == '.' {
+		typ = models.FloatLiteral
+		if prefix == 'o' || prefix == 'b' {
+			fail(i, "invalid radix point in "+litname(prefix), "")
+		}
+		i = digits(lit, i+1, base, &invalid, &digsep)
+	}
+
+	if digsep&1 == 0 {
+		fail(i, litname(prefix)+" has no digits", "")
+	}
+
+	// Exponent
+	if e := lower(at(i)); e == 'e' || e == 'p' {
+		switch {
+		case e == 'e' && prefix != 0 && prefix != '0':
+			fail(i, fmt.Sprintf("'%c' exponent requires decimal mantissa", at(i)), "use a 'p' exponent")
+		case e == 'p' && prefix != 'x':
+			fail(i, fmt.Sprintf("'%c' exponent requires hexadecimal mantissa", at(i)), "use an 'e' exponent")
+		}
+		i++
+		typ = models.FloatLiteral
+		if at(i) == '+' || at(i) == '-' {
+			i++
+		}
+		ds := 0
+		j := digits(lit, i, 10, nil, &ds)
+		digsep |= ds
+		if ds&1 == 0 {
+			fail(i, "exponent has no digits", "")
+		}
+		i = j
+	} else if prefix == 'x' && typ == models.FloatLiteral {
+		fail(i, "hexadecimal mantissa requires a 'p' exponent", "add an exponent such as p0")
+	}
+
+	// Imaginary suffix
+	if at(i) == 'i' {
+		typ = models.ImaginaryLiteral
+		i++
+	}
+
+	if i != len(lit) {
+		fail(i, fmt.Sprintf("invalid character %q in number", lit[i:i+1]), "")
+	}
+	if typ == models.IntLiteral && invalid >= 0 {
+		hint := ""
+		if prefix == '0' {
+			hint = "remove the leading zero for a decimal literal"
+		}
+		fail(invalid, fmt.Sprintf("invalid digit %q in %s", lit[invalid], litname(prefix)), hint)
+	}
+	if digsep&2 != 0 {
+		if sep := invalidSep(lit); sep >= 0 {
+			fail(sep, "'_' must separate successive digits", "")
+		}
+	}
+
+	return typ, err
+}
+
+// NumberIn is like Number for the numeric literals of lang. For C and JSON,
+// the literal spans what a C preprocessor scans as one number: a digit or a
+// dot and a digit, followed by letters, digits, dots and signs after an
+// exponent letter.
+func NumberIn(lang *models.Language, lit string) (models.TokenType, *Error) {
+	switch lang.Numbers {
+	case models.CNumbers:
+		return cNumber(lit)
+	case models.JSONNumbers:
+		return jsonNumber(lit)
+	}
+	return Number(lit)
+}
+
+// cNumber checks a numeric literal of C, including its suffix.
+func cNumber(lit string) (models.TokenType, *Error) {
+	typ := models.IntLiteral
+	fail := func(offset int, msg, hint string) (models.TokenType, *Error) {
+		return typ, &Error{Code: models.ErrInvalidNumber, Offset: offset, Message: msg, Hint: hint}
+	}
+	at := func(i int) byte {
+		if i < len(lit) {
+			return lit[i]
+		}
+		return 0
+	}
+
+	i := 0
+	prefix := byte(0)
+	if at(0) == '0' && (lower(at(1)) == 'x' || lower(at(1)) == 'b') {
+		prefix = lower(at(1))
+		i = 2
+	} else if at(0) == '0' {
+		prefix = '0'
+	}
+	isDigit := isDecimal
+	if prefix == 'x' {
+		isDigit = isHex
+	}
+
+	count, invalid := 0, -1
+	scan := func() {
+		for ; isDigit(at(i)); i++ {
+			count++
+			if prefix == 'b' && at(i) > '1' && invalid < 0 {
+				invalid = i
+			}
+		}
+	}
+	scan()
+	if at(i) == '.' {
+		typ = models.FloatLiteral
+		if prefix == 'b' {
+			return fail(i, "invalid radix point in binary literal", "")
+		}
+		i++
+		scan()
+	}
+	if count == 0 {
+		return fail(i, litname(prefix)+" has no digits", "")
+	}
+
+	if e := lower(at(i)); e == 'p' && prefix == 'x' || e == 'e' && prefix != 'x' && prefix != 'b' {
+		typ = models.FloatLiteral
+		i++
+		if at(i) == '+' || at(i) == '-' {
+			i++
+		}
+		start := i
+		for isDecimal(at(i)) {
+			i++
+		}
+		if i == start {
+			return fail(i, "exponent has no digits", "")
+		}
+	} else if prefix == 'x' && typ == models.FloatLiteral {
+		return fail(i, "hexadecimal mantissa requires a 'p' exponent", "add an exponent such as p0")
+	}
+
+	if typ == models.IntLiteral && prefix == '0' {
+		// Octal, unless it is a float such as 09.5
+		if j := strings.IndexAny(lit[:i], "89"); j >= 0 {
+			return fail(j, fmt.Sprintf("invalid digit %q in octal literal", lit[j]), "remove the leading zero for a decimal literal")
+		}
+	}
+	if invalid >= 0 {
+		return fail(invalid, fmt.Sprintf("invalid digit %q in binary literal", lit[invalid]), "")
+	}
+
+	suffix := lit[i:]
+	valid := cFloatSuffixes
+	name := "floating literal"
+	if typ == models.IntLiteral {
+		valid, name = cIntSuffixes, "integer literal"
+	}
+	if suffix != "" && !slices.Contains(valid, suffix) {
+		return fail(i, fmt.Sprintf("invalid suffix %q on %s", suffix, name), "")
+	}
+	return typ, nil
+}
+
+var (
+	cIntSuffixes = []string{
+		"u", "U", "l", "L", "ll", "LL", "ul", "uL", "Ul", "UL", "lu", "lU", "Lu", "LU",
+		"ull", "uLL", "Ull", "ULL", "llu", "llU", "LLu", "LLU",
+	}
+	cFloatSuffixes = []string{"f", "F", "l", "L"}
+)
+
+// jsonNumber checks a number of JSON: an optional minus sign, an integer
+// without leading zeros, an optional fraction and an optional exponent.
+func jsonNumber(lit string) (models.TokenType, *Error) {
+	typ := models.IntLiteral
+	fail := func(offset int, msg, hint string) (models.TokenType, *Error) {
+		return typ, &Error{Code: models.ErrInvalidNumber, Offset: offset, Message: msg, Hint: hint}
+	}
+	at := func(i int) byte {
+		if i < len(lit) {
+			return lit[i]
+		}
+		return 0
+	}
+	digits := func(i int) int {
+		for isDecimal(at(i)) {
+			i++
+		}
+		return i
+	}
+
+	i := 0
+	if at(i) == '-' {
+		i++
+	}
+	switch {
+	case at(i) == '0':
+		i++
+		if isDecimal(at(i)) {
+			return fail(i, "invalid leading zero in number", "remove the leading zero")
+		}
+	case isDecimal(at(i)):
+		i = digits(i)
+	default:
+		return fail(i, "number has no digits", "")
+	}
+
+	if at(i) == '.' {
+		typ = models.FloatLiteral
+		if j := digits(i + 1); j > i+1 {
+			i = j
+		} else {
+			return fail(i+1, "fraction has no digits", "")
+		}
+	}
+	if lower(at(i)) == 'e' {
+		typ = models.FloatLiteral
+		i++
+		if at(i) == '+' || at(i) == '-' {
+			i++
+		}
+		j := digits(i)
+		if j == i {
+			return fail(i, "exponent has no digits", "")
+		}
+		i = j
+	}
+	if i != len(lit) {
+		return fail(i, fmt.Sprintf("invalid character %q in number", lit[i:i+1]), "")
+	}
+	return typ, nil
+}
+
+// digits skips the digits and separators starting at i. Digits that are not
+// valid in base are recorded in invalid, but decimal digits are skipped for
+// all bases up to ten just like go/scanner does.
+func digits(lit string, i int, base int, invalid *int, digsep *int) int {
+	for ; i < len(lit); i++ {
+		ch := lit[i]
+		switch {
+		case ch == '_':
+			*digsep |= 2
+		case base <= 10 && isDecimal(ch), base == 16 && isHex(ch):
+			*digsep |= 1
+			if base < 10 && ch >= '0'+byte(base) && invalid != nil && *invalid < 0 {
+				*invalid = i
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// invalidSep returns the index of the first '_' in lit that does not
+// separate two digits, or a base prefix and a digit, or -1.
+func invalidSep(lit string) int {
+	hex := false
+	d := byte('.') // previous character class: '_', '0' for digits, '.' otherwise
+	i := 0
+
+	// A base prefix counts as a digit
+	if len(lit) >= 2 && lit[0] == '0' {
+		if p := lower(lit[1]); p == 'x' || p == 'o' || p == 'b' {
+			hex = p == 'x'
+			d = '0'
+			i = 2
+		}
+	}
+
+	for ; i < len(lit); i++ {
+		p := d
+		d = lit[i]
+		switch {
+		case d == '_':
+			if p != '0' {
+				return i
+			}
+		case isDecimal(d) || hex && isHex(d):
+			d = '0'
+		default:
+			if p == '_' {
+				return i - 1
+			}
+			d = '.'
+		}
+	}
+	if d == '_' {
+		return len(lit) - 1
+	}
+	return -1
+}
+
+func litname(prefix byte) string {
+	switch prefix {
+	case 'x':
+		return "hexadecimal literal"
+	case 'o', '0':
+		return "octal literal"
+	case 'b':
+		return "binary literal"
+	}
+	return "decimal literal"
+}
+
+func lower(ch byte) byte {
+	return ('a' - 'A') | ch
+}
+
+func isDecimal(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func isHex(ch byte) bool {
+	return '0' <= ch && ch <= '9' || 'a' <= lower(ch) && lower(ch) <= 'f'
+}
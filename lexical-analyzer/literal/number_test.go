@@ -0,0 +1,75 @@
+package literal
+
+import (
+	"go/scanner"
+	"go/token"
+	"testing"
+
+	"analyzer/models"
+)
+
+var numbers = []string{
+	"0", "1", "123", "1_000", "0_1", "017", "08", "09.5", "0129e3", "0129i",
+	"0x1F", "0X_1f", "0x", "0x_", "0x1_", "0x1p-2", "0X.8p1", "0x1.8", "0x1e",
+	"0o17", "0O_7", "0o8", "0o", "0o1.5", "0b101", "0b", "0b102", "0b1e5",
+	"1.5", "1.", ".5", "1e3", "1E+3", "1e-3", "1e", "1e+", "1p5", "1.5e3_0",
+	"1__0", "1_", "1_.5", "1._5", "1e_5", "3i", "1e3i", "0x1p-2i", "0b1i",
+	"0o7i", "1.5i", ".5i", "00", "0_", "0x1.p0", "0_P", "0b_2", "0x_1.8",
+}
+
+func TestNumber(t *testing.T) {
+	for _, lit := range numbers {
+		var s scanner.Scanner
+		errors, first := 0, -1
+		file := token.NewFileSet().AddFile("", -1, len(lit))
+		s.Init(file, []byte(lit), func(pos token.Position, _ string) {
+			if errors++; first < 0 || pos.Offset < first {
+				first = pos.Offset
+			}
+		}, 0)
+		_, tok, got := s.Scan()
+		if got != lit {
+			t.Fatalf("go/scanner scans %q as %q", lit, got)
+		}
+
+		want := map[token.Token]models.TokenType{
+			token.INT:   models.IntLiteral,
+			token.FLOAT: models.FloatLiteral,
+			token.IMAG:  models.ImaginaryLiteral,
+		}[tok]
+
+		typ, err := Number(lit)
+		if typ != want {
+			t.Errorf("Number(%q) type = %s; want %s", lit, typ, want)
+		}
+		if (err != nil) != (errors > 0) {
+			t.Errorf("Number(%q) error = %v; go/scanner reports %d errors", lit, err, errors)
+		}
+		if err != nil && err.Offset != first {
+			t.Errorf("Number(%q) error offset = %d; go/scanner reports %d", lit, err.Offset, first)
+		}
+	}
+}
+
+func TestNumberMessages(t *testing.T) {
+	tests := []struct {
+		lit string
+		msg string
+	}{
+		{"08", "invalid digit '8' in octal literal"},
+		{"0b102", "invalid digit '2' in binary literal"},
+		{"1__0", "'_' must separate successive digits"},
+		{"0x1.8", "hexadecimal mantissa requires a 'p' exponent"},
+		{"1p5", "'p' exponent requires hexadecimal mantissa"},
+		{"0b1e5", "'e' exponent requires decimal mantissa"},
+		{"1e+", "exponent has no digits"},
+		{"0x", "hexadecimal literal has no digits"},
+		{"0o1.5", "invalid radix point in octal literal"},
+	}
+
+	for _, tt := range tests {
+		if _, err := Number(tt.lit); err == nil || err.Message != tt.msg || err.Code != models.ErrInvalidNumber {
+			t.Errorf("Number(%q) error = %v; want %q", tt.lit, err, tt.msg)
+		}
+	}
+}
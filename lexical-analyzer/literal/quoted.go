@@ -0,0 +1,308 @@
+package literal
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"analyzer/language"
+	"analyzer/models"
+)
+
+// Quoted checks a string, raw string or rune literal against the Go spec,
+// including all escape sequences. A non-nil error describes the first
+// problem found.
+func Quoted(lit string) *Error {
+	return QuotedIn(language.Go, lit)
+}
+
+// QuotedIn is like Quoted for the escape sequences of lang.
+func QuotedIn(lang *models.Language, lit string) *Error {
+	_, err := unquote(lit, lang.Escapes, nil)
+	return err
+}
+
+// Unterminated checks a string or rune literal that ends at a line break or
+// the end of the input instead of its closing quote. A broken escape is
+// reported before the missing quote, and for rune literals instead of it,
+// as go/scanner does.
+func Unterminated(lit string) []*Error {
+	return UnterminatedIn(language.Go, lit)
+}
+
+// UnterminatedIn is like Unterminated for the escape sequences of lang.
+func UnterminatedIn(lang *models.Language, lit string) []*Error {
+	quote := lit[0]
+	var errs []*Error
+	for i := 1; i < len(lit); i++ {
+		if lit[i] != '\\' {
+			continue
+		}
+		_, _, n, err := escape(lit[i+1:], quote, lang.Escapes)
+		if err != nil {
+			err.Offset += i + 1
+			if err.Offset == len(lit) {
+				err.Code, err.Message = models.ErrUnterminated, "escape sequence not terminated"
+			}
+			if quote == '\'' {
+				return []*Error{err}
+			}
+			errs = append(errs, err)
+			break
+		}
+		i += n
+	}
+
+	return append(errs, unterminated(quote))
+}
+
+// literalNames names the kinds of quoted literals by their quotes.
+var literalNames = map[byte]string{'"': "string", '`': "raw string", '\'': "rune"}
+
+// unterminated returns the error of a literal that lacks its closing quote.
+func unterminated(quote byte) *Error {
+	return &Error{
+		Code:    models.ErrUnterminated,
+		Message: literalNames[quote] + " literal not terminated",
+		Hint:    fmt.Sprintf("add a closing %c", quote),
+	}
+}
+
+// unquote scans a quoted literal and writes its value into buf unless buf is
+// nil. For rune literals the value is also returned as a rune.
+func unquote(lit string, syntax models.EscapeSyntax, buf *strings.Builder) (rune, *Error) {
+	if lit == "" || lit[0] != '"' && lit[0] != '`' && lit[0] != '\'' {
+		return 0, &Error{Code: models.ErrUnterminated, Message: "literal is not quoted"}
+	}
+
+	quote := lit[0]
+	if len(lit) < 2 || lit[len(lit)-1] != quote {
+		return 0, unterminated(quote)
+	}
+	body := lit[1 : len(lit)-1]
+
+	if quote == '`' {
+		// Carriage returns are discarded from raw string values
+		if buf != nil {
+			buf.WriteString(strings.ReplaceAll(body, "\r", ""))
+		}
+		return 0, nil
+	}
+
+	var value rune
+	count := 0
+	for i := 0; i < len(body); {
+		ch, size := utf8.DecodeRuneInString(body[i:])
+		switch {
+		case ch == '\n':
+			return 0, unterminated(quote)
+		case ch == rune(quote):
+			return 0, &Error{
+				Code:    models.ErrUnterminated,
+				Offset:  1 + i,
+				Message: fmt.Sprintf("unescaped %c in %s literal", quote, literalNames[quote]),
+			}
+		case ch < ' ' && ch != '\t' && syntax == models.JSONEscapes:
+			return 0, &Error{
+				Code:    models.ErrIllegalCharacter,
+				Offset:  1 + i,
+				Message: fmt.Sprintf("invalid control character %#U in string literal", ch),
+				Hint:    "use an escape sequence",
+			}
+		case ch == '\\':
+			r, isByte, n, err := escape(body[i+1:], quote, syntax)
+			if err != nil {
+				// Offsets of escape errors start after the backslash
+				err.Offset += 2 + i
+				return 0, err
+			}
+			value = r
+			if buf != nil && isByte && quote == '"' {
+				buf.WriteByte(byte(r))
+			} else if buf != nil {
+				buf.WriteRune(r)
+			}
+			size = 1 + n
+		default:
+			value = ch
+			if buf != nil {
+				buf.WriteString(body[i : i+size])
+			}
+		}
+		i += size
+		count++
+	}
+
+	if quote == '\'' && count != 1 {
+		err := &Error{Code: models.ErrInvalidRune, Message: "more than one character in rune literal", Hint: "use a string literal"}
+		if count == 0 {
+			err.Message, err.Hint = "empty rune literal or unescaped ' in rune literal", `write '\'' for a quote`
+		}
+		return 0, err
+	}
+	return value, nil
+}
+
+// escape decodes the escape sequence at the start of s, right after the
+// backslash. Octal and \x escapes are single bytes in string literals, which
+// is reported by isByte. It returns the number of bytes used.
+func escape(s string, quote byte, syntax models.EscapeSyntax) (r rune, isByte bool, n int, err *Error) {
+	fail := func(offset int, code models.Code, msg, hint string) (rune, bool, int, *Error) {
+		return 0, false, 0, &Error{Code: code, Offset: offset, Message: msg, Hint: hint}
+	}
+	if s == "" {
+		return fail(0, models.ErrUnterminated, "escape sequence not terminated", "")
+	}
+	switch syntax {
+	case models.CEscapes:
+		return cEscape(s, quote)
+	case models.JSONEscapes:
+		return jsonEscape(s)
+	}
+
+	switch s[0] {
+	case 'a':
+		return '\a', false, 1, nil
+	case 'b':
+		return '\b', false, 1, nil
+	case 'f':
+		return '\f', false, 1, nil
+	case 'n':
+		return '\n', false, 1, nil
+	case 'r':
+		return '\r', false, 1, nil
+	case 't':
+		return '\t', false, 1, nil
+	case 'v':
+		return '\v', false, 1, nil
+	case '\\':
+		return '\\', false, 1, nil
+	case quote:
+		return rune(quote), false, 1, nil
+	}
+
+	var digits, start int
+	var base, limit uint32
+	switch c := s[0]; {
+	case '0' <= c && c <= '7':
+		// Octal escapes have no prefix letter
+		digits, start, base, limit, isByte = 3, 0, 8, 255, true
+	case c == 'x':
+		digits, start, base, limit, isByte = 2, 1, 16, 255, true
+	case c == 'u':
+		digits, start, base, limit = 4, 1, 16, utf8.MaxRune
+	case c == 'U':
+		digits, start, base, limit = 8, 1, 16, utf8.MaxRune
+	default:
+		return fail(0, models.ErrInvalidEscape, "unknown escape sequence", `write \\ for a backslash`)
+	}
+
+	var x uint32
+	for i := start; i < start+digits; i++ {
+		if i >= len(s) {
+			// The body ends here, so the closing quote is in the way
+			return fail(i, models.ErrInvalidEscape, fmt.Sprintf("illegal character %#U in escape sequence", rune(quote)), "")
+		}
+		d := digitVal(s[i])
+		if d >= base {
+			ch, _ := utf8.DecodeRuneInString(s[i:])
+			return fail(i, models.ErrInvalidEscape, fmt.Sprintf("illegal character %#U in escape sequence", ch), "")
+		}
+		x = x*base + d
+	}
+
+	if x > limit || 0xD800 <= x && x < 0xE000 {
+		return fail(0, models.ErrInvalidEscape, "escape sequence is invalid Unicode code point", "")
+	}
+	return rune(x), isByte, start + digits, nil
+}
+
+func digitVal(ch byte) uint32 {
+	switch {
+	case isDecimal(ch):
+		return uint32(ch - '0')
+	case isHex(ch):
+		return uint32(lower(ch) - 'a' + 10)
+	}
+	return 16
+}
+
+// cEscape decodes an escape sequence of C. Octal escapes take up to three
+// digits and \x escapes any number of them.
+func cEscape(s string, quote byte) (r rune, isByte bool, n int, err *Error) {
+	fail := func(offset int, msg string) (rune, bool, int, *Error) {
+		return 0, false, 0, &Error{Code: models.ErrInvalidEscape, Offset: offset, Message: msg}
+	}
+	if i := strings.IndexByte(`abfnrtv\'"?`, s[0]); i >= 0 {
+		return rune("\a\b\f\n\r\t\v\\'\"?"[i]), false, 1, nil
+	}
+
+	var x uint32
+	switch c := s[0]; {
+	case '0' <= c && c <= '7':
+		for n = 0; n < 3 && n < len(s) && '0' <= s[n] && s[n] <= '7'; n++ {
+			x = x*8 + uint32(s[n]-'0')
+		}
+	case c == 'x':
+		for n = 1; n < len(s) && isHex(s[n]); n++ {
+			if x = x*16 + digitVal(s[n]); x > 255 {
+				return fail(0, "hex escape sequence out of range")
+			}
+		}
+		if n == 1 {
+			return fail(1, `\x used with no following hex digits`)
+		}
+	case c == 'u', c == 'U':
+		// Universal character names are checked like in Go
+		return escape(s, quote, models.GoEscapes)
+	default:
+		return 0, false, 0, &Error{Code: models.ErrInvalidEscape, Message: "unknown escape sequence", Hint: `write \\ for a backslash`}
+	}
+	if x > 255 {
+		return fail(0, "octal escape sequence out of range")
+	}
+	return rune(x), true, n, nil
+}
+
+// jsonEscape decodes an escape sequence of JSON. A \u escape of a UTF-16
+// surrogate pair takes the second half along.
+func jsonEscape(s string) (r rune, isByte bool, n int, err *Error) {
+	if i := strings.IndexByte(`"\/bfnrt`, s[0]); i >= 0 {
+		return rune("\"\\/\b\f\n\r\t"[i]), false, 1, nil
+	}
+	if s[0] != 'u' {
+		return 0, false, 0, &Error{Code: models.ErrInvalidEscape, Message: "unknown escape sequence", Hint: `write \\ for a backslash`}
+	}
+
+	hex := func(s string, offset int) (rune, *Error) {
+		var x rune
+		for i := range 4 {
+			if i >= len(s) || !isHex(s[i]) {
+				// At the end of the body, the closing quote is in the way
+				ch := '"'
+				if i < len(s) {
+					ch, _ = utf8.DecodeRuneInString(s[i:])
+				}
+				return 0, &Error{Code: models.ErrInvalidEscape, Offset: offset + i, Message: fmt.Sprintf("illegal character %#U in escape sequence", ch)}
+			}
+			x = x*16 + rune(digitVal(s[i]))
+		}
+		return x, nil
+	}
+	r, err = hex(s[1:], 1)
+	if err != nil {
+		return 0, false, 0, err
+	}
+	if utf16.IsSurrogate(r) && strings.HasPrefix(s[5:], `\u`) {
+		if r2, err := hex(s[7:], 7); err == nil {
+			if pair := utf16.DecodeRune(r, r2); pair != utf8.RuneError {
+				return pair, false, 11, nil
+			}
+		}
+	}
+	if utf16.IsSurrogate(r) {
+		r = utf8.RuneError
+	}
+	return r, false, 5, nil
+}
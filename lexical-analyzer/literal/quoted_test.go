@@ -0,0 +1,136 @@
+package literal
+
+import (
+	"go/scanner"
+	"go/token"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"analyzer/models"
+)
+
+var quoted = []string{
+	`""`, `"abc"`, `"строка"`, `"\a\b\f\n\r\t\v\\\""`, `"\x41\101Ж\U0001F600"`,
+	`"\xff\377"`, "`raw\\n`", "`a\r\nb`", `'a'`, `'ж'`, `'\n'`, `'\''`, `'\x41'`,
+	`'\101'`, `'Ж'`, `'\U0001F600'`, `'\\'`,
+	`"\q"`, `"\'"`, `'\"'`, `"\x4"`, `"\xg1"`, `"\400"`, `"\uD800"`, `"\U00110000"`,
+	`"\u12"`, `''`, `'ab'`, `'\x4'`, `"\8"`,
+}
+
+func TestQuoted(t *testing.T) {
+	for _, lit := range quoted {
+		var s scanner.Scanner
+		errors, first := 0, -1
+		file := token.NewFileSet().AddFile("", -1, len(lit))
+		s.Init(file, []byte(lit), func(pos token.Position, _ string) {
+			if errors++; first < 0 {
+				first = pos.Offset
+			}
+		}, 0)
+		s.Scan()
+
+		err := Quoted(lit)
+		if (err != nil) != (errors > 0) {
+			t.Errorf("Quoted(%q) = %v; go/scanner reports %d errors", lit, err, errors)
+		}
+		if err != nil {
+			if err.Offset != first {
+				t.Errorf("Quoted(%q) error offset = %d; go/scanner reports %d", lit, err.Offset, first)
+			}
+			continue
+		}
+
+		// Valid literals decode like strconv does
+		typ := models.StringLiteral
+		if lit[0] == '\'' {
+			typ = models.RuneLiteral
+		}
+		want, uerr := strconv.Unquote(lit)
+		if uerr != nil {
+			t.Fatalf("strconv.Unquote(%q): %v", lit, uerr)
+		}
+		got := Decode(typ, lit)
+		if r, ok := got.(rune); ok {
+			got = string(r)
+		}
+		if got != want {
+			t.Errorf("Decode(%q) = %q; want %q", lit, got, want)
+		}
+	}
+}
+
+func TestUnterminated(t *testing.T) {
+	tests := []struct {
+		lit  string
+		want []int // offsets of the errors
+	}{
+		{`"abc`, []int{0}},
+		{`"a\`, []int{3, 0}},
+		{`"\q`, []int{2, 0}},
+		{`"\x4`, []int{4, 0}},
+		{`'a`, []int{0}},
+		{`'\`, []int{2}},
+	}
+
+	for _, tt := range tests {
+		var got []int
+		for _, err := range Unterminated(tt.lit) {
+			got = append(got, err.Offset)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Unterminated(%q) offsets = %v; want %v", tt.lit, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeNumbers(t *testing.T) {
+	ints := map[string]int64{"0": 0, "42": 42, "0x1F": 31, "017": 15, "0o17": 15, "0b101": 5, "1_000": 1000}
+	for lit, want := range ints {
+		x, ok := Decode(models.IntLiteral, lit).(*big.Int)
+		if !ok || x.Int64() != want {
+			t.Errorf("Decode(%q) = %v; want %d", lit, x, want)
+		}
+	}
+
+	floats := map[string]float64{"1.5": 1.5, ".5": 0.5, "1e3": 1000, "0x1p-2": 0.25, "09.5": 9.5, "1_0.2_5": 10.25}
+	for lit, want := range floats {
+		x, ok := Decode(models.FloatLiteral, lit).(*big.Float)
+		if f, _ := x.Float64(); !ok || f != want {
+			t.Errorf("Decode(%q) = %v; want %g", lit, x, want)
+		}
+	}
+
+	imaginary := map[string]float64{"3i": 3, "0129i": 129, "1e3i": 1000, "0x1p-2i": 0.25, "0o17i": 15}
+	for lit, want := range imaginary {
+		x, ok := Decode(models.ImaginaryLiteral, lit).(*big.Float)
+		if f, _ := x.Float64(); !ok || f != want {
+			t.Errorf("Decode(%q) = %v; want %g", lit, x, want)
+		}
+	}
+
+	// Big decimal exponents take too long to parse, which Undecoded warns
+	// about
+	huge := map[string]bool{"1e10000": true, "1e-10000i": true, "0x1p+100000": true, "1e10001": false, "1e-100000000": false, "6e886451608i": false, "1e1_0001": false}
+	for lit, decoded := range huge {
+		typ := models.FloatLiteral
+		if strings.HasSuffix(lit, "i") {
+			typ = models.ImaginaryLiteral
+		}
+		if got := Decode(typ, lit); (got != nil) != decoded {
+			t.Errorf("Decode(%q) = %v; want a value: %t", lit, got, decoded)
+		}
+		if err := Undecoded(typ, lit); (err == nil) != decoded || err != nil && (err.Severity != models.SeverityWarning || err.Offset != 1) {
+			t.Errorf("Undecoded(%q) = %+v; want a warning: %t", lit, err, !decoded)
+		}
+	}
+
+	if got := Decode(models.BooleanLiteral, "true"); got != true {
+		t.Errorf("Decode(true) = %v", got)
+	}
+	if got := Decode(models.Identifier, "x"); got != nil {
+		t.Errorf("Decode(x) = %v; want nil", got)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"analyzer/lsp"
+	"analyzer/models"
+)
+
+const lspUsage = "lsp [FLAGS]"
+
+// lspCommand runs the language server on stdin and stdout until the editor
+// sends exit or closes stdin.
+func lspCommand(args []string) int {
+	fs := newFlagSet("lsp", lspUsage)
+	lexer := fs.String("lexer", models.DefaultLexer, "the lexer: "+oneOf(allLanguageLexers()))
+	if code, stop := parseFlags(fs, args); stop {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, "unexpected arguments")
+	}
+	l := models.LookupLexer(*lexer)
+	if l == nil {
+		return usageError(fs, "unknown lexer %q, want %s", *lexer, oneOf(allLanguageLexers()))
+	}
+	// Editors open any language
+	if l.Languages() != nil {
+		return usageError(fs, "the %s lexer lexes only %s, want %s", *lexer, languageNames(l.Languages()), oneOf(allLanguageLexers()))
+	}
+
+	ctx, stop := interruptible()
+	defer stop()
+	server := lsp.NewServer()
+	server.Lex = l.Lex
+	if err := server.Serve(ctx, stdin, stdout); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+	return 0
+}
+
+// allLanguageLexers returns the names of the registered lexers of all
+// languages.
+func allLanguageLexers() []string {
+	var names []string
+	for _, name := range models.LexerNames() {
+		if models.LookupLexer(name).Languages() == nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,150 @@
+package lsp
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"analyzer/language"
+	"analyzer/models"
+)
+
+// document is an open text document with its tokens.
+type document struct {
+	uri     string
+	version int
+	text    string
+	lines   []int // offset of the first byte of each line
+	utf8    bool  // characters of positions count bytes, not UTF-16 code units
+
+	tokens []models.Token
+	diags  []models.Diagnostic
+}
+
+// language returns the language of the document by the extension in its
+// URI, Go if the extension is unknown.
+func (d *document) language() *models.Language {
+	name := d.uri
+	if u, err := url.Parse(d.uri); err == nil && u.Path != "" {
+		name = u.Path
+	}
+	if lang := language.ForFile(path.Base(name)); lang != nil {
+		return lang
+	}
+	return language.Go
+}
+
+// setText replaces the text and recomputes the line offsets.
+func (d *document) setText(text string) {
+	d.text = text
+	d.lines = append(d.lines[:0], 0)
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+}
+
+// lineEnd returns the offset of the end of line, before its newline.
+func (d *document) lineEnd(line int) int {
+	if line+1 < len(d.lines) {
+		return d.lines[line+1] - 1
+	}
+	return len(d.text)
+}
+
+// offset returns the byte offset of a position. Positions beyond the end
+// of a line or of the document are clamped to it, as LSP asks for.
+func (d *document) offset(p Position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	start, end := d.lines[p.Line], d.lineEnd(p.Line)
+	if d.utf8 {
+		return start + max(0, min(p.Character, end-start))
+	}
+	units := 0
+	for i, r := range d.text[start:end] {
+		if units >= p.Character {
+			return start + i
+		}
+		units += utf16Len(r)
+	}
+	return end
+}
+
+// position returns the position of a byte offset.
+func (d *document) position(offset int) Position {
+	offset = max(0, min(offset, len(d.text)))
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+	return Position{Line: line, Character: d.length(d.lines[line], offset)}
+}
+
+// length returns the length of the text from start to end in characters.
+func (d *document) length(start, end int) int {
+	if d.utf8 {
+		return end - start
+	}
+	units := 0
+	for _, r := range d.text[start:end] {
+		units += utf16Len(r)
+	}
+	return units
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
+
+func (d *document) rangeOf(start, end int) Range {
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+// apply applies a change to the text.
+func (d *document) apply(c TextDocumentContentChangeEvent) {
+	if c.Range == nil {
+		d.setText(c.Text)
+		return
+	}
+	start, end := d.offset(c.Range.Start), d.offset(c.Range.End)
+	if end < start {
+		start, end = end, start
+	}
+	var b strings.Builder
+	b.Grow(len(d.text) - (end - start) + len(c.Text))
+	b.WriteString(d.text[:start])
+	b.WriteString(c.Text)
+	b.WriteString(d.text[end:])
+	d.setText(b.String())
+}
+
+// nameAt returns the index of the name that contains offset or ends at it,
+// -1 if there is none.
+func (d *document) nameAt(offset int) int {
+	i := sort.Search(len(d.tokens), func(i int) bool { return d.tokens[i].End.Offset > offset })
+	if i < len(d.tokens) && d.tokens[i].Pos.Offset <= offset && isName(d.tokens[i].Type) {
+		return i
+	}
+	if i > 0 && d.tokens[i-1].End.Offset == offset && isName(d.tokens[i-1].Type) {
+		return i - 1
+	}
+	return -1
+}
+
+// isName reports whether tokens of the type are identifiers, predeclared
+// or not.
+func isName(t models.TokenType) bool {
+	switch t {
+	case models.Identifier, models.PredeclaredType, models.BuiltinFunc, models.Nil, models.Iota:
+		return true
+	}
+	return false
+}
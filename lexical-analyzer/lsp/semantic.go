@@ -0,0 +1,77 @@
+package lsp
+
+import (
+	"strings"
+
+	"analyzer/models"
+)
+
+// legend lists the semantic token types and modifiers the server uses, in
+// the order of their indexes.
+var legend = SemanticTokensLegend{
+	TokenTypes:     []string{"keyword", "variable", "number", "string", "operator", "comment", "type", "function"},
+	TokenModifiers: []string{"readonly", "defaultLibrary"},
+}
+
+const (
+	modReadonly = 1 << iota
+	modDefaultLibrary
+)
+
+// semanticType is the index of a semantic token type in the legend and the
+// bits of its modifiers.
+type semanticType struct {
+	index     uint32
+	modifiers uint32
+}
+
+// semanticTypes maps token types to semantic token types. Tokens of other
+// types, such as separators, whitespace and errors, aren't reported.
+var semanticTypes = map[models.TokenType]semanticType{
+	models.Keyword:          {0, 0},
+	models.Identifier:       {1, 0},
+	models.IntLiteral:       {2, 0},
+	models.FloatLiteral:     {2, 0},
+	models.ImaginaryLiteral: {2, 0},
+	models.StringLiteral:    {3, 0},
+	models.RuneLiteral:      {3, 0},
+	models.Operator:         {4, 0},
+	models.Comment:          {5, 0},
+	models.PredeclaredType:  {6, modDefaultLibrary},
+	models.BuiltinFunc:      {7, modDefaultLibrary},
+	models.BooleanLiteral:   {1, modReadonly | modDefaultLibrary},
+	models.Nil:              {1, modReadonly | modDefaultLibrary},
+	models.Iota:             {1, modReadonly | modDefaultLibrary},
+}
+
+// semanticTokens encodes the tokens of d that overlap the byte range from
+// start to end. Tokens that span several lines, such as block comments, are
+// split at the line ends, since clients need not support multiline tokens.
+func (d *document) semanticTokens(start, end int) SemanticTokens {
+	data := []uint32{}
+	var line, char int // the position of the previous token
+	emit := func(p Position, length int, st semanticType) {
+		deltaChar := p.Character
+		if p.Line == line {
+			deltaChar -= char
+		}
+		data = append(data, uint32(p.Line-line), uint32(deltaChar), uint32(length), st.index, st.modifiers)
+		line, char = p.Line, p.Character
+	}
+
+	for _, t := range d.tokens {
+		st, ok := semanticTypes[t.Type]
+		if !ok || t.Implicit || t.End.Offset <= start || t.Pos.Offset >= end {
+			continue
+		}
+		offset := t.Pos.Offset
+		for _, part := range strings.SplitAfter(t.Value, "\n") {
+			text := strings.TrimSuffix(strings.TrimSuffix(part, "\n"), "\r")
+			if text != "" {
+				emit(d.position(offset), d.length(offset, offset+len(text)), st)
+			}
+			offset += len(part)
+		}
+	}
+	return SemanticTokens{Data: data}
+}
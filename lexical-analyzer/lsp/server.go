@@ -0,0 +1,325 @@
+// Package lsp is a language server that brings the lexer to editors. It
+// speaks the Language Server Protocol over a pair of streams, usually stdin
+// and stdout, and offers semantic tokens for colouring, the lexical
+// diagnostics of open documents and the highlighting of a name wherever it
+// occurs in a document.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	_ "analyzer/fsmlex" // the default lexer
+	"analyzer/models"
+)
+
+// Server is a language server. The zero value is not usable, see
+// NewServer.
+type Server struct {
+	// Lex lexes documents, with the lexer registered as
+	// models.DefaultLexer by default.
+	Lex func(string, models.Options) ([]models.Token, []models.Diagnostic)
+
+	w           io.Writer
+	docs        map[string]*document
+	utf8        bool // positions count bytes instead of UTF-16 code units
+	initialized bool
+	shutdown    bool
+}
+
+// NewServer returns a server that lexes with the default lexer.
+func NewServer() *Server {
+	return &Server{Lex: models.LookupLexer(models.DefaultLexer).Lex}
+}
+
+// Serve reads messages from r and writes the responses and notifications
+// to w until the client sends exit, r ends or ctx is done. It handles one
+// message after the other. Errors of notifications go to the client as log
+// messages.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.w = w
+	s.docs = make(map[string]*document)
+
+	// The messages are read on their own goroutine, so that Serve can
+	// return when ctx is done; it ends with r
+	type read struct {
+		m   *Message
+		err error
+	}
+	reads := make(chan read)
+	go func() {
+		br := bufio.NewReader(r)
+		for {
+			m, err := ReadMessage(br)
+			select {
+			case reads <- read{m, err}:
+			case <-ctx.Done():
+				return
+			}
+			var rpcErr *Error
+			if err != nil && !errors.As(err, &rpcErr) {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case r := <-reads:
+			var rpcErr *Error
+			switch {
+			case errors.As(r.err, &rpcErr):
+				// Content that isn't JSON gets an error response
+				// without an ID
+				if err := s.reply(nil, nil, rpcErr); err != nil {
+					return err
+				}
+			case r.err == io.EOF:
+				return nil
+			case r.err != nil:
+				return r.err
+			case r.m.Method == "exit":
+				return nil
+			default:
+				if err := s.handle(r.m); err != nil {
+					return err
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// handle handles a request or a notification. Responses from the client
+// are ignored, since the server sends no requests. The error is that of
+// writing to the client.
+func (s *Server) handle(m *Message) error {
+	if m.Method == "" {
+		return nil
+	}
+	result, err := s.dispatch(m)
+	if m.ID == nil {
+		// Notifications get no response, errors are logged
+		if err != nil {
+			return s.notify("window/logMessage", LogMessageParams{Type: MessageError, Message: m.Method + ": " + err.Message})
+		}
+		return nil
+	}
+	return s.reply(m.ID, result, err)
+}
+
+// handlers of the methods; notifications return no result.
+var handlers = map[string]func(*Server, json.RawMessage) (any, error){
+	"initialize":                        (*Server).initialize,
+	"initialized":                       func(*Server, json.RawMessage) (any, error) { return nil, nil },
+	"shutdown":                          (*Server).shutdownRequest,
+	"textDocument/didOpen":              (*Server).didOpen,
+	"textDocument/didChange":            (*Server).didChange,
+	"textDocument/didClose":             (*Server).didClose,
+	"textDocument/semanticTokens/full":  (*Server).semanticTokensFull,
+	"textDocument/semanticTokens/range": (*Server).semanticTokensRange,
+	"textDocument/documentHighlight":    (*Server).documentHighlight,
+}
+
+func (s *Server) dispatch(m *Message) (any, *Error) {
+	handler, ok := handlers[m.Method]
+	switch {
+	case m.Method == "initialize" && s.initialized:
+		return nil, &Error{Code: InvalidRequest, Message: "initialize sent twice"}
+	case m.Method != "initialize" && !s.initialized:
+		return nil, &Error{Code: ServerNotInitialized, Message: "initialize must come first"}
+	case s.shutdown:
+		return nil, &Error{Code: InvalidRequest, Message: "the server is shut down"}
+	case !ok:
+		return nil, &Error{Code: MethodNotFound, Message: fmt.Sprintf("method %q not found", m.Method)}
+	}
+	result, err := handler(s, m.Params)
+	var rpcErr *Error
+	switch {
+	case errors.As(err, &rpcErr):
+		return nil, rpcErr
+	case err != nil:
+		return nil, &Error{Code: InternalError, Message: err.Error()}
+	}
+	return result, nil
+}
+
+// reply sends the response to a request.
+func (s *Server) reply(id *json.RawMessage, result any, err *Error) error {
+	if err != nil {
+		return WriteMessage(s.w, errorResponse{JSONRPC: "2.0", ID: id, Error: err})
+	}
+	return WriteMessage(s.w, response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+// notify sends a notification to the client.
+func (s *Server) notify(method string, params any) error {
+	return WriteMessage(s.w, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// unmarshal decodes the params of a message.
+func unmarshal(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &Error{Code: InvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	var p InitializeParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.initialized = true
+	encoding := "utf-16"
+	if slices.Contains(p.Capabilities.General.PositionEncodings, "utf-8") {
+		encoding = "utf-8"
+		s.utf8 = true
+	}
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			PositionEncoding: encoding,
+			TextDocumentSync: SyncIncremental,
+			SemanticTokensProvider: SemanticTokensOptions{
+				Legend: legend,
+				Range:  true,
+				Full:   true,
+			},
+			DocumentHighlightProvider: true,
+		},
+		ServerInfo: ServerInfo{Name: "lexer"},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(json.RawMessage) (any, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+// doc returns an open document.
+func (s *Server) doc(uri string) (*document, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, &Error{Code: InvalidParams, Message: fmt.Sprintf("document %s is not open", uri)}
+	}
+	return d, nil
+}
+
+// lex lexes a document and publishes its diagnostics.
+func (s *Server) lex(d *document) error {
+	opts := models.Options{Trivia: true, Predeclared: true, Language: d.language()}
+	d.tokens, d.diags = s.Lex(d.text, opts)
+	diags := []Diagnostic{}
+	for _, diag := range d.diags {
+		severity := SeverityError
+		if diag.Severity == models.SeverityWarning {
+			severity = SeverityWarning
+		}
+		message := diag.Message
+		if diag.Hint != "" {
+			message += "\nhint: " + diag.Hint
+		}
+		diags = append(diags, Diagnostic{
+			Range:    d.rangeOf(diag.Pos.Offset, diag.End.Offset),
+			Severity: severity,
+			Code:     string(diag.Code),
+			Source:   "lexer",
+			Message:  message,
+		})
+	}
+	version := d.version
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: d.uri, Version: &version, Diagnostics: diags})
+}
+
+func (s *Server) didOpen(params json.RawMessage) (any, error) {
+	var p DidOpenTextDocumentParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d := &document{uri: p.TextDocument.URI, version: p.TextDocument.Version, utf8: s.utf8}
+	d.setText(p.TextDocument.Text)
+	s.docs[d.uri] = d
+	return nil, s.lex(d)
+}
+
+func (s *Server) didChange(params json.RawMessage) (any, error) {
+	var p DidChangeTextDocumentParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.doc(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range p.ContentChanges {
+		d.apply(change)
+	}
+	d.version = p.TextDocument.Version
+	return nil, s.lex(d)
+}
+
+func (s *Server) didClose(params json.RawMessage) (any, error) {
+	var p DidCloseTextDocumentParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	// Diagnostics of closed documents go away
+	return nil, s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+}
+
+func (s *Server) semanticTokensFull(params json.RawMessage) (any, error) {
+	var p SemanticTokensParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.doc(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return d.semanticTokens(0, len(d.text)), nil
+}
+
+func (s *Server) semanticTokensRange(params json.RawMessage) (any, error) {
+	var p SemanticTokensRangeParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.doc(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return d.semanticTokens(d.offset(p.Range.Start), d.offset(p.Range.End)), nil
+}
+
+// documentHighlight highlights the name at the position wherever it occurs
+// in the document. The lexer can't tell declarations and scopes apart, so
+// these are the identical names.
+func (s *Server) documentHighlight(params json.RawMessage) (any, error) {
+	var p TextDocumentPositionParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.doc(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	highlights := []DocumentHighlight{}
+	i := d.nameAt(d.offset(p.Position))
+	if i < 0 {
+		return highlights, nil
+	}
+	name := d.tokens[i]
+	for _, t := range d.tokens {
+		if t.Type == name.Type && t.Value == name.Value {
+			highlights = append(highlights, DocumentHighlight{Range: d.rangeOf(t.Pos.Offset, t.End.Offset), Kind: HighlightText})
+		}
+	}
+	return highlights, nil
+}
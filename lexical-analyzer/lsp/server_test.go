@@ -0,0 +1,400 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// client talks to a server in the same process through pipes.
+type client struct {
+	t        *testing.T
+	w        io.Writer
+	messages chan *Message // read from the server as they come, like editors do
+	nextID   int
+	done     chan error // the result of Serve
+
+	// notifications holds the notifications of the server received
+	// while waiting for responses
+	notifications []*Message
+}
+
+func newClient(t *testing.T) *client {
+	serverR, clientW := io.Pipe()
+	clientR, serverW := io.Pipe()
+	c := &client{t: t, w: clientW, messages: make(chan *Message, 100), done: make(chan error, 1)}
+	go func() {
+		err := NewServer().Serve(context.Background(), serverR, serverW)
+		serverW.Close()
+		c.done <- err
+	}()
+	go func() {
+		defer close(c.messages)
+		br := bufio.NewReader(clientR)
+		for {
+			m, err := ReadMessage(br)
+			if err != nil {
+				return
+			}
+			c.messages <- m
+		}
+	}()
+	t.Cleanup(func() { clientW.Close() })
+	return c
+}
+
+// call sends a request and returns the result or the error of the response.
+func (c *client) call(method string, params any) (json.RawMessage, *Error) {
+	c.t.Helper()
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	if err := WriteMessage(c.w, Message{JSONRPC: "2.0", ID: &id, Method: method, Params: marshal(c.t, params)}); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		m, ok := <-c.messages
+		if !ok {
+			c.t.Fatalf("%s: the server closed the connection", method)
+		}
+		if m.ID == nil {
+			c.notifications = append(c.notifications, m)
+			continue
+		}
+		if string(*m.ID) != string(id) {
+			c.t.Fatalf("%s: response to request %s; want %s", method, *m.ID, id)
+		}
+		return m.Result, m.Error
+	}
+}
+
+// callResult calls a method that must succeed and decodes its result.
+func (c *client) callResult(method string, params, result any) {
+	c.t.Helper()
+	raw, err := c.call(method, params)
+	if err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+}
+
+// notify sends a notification.
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	if err := WriteMessage(c.w, Message{JSONRPC: "2.0", Method: method, Params: marshal(c.t, params)}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// diagnostics returns the diagnostics published since the last call. A
+// request makes sure the server has handled the notifications before.
+func (c *client) diagnostics() []PublishDiagnosticsParams {
+	c.t.Helper()
+	c.call("textDocument/semanticTokens/full", SemanticTokensParams{TextDocument: TextDocumentIdentifier{URI: "sync:"}})
+	var published []PublishDiagnosticsParams
+	for _, m := range c.notifications {
+		if m.Method != "textDocument/publishDiagnostics" {
+			c.t.Errorf("unexpected notification %s %s", m.Method, m.Params)
+			continue
+		}
+		var p PublishDiagnosticsParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			c.t.Fatal(err)
+		}
+		published = append(published, p)
+	}
+	c.notifications = nil
+	return published
+}
+
+func marshal(t *testing.T, v any) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func (c *client) initialize(encodings ...string) InitializeResult {
+	var p InitializeParams
+	p.Capabilities.General.PositionEncodings = encodings
+	var result InitializeResult
+	c.callResult("initialize", p, &result)
+	c.notify("initialized", struct{}{})
+	return result
+}
+
+func (c *client) open(uri, text string) {
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: text}})
+}
+
+func TestLifecycle(t *testing.T) {
+	c := newClient(t)
+	if _, err := c.call("textDocument/semanticTokens/full", SemanticTokensParams{}); err == nil || err.Code != ServerNotInitialized {
+		t.Errorf("request before initialize: error %v; want code %d", err, ServerNotInitialized)
+	}
+
+	result := c.initialize()
+	caps := result.Capabilities
+	if caps.PositionEncoding != "utf-16" || caps.TextDocumentSync != SyncIncremental || !caps.DocumentHighlightProvider ||
+		!caps.SemanticTokensProvider.Full || !caps.SemanticTokensProvider.Range ||
+		!reflect.DeepEqual(caps.SemanticTokensProvider.Legend, legend) {
+		t.Errorf("capabilities %+v", caps)
+	}
+	if _, err := c.call("initialize", InitializeParams{}); err == nil || err.Code != InvalidRequest {
+		t.Errorf("second initialize: error %v; want code %d", err, InvalidRequest)
+	}
+	if _, err := c.call("textDocument/hover", TextDocumentPositionParams{}); err == nil || err.Code != MethodNotFound {
+		t.Errorf("unknown method: error %v; want code %d", err, MethodNotFound)
+	}
+	if _, err := c.call("textDocument/documentHighlight", "x"); err == nil || err.Code != InvalidParams {
+		t.Errorf("invalid params: error %v; want code %d", err, InvalidParams)
+	}
+	if _, err := c.call("textDocument/semanticTokens/full", SemanticTokensParams{TextDocument: TextDocumentIdentifier{URI: "file:///a.go"}}); err == nil || err.Code != InvalidParams {
+		t.Errorf("document not open: error %v; want code %d", err, InvalidParams)
+	}
+
+	// Errors of notifications are logged
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{TextDocument: VersionedTextDocumentIdentifier{URI: "file:///a.go"}})
+	c.call("textDocument/hover", nil)
+	if len(c.notifications) != 1 || c.notifications[0].Method != "window/logMessage" {
+		t.Errorf("notifications after a change of a closed document: %+v", c.notifications)
+	}
+
+	if raw, err := c.call("shutdown", nil); err != nil || string(raw) != "null" {
+		t.Errorf("shutdown: result %s, error %v; want null", raw, err)
+	}
+	if _, err := c.call("textDocument/semanticTokens/full", SemanticTokensParams{}); err == nil || err.Code != InvalidRequest {
+		t.Errorf("request after shutdown: error %v; want code %d", err, InvalidRequest)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve returned %v after exit", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	c := newClient(t)
+	io.WriteString(c.w, "Content-Length: 5\r\n\r\n{nope")
+	m := <-c.messages
+	if m == nil || m.Error == nil || m.Error.Code != ParseError || m.ID != nil {
+		t.Errorf("response to malformed content: %+v", m)
+	}
+	c.initialize()
+
+	io.WriteString(c.w, "Content-Type: text/plain\r\n\r\n")
+	if err := <-c.done; err == nil {
+		t.Errorf("Serve returned nil after a message without Content-Length")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	c.open("file:///x/a.go", "package a\n\nvar é = 08 // 😀\nvar s = \"\\q\"\n")
+
+	version := 1
+	want := []PublishDiagnosticsParams{{
+		URI:     "file:///x/a.go",
+		Version: &version,
+		Diagnostics: []Diagnostic{{
+			Range:    Range{Start: Position{2, 9}, End: Position{2, 10}},
+			Severity: SeverityError,
+			Code:     "L0004",
+			Source:   "lexer",
+			Message:  "invalid digit '8' in octal literal\nhint: remove the leading zero for a decimal literal",
+		}, {
+			Range:    Range{Start: Position{3, 10}, End: Position{3, 12}},
+			Severity: SeverityError,
+			Code:     "L0005",
+			Source:   "lexer",
+			Message:  "unknown escape sequence\nhint: write \\\\ for a backslash",
+		}},
+	}}
+	if got := c.diagnostics(); !reflect.DeepEqual(got, want) {
+		t.Errorf("diagnostics after didOpen:\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Fix both errors with incremental changes, the second one after the
+	// first changed the line
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{URI: "file:///x/a.go", Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{
+			{Range: &Range{Start: Position{2, 8}, End: Position{2, 9}}, Text: ""},
+			{Range: &Range{Start: Position{3, 9}, End: Position{3, 10}}, Text: "\\\\"},
+		},
+	})
+	version = 2
+	want = []PublishDiagnosticsParams{{URI: "file:///x/a.go", Version: &version, Diagnostics: []Diagnostic{}}}
+	if got := c.diagnostics(); !reflect.DeepEqual(got, want) {
+		t.Errorf("diagnostics after didChange:\n%+v\nwant\n%+v", got, want)
+	}
+	var tokens SemanticTokens
+	c.callResult("textDocument/semanticTokens/full", SemanticTokensParams{TextDocument: TextDocumentIdentifier{URI: "file:///x/a.go"}}, &tokens)
+	if len(tokens.Data) != 5*11 {
+		t.Errorf("%d semantic tokens after didChange; want 11", len(tokens.Data)/5)
+	}
+
+	// A full change replaces the text, in the language of the URI
+	c.open("file:///x/b.json", "[1]")
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: "file:///x/b.json", Version: 7},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "[1, 'a']"}},
+	})
+	got := c.diagnostics()
+	if len(got) != 2 || *got[1].Version != 7 || len(got[1].Diagnostics) != 3 || got[1].Diagnostics[0].Range != (Range{Start: Position{0, 4}, End: Position{0, 5}}) {
+		t.Errorf("diagnostics of JSON: %+v", got)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: "file:///x/b.json"}})
+	want = []PublishDiagnosticsParams{{URI: "file:///x/b.json", Diagnostics: []Diagnostic{}}}
+	if got := c.diagnostics(); !reflect.DeepEqual(got, want) {
+		t.Errorf("diagnostics after didClose:\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	src := "/* é\n😀 */ x := len(\"😀\") + 1\ntype t int\n"
+	tests := []struct {
+		encoding string
+		rng      *Range
+		want     []uint32
+	}{
+		{"utf-16", nil, []uint32{
+			0, 0, 4, 5, 0, // "/* é"
+			1, 0, 5, 5, 0, // "😀 */"
+			0, 6, 1, 1, 0, // x
+			0, 2, 2, 4, 0, // :=
+			0, 3, 3, 7, modDefaultLibrary, // len
+			0, 4, 4, 3, 0, // "😀"
+			0, 6, 1, 4, 0, // +
+			0, 2, 1, 2, 0, // 1
+			1, 0, 4, 0, 0, // type
+			0, 5, 1, 1, 0, // t
+			0, 2, 3, 6, modDefaultLibrary, // int
+		}},
+		{"utf-8", nil, []uint32{
+			0, 0, 5, 5, 0,
+			1, 0, 7, 5, 0,
+			0, 8, 1, 1, 0,
+			0, 2, 2, 4, 0,
+			0, 3, 3, 7, modDefaultLibrary,
+			0, 4, 6, 3, 0,
+			0, 8, 1, 4, 0,
+			0, 2, 1, 2, 0,
+			1, 0, 4, 0, 0,
+			0, 5, 1, 1, 0,
+			0, 2, 3, 6, modDefaultLibrary,
+		}},
+		// The range takes the tokens that overlap it, all lines of them
+		{"utf-16", &Range{Start: Position{1, 3}, End: Position{1, 7}}, []uint32{
+			0, 0, 4, 5, 0,
+			1, 0, 5, 5, 0,
+			0, 6, 1, 1, 0,
+		}},
+		{"utf-16", &Range{Start: Position{2, 4}, End: Position{2, 4}}, []uint32{}},
+	}
+
+	for _, tt := range tests {
+		c := newClient(t)
+		c.initialize(tt.encoding)
+		c.open("file:///a.go", src)
+		doc := TextDocumentIdentifier{URI: "file:///a.go"}
+		var got SemanticTokens
+		if tt.rng == nil {
+			c.callResult("textDocument/semanticTokens/full", SemanticTokensParams{TextDocument: doc}, &got)
+		} else {
+			c.callResult("textDocument/semanticTokens/range", SemanticTokensRangeParams{TextDocument: doc, Range: *tt.rng}, &got)
+		}
+		if !reflect.DeepEqual(got.Data, tt.want) {
+			t.Errorf("%s, range %v: data\n%v\nwant\n%v", tt.encoding, tt.rng, got.Data, tt.want)
+		}
+	}
+}
+
+func TestDocumentHighlight(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	c.open("file:///a.go", "x := len(s)\ns = x+x\n// x\nlen := 1")
+
+	tests := []struct {
+		pos  Position
+		want []Range
+	}{
+		// At the start, inside and at the end of a name
+		{Position{0, 0}, []Range{{Position{0, 0}, Position{0, 1}}, {Position{1, 4}, Position{1, 5}}, {Position{1, 6}, Position{1, 7}}}},
+		{Position{1, 5}, []Range{{Position{0, 0}, Position{0, 1}}, {Position{1, 4}, Position{1, 5}}, {Position{1, 6}, Position{1, 7}}}},
+		{Position{0, 10}, []Range{{Position{0, 9}, Position{0, 10}}, {Position{1, 0}, Position{1, 1}}}},
+		// The builtin and the identifier of the same name differ
+		{Position{0, 6}, []Range{{Position{0, 5}, Position{0, 8}}, {Position{3, 0}, Position{3, 3}}}},
+		// Not on a name
+		{Position{0, 3}, []Range{}},
+		{Position{2, 3}, []Range{}},
+		{Position{9, 0}, []Range{}},
+	}
+	for _, tt := range tests {
+		var got []DocumentHighlight
+		c.callResult("textDocument/documentHighlight", TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: "file:///a.go"}, Position: tt.pos}, &got)
+		var ranges []Range
+		for _, h := range got {
+			if h.Kind != HighlightText {
+				t.Errorf("highlight kind %d", h.Kind)
+			}
+			ranges = append(ranges, h.Range)
+		}
+		if ranges == nil {
+			ranges = []Range{}
+		}
+		if !reflect.DeepEqual(ranges, tt.want) {
+			t.Errorf("highlights at %v = %v; want %v", tt.pos, ranges, tt.want)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	d := &document{}
+	d.setText("aé😀b\n\nxyz")
+	tests := []struct {
+		offset int
+		utf16  Position
+		utf8   Position
+	}{
+		{0, Position{0, 0}, Position{0, 0}},
+		{1, Position{0, 1}, Position{0, 1}},
+		{3, Position{0, 2}, Position{0, 3}},
+		{7, Position{0, 4}, Position{0, 7}},
+		{8, Position{0, 5}, Position{0, 8}},
+		{9, Position{1, 0}, Position{1, 0}},
+		{10, Position{2, 0}, Position{2, 0}},
+		{13, Position{2, 3}, Position{2, 3}},
+	}
+	for _, tt := range tests {
+		for _, utf8 := range []bool{false, true} {
+			d.utf8 = utf8
+			want := tt.utf16
+			if utf8 {
+				want = tt.utf8
+			}
+			if got := d.position(tt.offset); got != want {
+				t.Errorf("utf8 %t: position(%d) = %v; want %v", utf8, tt.offset, got, want)
+			}
+			if got := d.offset(want); got != tt.offset {
+				t.Errorf("utf8 %t: offset(%v) = %d; want %d", utf8, want, got, tt.offset)
+			}
+		}
+	}
+
+	// Positions beyond lines and the document are clamped
+	d.utf8 = false
+	clamped := map[Position]int{{0, 99}: 8, {1, 5}: 9, {7, 0}: 13, {-1, 0}: 0}
+	for p, want := range clamped {
+		if got := d.offset(p); got != want {
+			t.Errorf("offset(%v) = %d; want %d", p, got, want)
+		}
+	}
+}
@@ -1,41 +1,122 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strings"
+)
 
-	"analyzer/fsmlex"
-	"analyzer/models"
-	"analyzer/rxlex"
+// The streams of the commands, replaced in tests.
+var (
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
 )
 
+const usage = `Usage: lexer COMMAND [FLAGS] [PATH...]
+
+Commands:
+	lex        lex files and print their tokens
+	diff       compare the lexers with go/scanner
+	stats      count the tokens of files by type and value
+	highlight  colour files by token type for terminals or as HTML
+	spec       lex with a lexer specification or generate a lexer from it
+	lsp        serve semantic tokens and diagnostics to editors over stdio
+	help       print this help, or the flags of a command
+
+A PATH is a file, a directory searched recursively, or - for stdin.
+
+Exit codes: 0 on success, 1 if a file has lexical errors or the lexers
+diverge, 2 on usage and I/O errors.`
+
+// commands maps the names of the subcommands to their functions, which
+// return the exit code.
+var commands map[string]func(args []string) int
+
+func init() {
+	commands = map[string]func(args []string) int{
+		"lex":       lexCommand,
+		"diff":      diffCommand,
+		"stats":     statsCommand,
+		"highlight": highlightCommand,
+		"spec":      specCommand,
+		"lsp":       lspCommand,
+		"help":      helpCommand,
+	}
+}
+
 func main() {
-	var input []byte
-	var err error
-
-	if len(os.Args) > 2 {
-		input, err = os.ReadFile(os.Args[1])
-		if err != nil {
-			fmt.Println("Error reading file:", err)
-			return
-		}
-
-		var tokens []models.Token
-		if os.Args[2] == "fsm" {
-			tokens = fsmlex.Lex(string(input))
-		} else if os.Args[2] == "rx" {
-			tokens, err = rxlex.Lex(string(input))
-			if err != nil {
-				fmt.Println("Error:", err)
-				return
-			}
-		}
-
-		for _, token := range tokens {
-			fmt.Printf("%s: %s\n", token.Type, token.Value)
-		}
-	} else {
-		fmt.Println("Not enough params. Example: lexer ./examples/example.go rx")
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, usage)
+		return 2
 	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "Error: unknown command %q\n\n%s\n", args[0], usage)
+		return 2
+	}
+	return command(args[1:])
+}
+
+func helpCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stdout, usage)
+		return 0
+	}
+	command, ok := commands[args[0]]
+	if !ok || args[0] == "help" {
+		fmt.Fprintf(stderr, "Error: unknown command %q\n", args[0])
+		return 2
+	}
+	return command([]string{"-h"})
+}
+
+// interruptible returns a context that is cancelled on an interrupt, so
+// that the commands stop early and still print what they have.
+func interruptible() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+// newFlagSet returns the flag set of a command, which reports errors and
+// prints its usage to stderr.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: lexer %s\n\nFlags:\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the flags of a command. It returns the exit code if
+// the command should stop: 0 after -h, 2 on errors.
+func parseFlags(fs *flag.FlagSet, args []string) (code int, stop bool) {
+	switch err := fs.Parse(args); {
+	case err == flag.ErrHelp:
+		return 0, true
+	case err != nil:
+		return 2, true
+	}
+	return 0, false
+}
+
+// usageError reports a wrong use of a command and returns the exit code.
+func usageError(fs *flag.FlagSet, format string, args ...any) int {
+	fmt.Fprintf(stderr, "Error: %s\n", fmt.Sprintf(format, args...))
+	fs.Usage()
+	return 2
+}
 
+// oneOf formats the allowed values of a flag for messages.
+func oneOf(values []string) string {
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
 }
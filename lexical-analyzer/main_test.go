@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// runWith runs the command line args with input on stdin.
+func runWith(input string, args ...string) (code int, out, errs string) {
+	var o, e bytes.Buffer
+	stdin, stdout, stderr = strings.NewReader(input), &o, &e
+	defer func() { stdin, stdout, stderr = os.Stdin, os.Stdout, os.Stderr }()
+	code = run(args)
+	return code, o.String(), e.String()
+}
+
+// tree writes files below a temporary directory and returns it.
+func tree(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	dir := tree(t, map[string]string{
+		"a.go":        "package a\n",
+		"bad.go":      "x := 08\n",
+		"sub/c.c":     "int c;\n",
+		"sub/d.json":  "[1]\n",
+		"sub/e.txt":   "text\n",
+		"vendor/v.go": "package v\n",
+		"words.l":     "%%\n[a-z]+ Identifier\n[^a-z]+ skip\n",
+	})
+
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+		code  int
+		out   string // a part of stdout
+		err   string // a part of stderr
+	}{
+		{"no command", nil, "", 2, "", "Usage: lexer COMMAND"},
+		{"unknown command", []string{"lexx"}, "", 2, "", `unknown command "lexx"`},
+		{"help", []string{"help"}, "", 0, "Commands:", ""},
+		{"help lex", []string{"help", "lex"}, "", 0, "", "-format FORMAT"},
+		{"stdin", []string{"lex", "-"}, "a + 1", 0, "Identifier: a\nOperator: +\nInt: 1\n", ""},
+		{"stdin language", []string{"lex", "-lang", "json", "-"}, "[null]", 0, "Keyword: null", ""},
+		{"lexer", []string{"lex", "-lexer", "rx", "-format", "go-scanner", "-"}, "x", 0, "<stdin>:1:1\tIDENT\t\"x\"\n<stdin>:1:2\t;\t\"\\n\"\n", ""},
+		{"errors", []string{"lex", filepath.Join(dir, "bad.go")}, "", 1, "ERROR: 08", "bad.go:1:7: error: invalid digit '8'"},
+		{"unknown lexer", []string{"lex", "-lexer", "peg", "-"}, "", 2, "", `unknown lexer "peg"`},
+		{"unknown format", []string{"lex", "-format", "xml", "-"}, "", 2, "", `unknown format "xml"`},
+		{"unknown flag", []string{"lex", "-colour", "-"}, "", 2, "", "flag provided but not defined: -colour"},
+		{"gen language", []string{"lex", "-lexer", "gen", "-lang", "c", "-"}, "", 2, "", "the gen lexer doesn't lex c, only go"},
+		{"gen file language", []string{"lex", "-lexer", "gen", filepath.Join(dir, "sub", "d.json")}, "", 2, "", "d.json: the gen lexer doesn't lex json, only go"},
+		{"gen included language", []string{"stats", "-lexer", "gen", "-include", "*.c", dir}, "", 2, "", "c.c: the gen lexer doesn't lex c, only go"},
+		{"gen file language forced", []string{"highlight", "-lexer", "gen", "-lang", "go", filepath.Join(dir, "sub", "d.json")}, "", 0, "[", ""},
+		{"gen directory", []string{"lex", "-lexer", "gen", "-exclude", "bad.go", dir}, "", 0, "Keyword: package", ""},
+		{"no files", []string{"lex"}, "", 2, "", "no files to lex"},
+		{"missing file", []string{"lex", filepath.Join(dir, "nope.go")}, "", 2, "", "no such file"},
+		{"diff", []string{"diff", "-exclude", "bad.go", dir}, "", 0, "", "2 files, 0 diverge, 0 differ in errors"},
+		{"diff stdin", []string{"diff", "-"}, "x := 1", 0, "", "1 files, 0 diverge, 0 differ in errors"},
+		{"diff lexers", []string{"diff", "-lexers", "rx,gen", "-"}, "x := 1", 0, "", "1 files, 0 diverge, 0 differ in errors"},
+		{"diff unknown lexer", []string{"diff", "-lexers", "peg", "-"}, "", 2, "", `unknown lexer "peg"`},
+		{"summary", []string{"lex", "-j", "3", "-summary", "-exclude", "bad.go", dir}, "", 0, "Keyword: package\nIdentifier: a\n", "4 files, 31 bytes, 10 tokens, 0 errors in 0 files"},
+		{"summary errors", []string{"lex", "-summary", dir}, "", 1, "", "5 files, 39 bytes, 13 tokens, 1 errors in 1 files"},
+		{"negative workers", []string{"lex", "-j", "-1", "-"}, "", 2, "", "-j must not be negative"},
+		{"diff workers", []string{"diff", "-j", "2", "-exclude", "bad.go", dir}, "", 0, "", "2 files, 0 diverge, 0 differ in errors"},
+		{"stats", []string{"stats", "-top", "1", "-"}, "x := x + y", 0, "tokens           5\naverage length   1.20\ntokens per line  5.00\n", ""},
+		{"stats identifiers", []string{"stats", "-top", "1", "-"}, "x := x + y", 0, "identifiers\n  x  2  66.7%\n\noperators", ""},
+		{"stats per path", []string{"stats", "-per-path", "-format", "json", filepath.Join(dir, "sub"), filepath.Join(dir, "vendor")}, "", 0, `"path": "total",` + "\n" + `    "files": 3,`, ""},
+		{"stats errors", []string{"stats", dir}, "", 1, "files            5\n", "invalid digit '8'"},
+		{"stats format", []string{"stats", "-format", "csv", "-"}, "", 2, "", `unknown format "csv"`},
+		{"highlight", []string{"highlight", "-"}, "x // c", 0, "x \x1b[3;38;2;127;132;142m// c\x1b[0m", ""},
+		{"highlight html", []string{"highlight", "-format", "html", filepath.Join(dir, "bad.go")}, "", 1, `<span class="t-error error" title="invalid digit &#39;8&#39; in octal literal">08</span>`, "bad.go:1:7"},
+		{"highlight names", []string{"highlight", "-exclude", "bad.go", dir}, "", 0, "\x1b[1m" + filepath.Join(dir, "a.go") + "\x1b[0m\n", ""},
+		{"highlight theme", []string{"highlight", "-theme", filepath.Join(dir, "a.go"), "-"}, "", 2, "", "a.go: theme: invalid character"},
+		{"highlight format", []string{"highlight", "-format", "rtf", "-"}, "", 2, "", `unknown format "rtf"`},
+		{"spec help", []string{"help", "spec"}, "", 0, "", "lexer spec gen"},
+		{"spec lex", []string{"spec", "lex", "-include", "*.txt", filepath.Join(dir, "words.l"), filepath.Join(dir, "sub")}, "", 0, "Identifier: text\n", ""},
+		{"spec lex stdin", []string{"spec", "lex", filepath.Join(dir, "words.l"), "-"}, "a b", 0, "Identifier: a\nIdentifier: b\n", ""},
+		{"spec lex flags", []string{"spec", "lex", "-h"}, "", 0, "", "-include GLOB"},
+		{"spec lex no files", []string{"spec", "lex", filepath.Join(dir, "words.l")}, "", 2, "", "no files to lex"},
+		{"lsp", []string{"lsp"}, lspMessages(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`, `{"jsonrpc":"2.0","method":"exit"}`), 0, `"documentHighlightProvider":true`, ""},
+		{"lsp end of input", []string{"lsp", "-lexer", "rx"}, "", 0, "", ""},
+		{"lsp lexer", []string{"lsp", "-lexer", "gen"}, "", 2, "", "the gen lexer lexes only go, want fsm or rx"},
+		{"lsp unknown lexer", []string{"lsp", "-lexer", "peg"}, "", 2, "", `unknown lexer "peg"`},
+	}
+
+	for _, tt := range tests {
+		code, out, errs := runWith(tt.stdin, tt.args...)
+		if code != tt.code || !strings.Contains(out, tt.out) || !strings.Contains(errs, tt.err) {
+			t.Errorf("%s: exit code %d, stdout\n%s\nstderr\n%s\nwant %d, %q and %q", tt.name, code, out, errs, tt.code, tt.out, tt.err)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := tree(t, map[string]string{
+		"a.go":            "",
+		"a_test.go":       "",
+		"b.c":             "",
+		"notes.txt":       "",
+		"sub/c.json":      "",
+		"sub/deep/d.go":   "",
+		"vendor/v.go":     "",
+		"testdata/x.go":   "",
+		"testdata/y.json": "",
+	})
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"a.go", "a_test.go", "b.c", "sub/c.json", "sub/deep/d.go", "testdata/x.go", "testdata/y.json", "vendor/v.go"}},
+		{[]string{"-lang", "go"}, []string{"a.go", "a_test.go", "sub/deep/d.go", "testdata/x.go", "vendor/v.go"}},
+		{[]string{"-lexer", "gen"}, []string{"a.go", "a_test.go", "sub/deep/d.go", "testdata/x.go", "vendor/v.go"}},
+		{[]string{"-exclude", "vendor,testdata", "-exclude", "*_test.go"}, []string{"a.go", "b.c", "sub/c.json", "sub/deep/d.go"}},
+		{[]string{"-include", "*.txt", "-include", "sub/*/*.go"}, []string{"notes.txt", "sub/deep/d.go"}},
+		{[]string{"-include", "*.go", "-exclude", "sub"}, []string{"a.go", "a_test.go", "testdata/x.go", "vendor/v.go"}},
+	}
+
+	for _, tt := range tests {
+		fs := newFlagSet("lex", lexUsage)
+		var f lexFlags
+		f.add(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		if msg := f.check(); msg != "" {
+			t.Fatal(msg)
+		}
+		files, err := f.files([]string{dir, "-"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, file := range files {
+			rel, _ := filepath.Rel(dir, file)
+			if file == "-" {
+				rel = "-"
+			}
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if want := append(tt.want, "-"); !slices.Equal(got, want) {
+			t.Errorf("files with %q = %q; want %q", tt.args, got, want)
+		}
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	files := make(map[string]string)
+	for i := range 50 {
+		files[fmt.Sprintf("f%02d.go", i)] = strings.Repeat(fmt.Sprintf("x%d := %d\n", i, i), i)
+	}
+	dir := tree(t, files)
+
+	_, want, _ := runWith("", "lex", "-j", "1", "-format", "jsonl", dir)
+	for _, workers := range []string{"2", "7", "64"} {
+		if _, got, _ := runWith("", "lex", "-j", workers, "-format", "jsonl", dir); got != want {
+			t.Errorf("the output with -j %s differs from the output with -j 1", workers)
+		}
+	}
+}
+
+// lspMessages frames JSON-RPC messages for the language server.
+func lspMessages(messages ...string) string {
+	var b strings.Builder
+	for _, m := range messages {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n%s", len(m), m)
+	}
+	return b.String()
+}
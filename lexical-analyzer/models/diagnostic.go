@@ -0,0 +1,73 @@
+package models
+
+import "fmt"
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Code identifies a kind of diagnostic. Codes are stable, so tools may match
+// on them instead of on messages.
+type Code string
+
+const (
+	ErrIllegalCharacter Code = "L0001" // character that can't start a token
+	ErrInvalidEncoding  Code = "L0002" // invalid UTF-8 or misplaced byte order mark
+	ErrUnterminated     Code = "L0003" // string, rune or comment without its end
+	ErrInvalidNumber    Code = "L0004" // malformed numeric literal
+	ErrInvalidEscape    Code = "L0005" // malformed escape sequence
+	ErrInvalidRune      Code = "L0006" // rune literal without exactly one character
+	ErrIllegalOperator  Code = "L0007" // operator characters that form no operator
+	ErrTooManyErrors    Code = "L0008" // lexing stopped at Options.MaxErrors
+	ErrUndecoded        Code = "L0009" // valid literal whose value is not decoded
+)
+
+// Diagnostic is a problem found in the source.
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Pos      Position // start of the offending text
+	End      Position // position immediately after it
+	Message  string
+	Hint     string // optional suggestion how to fix the problem
+}
+
+// Format renders the diagnostic in compiler style,
+// "file:line:col: severity: message [code]", with the hint on its own line.
+func (d Diagnostic) Format(file string) string {
+	s := fmt.Sprintf("%s:%d:%d: %s: %s [%s]", file, d.Pos.Line, d.Pos.Column, d.Severity, d.Message, d.Code)
+	if d.Hint != "" {
+		s += "\n\thint: " + d.Hint
+	}
+	return s
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%d:%d: %s", d.Pos.Line, d.Pos.Column, d.Message)
+}
+
+// TooManyErrors returns the diagnostic that ends lexing at pos once
+// Options.MaxErrors is reached.
+func TooManyErrors(pos Position) Diagnostic {
+	return Diagnostic{Severity: SeverityError, Code: ErrTooManyErrors, Pos: pos, End: pos, Message: "too many errors"}
+}
+
+// HasErrors reports whether any of the diagnostics is an error.
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package models
+
+// Language describes the lexical grammar the lexers follow. The built-in
+// languages are in the language package, Options.Language selects one.
+type Language struct {
+	Name       string
+	Extensions []string // file name extensions, such as ".go"
+
+	// Keywords are the reserved words, Literals are words of other types
+	// such as true and false.
+	Keywords    []string
+	Literals    map[string]TokenType
+	Identifiers IdentifierSyntax
+
+	// Predeclared holds the types of the predeclared identifiers, used
+	// with Options.Predeclared.
+	Predeclared map[string]TokenType
+
+	// Operators and Separators are the punctuation of the language. Every
+	// separator is a single character.
+	Operators  []string
+	Separators string
+
+	// Whitespace holds the blanks besides the newline. With Splice, a
+	// backslash before a newline is whitespace as well, which joins the
+	// lines as in C.
+	Whitespace string
+	Splice     bool
+
+	// LineComment starts a comment up to the end of the line and
+	// BlockComment holds the start and the end of a block comment. They
+	// are empty if the language has no such comments.
+	LineComment  string
+	BlockComment [2]string
+
+	Quotes  []Quote
+	Escapes EscapeSyntax
+	Numbers NumberSyntax
+
+	// Semicolons is set if the semicolon rule of Go applies, see
+	// Options.Semicolons.
+	Semicolons bool
+}
+
+// Quote is a delimiter of string or rune literals.
+type Quote struct {
+	Delim byte
+	Type  TokenType // StringLiteral or RuneLiteral
+
+	// Raw literals have no escapes and may span lines.
+	Raw bool
+}
+
+// IdentifierSyntax tells which words are identifiers.
+type IdentifierSyntax int
+
+const (
+	UnicodeIdentifiers IdentifierSyntax = iota // letters, digits and '_' as in Go
+	ASCIIIdentifiers                           // ASCII letters, digits and '_' as in C
+	NoIdentifiers                              // words other than keywords and literals are errors
+)
+
+// EscapeSyntax selects the escape sequences of quoted literals.
+type EscapeSyntax int
+
+const (
+	GoEscapes EscapeSyntax = iota
+	CEscapes
+	JSONEscapes
+)
+
+// NumberSyntax selects the syntax of numeric literals.
+type NumberSyntax int
+
+const (
+	GoNumbers   NumberSyntax = iota
+	CNumbers                 // with suffixes such as 10UL or 1.5f
+	JSONNumbers              // decimal only, with an optional minus sign
+)
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Lexer is a lexing engine. Engines register under a name with
+// RegisterLexer, so that tools find them by the name.
+type Lexer interface {
+	// Lex lexes the whole input. Problems are reported as diagnostics,
+	// the offending text becomes an Error token and lexing goes on after
+	// it. It must be safe for concurrent use.
+	Lex(input string, opts Options) ([]Token, []Diagnostic)
+
+	// Languages returns the languages the lexer knows, nil if it lexes
+	// any language described by Options.Language.
+	Languages() []*Language
+}
+
+// LexerFunc is a Lexer of all languages that lexes with the function.
+type LexerFunc func(input string, opts Options) ([]Token, []Diagnostic)
+
+func (f LexerFunc) Lex(input string, opts Options) ([]Token, []Diagnostic) {
+	return f(input, opts)
+}
+
+func (f LexerFunc) Languages() []*Language {
+	return nil
+}
+
+// Knows reports whether l lexes lang.
+func Knows(l Lexer, lang *Language) bool {
+	langs := l.Languages()
+	return langs == nil || slices.Contains(langs, lang)
+}
+
+// DefaultLexer is the name of the lexer tools use unless told otherwise.
+const DefaultLexer = "fsm"
+
+// lexers holds the registered lexers by name.
+var lexers = struct {
+	sync.RWMutex
+	m map[string]Lexer
+}{m: make(map[string]Lexer)}
+
+// RegisterLexer makes a lexer available under name. Engines register in
+// their init functions, so that importing one is enough to use it. It
+// panics if the name is taken.
+func RegisterLexer(name string, l Lexer) {
+	lexers.Lock()
+	defer lexers.Unlock()
+	if _, ok := lexers.m[name]; ok {
+		panic(fmt.Sprintf("models: lexer %q registered twice", name))
+	}
+	lexers.m[name] = l
+}
+
+// LookupLexer returns the lexer registered under name, or nil.
+func LookupLexer(name string) Lexer {
+	lexers.RLock()
+	defer lexers.RUnlock()
+	return lexers.m[name]
+}
+
+// LexerNames returns the names of the registered lexers in order.
+func LexerNames() []string {
+	lexers.RLock()
+	defer lexers.RUnlock()
+	names := make([]string, 0, len(lexers.m))
+	for name := range lexers.m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
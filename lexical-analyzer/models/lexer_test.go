@@ -0,0 +1,61 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+// onlyGo is a lexer of a single language.
+type onlyGo struct{ LexerFunc }
+
+func (onlyGo) Languages() []*Language {
+	return []*Language{goLang}
+}
+
+var goLang, cLang = &Language{Name: "go"}, &Language{Name: "c"}
+
+func TestRegisterLexer(t *testing.T) {
+	lex := func(input string, opts Options) ([]Token, []Diagnostic) {
+		return []Token{{Type: Identifier, Value: input}}, nil
+	}
+	RegisterLexer("test-all", LexerFunc(lex))
+	RegisterLexer("test-go", onlyGo{lex})
+
+	l := LookupLexer("test-all")
+	if l == nil {
+		t.Fatal("LookupLexer(test-all) = nil")
+	}
+	if tokens, _ := l.Lex("x", Options{}); len(tokens) != 1 || tokens[0].Value != "x" {
+		t.Errorf("Lex(x) = %v", tokens)
+	}
+	if l := LookupLexer("test-none"); l != nil {
+		t.Errorf("LookupLexer(test-none) = %v; want nil", l)
+	}
+	names := LexerNames()
+	if !slices.IsSorted(names) || !slices.Contains(names, "test-all") || !slices.Contains(names, "test-go") {
+		t.Errorf("LexerNames() = %v", names)
+	}
+
+	tests := []struct {
+		lexer string
+		lang  *Language
+		want  bool
+	}{
+		{"test-all", goLang, true},
+		{"test-all", cLang, true},
+		{"test-go", goLang, true},
+		{"test-go", cLang, false},
+	}
+	for _, tt := range tests {
+		if got := Knows(LookupLexer(tt.lexer), tt.lang); got != tt.want {
+			t.Errorf("Knows(%s, %s) = %t; want %t", tt.lexer, tt.lang.Name, got, tt.want)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterLexer(test-all) twice didn't panic")
+		}
+	}()
+	RegisterLexer("test-all", LexerFunc(lex))
+}
@@ -1,21 +1,184 @@
 package models
 
-type TokenType string
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// TokenType is the type of a token. The types of this package are
+// constants, lexers that define types of their own, such as lexer
+// specifications, add them with NewTokenType. Types print, and marshal to
+// text, by their names. Only the names of known types unmarshal.
+type TokenType uint16
 
 const (
-	Keyword        TokenType = "Keyword"
-	Identifier     TokenType = "Identifier"
-	IntLiteral     TokenType = "Int"
-	FloatLiteral   TokenType = "Float"
-	StringLiteral  TokenType = "String"
-	RuneLiteral    TokenType = "Rune"
-	BooleanLiteral TokenType = "Boolean"
-	Operator       TokenType = "Operator"
-	Separator      TokenType = "Separator"
-	Error          TokenType = "ERROR"
+	// NoType is the zero TokenType, which no token has.
+	NoType TokenType = iota
+
+	Keyword
+	Identifier
+	IntLiteral
+	FloatLiteral
+	ImaginaryLiteral
+	StringLiteral
+	RuneLiteral
+	BooleanLiteral
+	Operator
+	Separator
+	Comment
+	Whitespace
+	Newline
+	Error
+
+	// Predeclared identifiers of Go get these types instead of Identifier
+	// with Options.Predeclared. Declarations may shadow them, which is
+	// beyond what a lexer can tell.
+	PredeclaredType // such as int or error
+	BuiltinFunc     // such as len or append
+	Nil
+	Iota
+
+	// numTypes is the number of the types above
+	numTypes
 )
 
+// typeNames holds the names of the token types of this package.
+var typeNames = [numTypes]string{
+	NoType:           "",
+	Keyword:          "Keyword",
+	Identifier:       "Identifier",
+	IntLiteral:       "Int",
+	FloatLiteral:     "Float",
+	ImaginaryLiteral: "Imaginary",
+	StringLiteral:    "String",
+	RuneLiteral:      "Rune",
+	BooleanLiteral:   "Boolean",
+	Operator:         "Operator",
+	Separator:        "Separator",
+	Comment:          "Comment",
+	Whitespace:       "Whitespace",
+	Newline:          "Newline",
+	Error:            "ERROR",
+	PredeclaredType:  "PredeclaredType",
+	BuiltinFunc:      "BuiltinFunc",
+	Nil:              "Nil",
+	Iota:             "Iota",
+}
+
+// addedTypes holds the types added by NewTokenType, the first one is
+// numTypes.
+var addedTypes struct {
+	sync.RWMutex
+	names []string
+	types map[string]TokenType // all types by name, filled in by init
+}
+
+func init() {
+	addedTypes.types = make(map[string]TokenType)
+	for t, name := range typeNames {
+		addedTypes.types[name] = TokenType(t)
+	}
+}
+
+// NewTokenType returns the token type with the name, which it adds if
+// there is none yet. It is safe for concurrent use.
+func NewTokenType(name string) TokenType {
+	addedTypes.Lock()
+	defer addedTypes.Unlock()
+	if t, ok := addedTypes.types[name]; ok {
+		return t
+	}
+	t := numTypes + TokenType(len(addedTypes.names))
+	addedTypes.names = append(addedTypes.names, name)
+	addedTypes.types[name] = t
+	return t
+}
+
+// ParseTokenType returns the token type with the name, if there is one.
+// The empty name is NoType.
+func ParseTokenType(name string) (TokenType, bool) {
+	addedTypes.RLock()
+	defer addedTypes.RUnlock()
+	t, ok := addedTypes.types[name]
+	return t, ok
+}
+
+// String returns the name of the type, such as "Keyword" or "Int".
+func (t TokenType) String() string {
+	if t < numTypes {
+		return typeNames[t]
+	}
+	addedTypes.RLock()
+	defer addedTypes.RUnlock()
+	if i := int(t - numTypes); i < len(addedTypes.names) {
+		return addedTypes.names[i]
+	}
+	return "TokenType(" + strconv.Itoa(int(t)) + ")"
+}
+
+func (t TokenType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText sets t to the type with the name in text. Types of their
+// own must have been added with NewTokenType, so that a misspelt name is
+// an error instead of a new type.
+func (t *TokenType) UnmarshalText(text []byte) error {
+	typ, ok := ParseTokenType(string(text))
+	if !ok {
+		return fmt.Errorf("unknown token type %q", text)
+	}
+	*t = typ
+	return nil
+}
+
+// IsTrivia reports whether tokens of the type carry no meaning for the
+// program, such as comments and whitespace.
+func (t TokenType) IsTrivia() bool {
+	return t == Comment || t == Whitespace || t == Newline
+}
+
 type Token struct {
 	Type  TokenType
 	Value string
+	Pos   Position // position of the first byte of the token
+	End   Position // position immediately after the token
+
+	// Implicit is set for tokens that are not present in the source, such
+	// as automatically inserted semicolons. They have zero width.
+	Implicit bool
+
+	// Decoded holds the value of a literal if decoding is enabled, see
+	// literal.Decode for the types. Value keeps the source text.
+	Decoded any
+}
+
+// Options controls optional behaviour of the lexers.
+type Options struct {
+	// Semicolons enables automatic semicolon insertion as described in
+	// the Go spec.
+	Semicolons bool
+
+	// Trivia makes the lexers emit comments, whitespace and newlines as
+	// tokens, so that the token values add up to the input.
+	Trivia bool
+
+	// Decode fills in Token.Decoded for literals.
+	Decode bool
+
+	// OmitPositions leaves Token.Pos and Token.End zero, for tools that
+	// only need the tokens themselves. Diagnostics keep their positions.
+	OmitPositions bool
+
+	// MaxErrors stops lexing once that many errors have been reported,
+	// zero means no limit.
+	MaxErrors int
+
+	// Language is the language to lex, Go if nil.
+	Language *Language
+
+	// Predeclared gives the predeclared identifiers of the language their
+	// own types, such as PredeclaredType and BuiltinFunc.
+	Predeclared bool
 }
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTokenType(t *testing.T) {
+	tests := map[TokenType]string{
+		NoType:          "",
+		Keyword:         "Keyword",
+		IntLiteral:      "Int",
+		Error:           "ERROR",
+		Iota:            "Iota",
+		TokenType(9999): "TokenType(9999)",
+	}
+	for typ, want := range tests {
+		if got := typ.String(); got != want {
+			t.Errorf("TokenType(%d).String() = %q; want %q", typ, got, want)
+		}
+	}
+
+	if typ, ok := ParseTokenType("Float"); !ok || typ != FloatLiteral {
+		t.Errorf("ParseTokenType(Float) = %v, %t; want Float", typ, ok)
+	}
+	if _, ok := ParseTokenType("Heading"); ok {
+		t.Errorf("ParseTokenType(Heading) found a type before it was added")
+	}
+	heading := NewTokenType("Heading")
+	if heading < numTypes || heading.String() != "Heading" || NewTokenType("Heading") != heading || NewTokenType("Comment") != Comment {
+		t.Errorf("NewTokenType(Heading) = %d, %q", heading, heading)
+	}
+	if typ, ok := ParseTokenType("Heading"); !ok || typ != heading {
+		t.Errorf("ParseTokenType(Heading) = %v, %t after adding it", typ, ok)
+	}
+}
+
+func TestTokenTypeJSON(t *testing.T) {
+	counts := map[TokenType]int{Keyword: 2, StringLiteral: 1}
+	b, err := json.Marshal(counts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Keyword":2,"String":1}`; string(b) != want {
+		t.Errorf("json.Marshal = %s; want %s", b, want)
+	}
+
+	// Types of their own unmarshal once they are added
+	var got map[TokenType]int
+	if err := json.Unmarshal([]byte(`{"Keyword":2,"Section":3}`), &got); err == nil || !strings.Contains(err.Error(), `unknown token type "Section"`) {
+		t.Errorf("json.Unmarshal of an unknown type: %v", err)
+	}
+	section := NewTokenType("Section")
+	got = nil
+	if err := json.Unmarshal([]byte(`{"Keyword":2,"Section":3}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[Keyword] != 2 || got[section] != 3 {
+		t.Errorf("json.Unmarshal = %v", got)
+	}
+}
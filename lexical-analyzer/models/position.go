@@ -0,0 +1,41 @@
+package models
+
+import "sort"
+
+// Position describes a location in the source text.
+type Position struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // column number in bytes, starting at 1
+}
+
+// PosTable maps byte offsets of a source text back to lines and columns.
+type PosTable struct {
+	lines []int // offset of the first byte of each line
+	size  int
+}
+
+// NewPosTable indexes the line starts of src, the whole source text the
+// offsets are taken from. Lines end at '\n' bytes.
+func NewPosTable(src string) *PosTable {
+	lines := []int{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &PosTable{lines: lines, size: len(src)}
+}
+
+// Position returns the line and column of the given offset. Offsets outside
+// of the source are clamped to its bounds.
+func (t *PosTable) Position(offset int) Position {
+	offset = max(0, min(offset, t.size))
+	line := sort.Search(len(t.lines), func(i int) bool { return t.lines[i] > offset }) - 1
+	return Position{Offset: offset, Line: line + 1, Column: offset - t.lines[line] + 1}
+}
+
+// LineCount returns the number of lines in the source.
+func (t *PosTable) LineCount() int {
+	return len(t.lines)
+}
@@ -0,0 +1,31 @@
+package models
+
+import "testing"
+
+func TestPosTable(t *testing.T) {
+	table := NewPosTable("ab\ncd\n\nef")
+
+	tests := []struct {
+		offset int
+		want   Position
+	}{
+		{0, Position{Offset: 0, Line: 1, Column: 1}},
+		{2, Position{Offset: 2, Line: 1, Column: 3}},
+		{3, Position{Offset: 3, Line: 2, Column: 1}},
+		{6, Position{Offset: 6, Line: 3, Column: 1}},
+		{8, Position{Offset: 8, Line: 4, Column: 2}},
+		{9, Position{Offset: 9, Line: 4, Column: 3}},
+		{-1, Position{Offset: 0, Line: 1, Column: 1}},
+		{100, Position{Offset: 9, Line: 4, Column: 3}},
+	}
+
+	for _, tt := range tests {
+		if got := table.Position(tt.offset); got != tt.want {
+			t.Errorf("Position(%d) = %+v; want %+v", tt.offset, got, tt.want)
+		}
+	}
+
+	if got := table.LineCount(); got != 4 {
+		t.Errorf("LineCount() = %d; want 4", got)
+	}
+}
@@ -0,0 +1,25 @@
+package models
+
+// InsertsSemicolon reports whether a line break after the token terminates a
+// statement, according to the semicolon rule of the Go spec.
+func InsertsSemicolon(t Token) bool {
+	switch t.Type {
+	case Identifier, PredeclaredType, BuiltinFunc, Nil, Iota, BooleanLiteral, IntLiteral, FloatLiteral, ImaginaryLiteral, StringLiteral, RuneLiteral:
+		return true
+	case Keyword:
+		switch t.Value {
+		case "break", "continue", "fallthrough", "return":
+			return true
+		}
+	case Operator:
+		return t.Value == "++" || t.Value == "--"
+	case Separator:
+		return t.Value == ")" || t.Value == "]" || t.Value == "}"
+	}
+	return false
+}
+
+// Semicolon returns an implicit semicolon token at the given position.
+func Semicolon(pos Position) Token {
+	return Token{Type: Separator, Value: ";", Pos: pos, End: pos, Implicit: true}
+}
@@ -0,0 +1,43 @@
+package models
+
+// Span is the compact form of a token: its type and the byte offsets of its
+// text in the source, which it doesn't copy. Spans have no other fields and
+// so can be lexed into a reused slice without allocating. An implicit
+// semicolon is a Separator of zero width.
+type Span struct {
+	Type       TokenType
+	Start, End int
+}
+
+// Text returns the text of the span in src, which it shares.
+func (s Span) Text(src []byte) []byte {
+	return src[s.Start:s.End:s.End]
+}
+
+// Implicit reports whether the span is not present in the source.
+func (s Span) Implicit() bool {
+	return s.Start == s.End
+}
+
+// Span returns the compact form of the token.
+func (t Token) Span() Span {
+	return Span{Type: t.Type, Start: t.Pos.Offset, End: t.End.Offset}
+}
+
+// Tokens converts the spans of src to tokens with their values and
+// positions. Decoded is left unset.
+func Tokens(src string, spans []Span) []Token {
+	if len(spans) == 0 {
+		return nil
+	}
+	table := NewPosTable(src)
+	tokens := make([]Token, len(spans))
+	for i, s := range spans {
+		if s.Implicit() {
+			tokens[i] = Semicolon(table.Position(s.Start))
+			continue
+		}
+		tokens[i] = Token{Type: s.Type, Value: src[s.Start:s.End], Pos: table.Position(s.Start), End: table.Position(s.End)}
+	}
+	return tokens
+}
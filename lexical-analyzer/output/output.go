@@ -0,0 +1,310 @@
+// Package output writes tokens in the formats of the lexer command: plain
+// text, JSON, JSON lines, CSV, an aligned table and the form of go/scanner.
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"math/big"
+	"strconv"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"analyzer/models"
+)
+
+// Format names an output format.
+type Format string
+
+const (
+	Text      Format = "text"       // "Type: Value" lines
+	JSON      Format = "json"       // an array of token objects
+	JSONL     Format = "jsonl"      // a token object per line
+	CSV       Format = "csv"        // a header and a row per token
+	Table     Format = "table"      // tab-aligned columns
+	GoScanner Format = "go-scanner" // "file:line:column	TOKEN	literal" like the go/scanner example
+)
+
+// Formats lists the formats in the order they are documented.
+var Formats = []Format{Text, JSON, JSONL, CSV, Table, GoScanner}
+
+// Writer writes the tokens of one or more files in a format. Close finishes
+// the output, such as the closing bracket of JSON.
+type Writer interface {
+	WriteFile(file string, tokens []models.Token) error
+	Close() error
+}
+
+// NewWriter returns a Writer for format f.
+func NewWriter(w io.Writer, f Format) (Writer, error) {
+	switch f {
+	case Text:
+		return &textWriter{w: w}, nil
+	case JSON:
+		return &jsonWriter{w: w}, nil
+	case JSONL:
+		return &jsonWriter{w: w, lines: true}, nil
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case Table:
+		return &tableWriter{w: tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)}, nil
+	case GoScanner:
+		return &scannerWriter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", f)
+}
+
+// Token is the form of a token in JSON. JSON strings hold only valid
+// UTF-8, so a value that isn't is also given as bytes, which marshal to
+// base64: the exact source text in ValueBytes next to Value, where invalid
+// bytes became U+FFFD, and a decoded string in DecodedBytes instead of
+// Decoded.
+type Token struct {
+	File         string   `json:"file"`
+	Type         string   `json:"type"`
+	Value        string   `json:"value"`
+	ValueBytes   []byte   `json:"value_bytes,omitempty"`
+	Pos          Position `json:"pos"`
+	End          Position `json:"end"`
+	Implicit     bool     `json:"implicit,omitempty"`
+	Decoded      any      `json:"decoded,omitempty"`
+	DecodedBytes []byte   `json:"decoded_bytes,omitempty"`
+}
+
+// Position is the form of a position in JSON.
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func newToken(file string, t models.Token) Token {
+	token := Token{
+		File:     file,
+		Type:     t.Type.String(),
+		Value:    t.Value,
+		Pos:      Position(t.Pos),
+		End:      Position(t.End),
+		Implicit: t.Implicit,
+	}
+	if !utf8.ValidString(t.Value) {
+		token.ValueBytes = []byte(t.Value)
+	}
+	if s, ok := t.Decoded.(string); ok && !utf8.ValidString(s) {
+		token.DecodedBytes = []byte(s)
+	} else {
+		token.Decoded = jsonValue(t.Decoded)
+	}
+	return token
+}
+
+// jsonValue turns a decoded value into a JSON value. Numbers stay exact,
+// runes become strings.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case *big.Int:
+		return json.Number(v.String())
+	case *big.Float:
+		if v.IsInf() {
+			return v.String()
+		}
+		return json.Number(v.Text('g', -1))
+	case rune:
+		return string(v)
+	}
+	return v
+}
+
+// decodedText formats a decoded value for text columns, empty if there is
+// none.
+func decodedText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case *big.Float:
+		return v.Text('g', -1)
+	case rune:
+		return string(v)
+	}
+	return fmt.Sprint(v)
+}
+
+type textWriter struct {
+	w io.Writer
+}
+
+func (t *textWriter) WriteFile(file string, tokens []models.Token) error {
+	for _, token := range tokens {
+		if _, err := fmt.Fprintf(t.w, "%s: %s\n", token.Type, token.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *textWriter) Close() error { return nil }
+
+type jsonWriter struct {
+	w       io.Writer
+	lines   bool
+	started bool // the opening bracket of JSON is written
+}
+
+func (j *jsonWriter) WriteFile(file string, tokens []models.Token) error {
+	for _, token := range tokens {
+		b, err := json.Marshal(newToken(file, token))
+		if err != nil {
+			return err
+		}
+		switch {
+		case j.lines:
+			b = append(b, '\n')
+		case j.started:
+			b = append([]byte(",\n"), b...)
+		default:
+			b = append([]byte("[\n"), b...)
+			j.started = true
+		}
+		if _, err := j.w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *jsonWriter) Close() error {
+	switch {
+	case j.lines:
+		return nil
+	case !j.started:
+		_, err := io.WriteString(j.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(j.w, "\n]\n")
+	return err
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool // the header is written
+}
+
+func (c *csvWriter) WriteFile(file string, tokens []models.Token) error {
+	if !c.header {
+		c.header = true
+		c.w.Write([]string{"file", "type", "value", "line", "column", "offset", "end_line", "end_column", "end_offset", "implicit", "decoded"})
+	}
+	for _, t := range tokens {
+		c.w.Write([]string{
+			file, t.Type.String(), t.Value,
+			strconv.Itoa(t.Pos.Line), strconv.Itoa(t.Pos.Column), strconv.Itoa(t.Pos.Offset),
+			strconv.Itoa(t.End.Line), strconv.Itoa(t.End.Column), strconv.Itoa(t.End.Offset),
+			strconv.FormatBool(t.Implicit), decodedText(t.Decoded),
+		})
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error { return nil }
+
+// tableWriter quotes values and decoded strings so that tabs and newlines
+// in them keep the columns aligned.
+type tableWriter struct {
+	w      *tabwriter.Writer
+	header bool // the header is written
+}
+
+func (t *tableWriter) WriteFile(file string, tokens []models.Token) error {
+	if !t.header {
+		t.header = true
+		fmt.Fprintln(t.w, "POSITION\tTYPE\tVALUE\tDECODED")
+	}
+	for _, token := range tokens {
+		value := strconv.Quote(token.Value)
+		if token.Implicit {
+			value = "(implicit)"
+		}
+		decoded := decodedText(token.Decoded)
+		switch token.Decoded.(type) {
+		case string, rune:
+			decoded = strconv.Quote(decoded)
+		}
+		fmt.Fprintf(t.w, "%s:%d:%d\t%s\t%s\t%s\n", file, token.Pos.Line, token.Pos.Column, token.Type, value, decoded)
+	}
+	return nil
+}
+
+func (t *tableWriter) Close() error { return t.w.Flush() }
+
+// scannerWriter prints tokens like the example of go/scanner does: the
+// position, the token and its literal. Operators and separators have an
+// empty literal, implicit semicolons the literal "\n".
+type scannerWriter struct {
+	w io.Writer
+}
+
+// operators maps the text of operators and separators to their tokens.
+var operators = func() map[string]token.Token {
+	ops := make(map[string]token.Token)
+	for tok := range token.Token(128) {
+		if tok.IsOperator() {
+			ops[tok.String()] = tok
+		}
+	}
+	return ops
+}()
+
+func (s *scannerWriter) WriteFile(file string, tokens []models.Token) error {
+	for _, t := range tokens {
+		if t.Type == models.Whitespace || t.Type == models.Newline {
+			continue
+		}
+		tok, lit := scannerToken(t)
+		if _, err := fmt.Fprintf(s.w, "%s:%d:%d\t%s\t%q\n", file, t.Pos.Line, t.Pos.Column, tok, lit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *scannerWriter) Close() error { return nil }
+
+// scannerToken returns the go/scanner token of t and its literal, ILLEGAL
+// for errors and for operators Go doesn't have.
+func scannerToken(t models.Token) (token.Token, string) {
+	switch t.Type {
+	case models.Keyword:
+		return token.Lookup(t.Value), t.Value
+	case models.IntLiteral:
+		return token.INT, t.Value
+	case models.FloatLiteral:
+		return token.FLOAT, t.Value
+	case models.ImaginaryLiteral:
+		return token.IMAG, t.Value
+	case models.RuneLiteral:
+		return token.CHAR, t.Value
+	case models.StringLiteral:
+		return token.STRING, t.Value
+	case models.Comment:
+		return token.COMMENT, t.Value
+	case models.Operator, models.Separator:
+		if t.Implicit {
+			return token.SEMICOLON, "\n"
+		}
+		if tok, ok := operators[t.Value]; ok {
+			if tok == token.SEMICOLON {
+				return tok, t.Value
+			}
+			return tok, ""
+		}
+		return token.ILLEGAL, t.Value
+	case models.Error:
+		return token.ILLEGAL, t.Value
+	}
+	// Identifiers, predeclared or not, and true and false
+	return token.IDENT, t.Value
+}
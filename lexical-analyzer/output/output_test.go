@@ -0,0 +1,205 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"analyzer/fsmlex"
+	"analyzer/models"
+)
+
+const src = "package p\n\nvar x = f(0x1F, 1.5, 2i, \"a\\tb\", 'c', false) // c\n"
+
+func write(t *testing.T, f Format, opts models.Options, files ...string) string {
+	t.Helper()
+	var b bytes.Buffer
+	w, err := NewWriter(&b, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		input := src
+		if file != "src.go" {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			input = string(data)
+		}
+		tokens, _ := fsmlex.LexOptions(input, opts)
+		if err := w.WriteFile(file, tokens); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestJSON(t *testing.T) {
+	for _, f := range []Format{JSON, JSONL} {
+		out := write(t, f, models.Options{Decode: true, Semicolons: true}, "src.go", "src.go")
+		var tokens []Token
+		if f == JSON {
+			if err := json.Unmarshal([]byte(out), &tokens); err != nil {
+				t.Fatalf("%s: %v\n%s", f, err, out)
+			}
+		} else {
+			for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+				var token Token
+				if err := json.Unmarshal([]byte(line), &token); err != nil {
+					t.Fatalf("%s: %v in %s", f, err, line)
+				}
+				tokens = append(tokens, token)
+			}
+		}
+		if len(tokens) != 42 {
+			t.Fatalf("%s: %d tokens; want 42", f, len(tokens))
+		}
+
+		var decoded []string
+		for _, token := range tokens[:21] {
+			if token.Decoded != nil {
+				decoded = append(decoded, fmt.Sprint(token.Decoded))
+			}
+		}
+		if got, want := strings.Join(decoded, " "), "31 1.5 2 a\tb c false"; got != want {
+			t.Errorf("%s: decoded values %q; want %q", f, got, want)
+		}
+		if x := tokens[4]; x.Value != "x" || x.Pos != (Position{Offset: 15, Line: 3, Column: 5}) || x.End != (Position{Offset: 16, Line: 3, Column: 6}) {
+			t.Errorf("%s: token 4 = %+v", f, x)
+		}
+		if semi := tokens[2]; !semi.Implicit || semi.Value != ";" {
+			t.Errorf("%s: token 2 = %+v; want an implicit semicolon", f, semi)
+		}
+	}
+
+	if out := write(t, JSON, models.Options{}); out != "[]\n" {
+		t.Errorf("JSON without tokens = %q", out)
+	}
+}
+
+// TestJSONInvalidUTF8 checks that values that aren't valid UTF-8 keep
+// their bytes.
+func TestJSONInvalidUTF8(t *testing.T) {
+	input := "x := \"\\xff\" + \"\xfe\"\n"
+	tokens, _ := fsmlex.LexOptions(input, models.Options{Decode: true})
+	var b bytes.Buffer
+	w, err := NewWriter(&b, JSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteFile("x.go", tokens); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != len(tokens) {
+		t.Fatalf("%d lines for %d tokens", len(lines), len(tokens))
+	}
+	for i, line := range lines {
+		var token Token
+		if err := json.Unmarshal([]byte(line), &token); err != nil {
+			t.Fatalf("%v in %s", err, line)
+		}
+		want := tokens[i]
+		value := token.Value
+		if token.ValueBytes != nil {
+			value = string(token.ValueBytes)
+		}
+		if value != want.Value {
+			t.Errorf("value of token %d = %q; want %q", i, value, want.Value)
+		}
+		if s, ok := want.Decoded.(string); ok && !utf8.ValidString(s) {
+			if token.Decoded != nil || string(token.DecodedBytes) != s {
+				t.Errorf("token %d: decoded %v, decoded_bytes %q; want decoded_bytes %q", i, token.Decoded, token.DecodedBytes, s)
+			}
+		} else if token.DecodedBytes != nil {
+			t.Errorf("token %d: decoded_bytes %q for %q", i, token.DecodedBytes, want.Value)
+		}
+	}
+	if n := strings.Count(b.String(), "_bytes"); n != 2 {
+		t.Errorf("%d byte fields; want 2\n%s", n, b.String())
+	}
+}
+
+func TestCSV(t *testing.T) {
+	out := write(t, CSV, models.Options{Decode: true}, "src.go", "src.go")
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1+2*19 {
+		t.Fatalf("%d records; want %d", len(records), 1+2*19)
+	}
+	want := []string{"src.go", "String", `"a\tb"`, "3", "26", "36", "3", "32", "42", "false", "a\tb"}
+	if got := records[14]; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("record 14 = %q; want %q", got, want)
+	}
+}
+
+func TestTable(t *testing.T) {
+	lines := strings.Split(write(t, Table, models.Options{Decode: true}, "src.go"), "\n")
+	want := map[int]string{
+		0:  "POSITION     TYPE        VALUE        DECODED",
+		8:  `src.go:3:11  Int         "0x1F"       31`,
+		14: `src.go:3:26  String      "\"a\\tb\""  "a\tb"`,
+		16: `src.go:3:34  Rune        "'c'"        "c"`,
+	}
+	for i, line := range want {
+		if strings.TrimRight(lines[i], " ") != line {
+			t.Errorf("line %d = %q; want %q", i, lines[i], line)
+		}
+	}
+}
+
+// TestGoScanner checks the go-scanner format against go/scanner itself.
+func TestGoScanner(t *testing.T) {
+	for _, file := range []string{"src.go", "../examples/example.go", "output.go"} {
+		input := []byte(src)
+		if file != "src.go" {
+			var err error
+			if input, err = os.ReadFile(file); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var want strings.Builder
+		var s scanner.Scanner
+		fset := token.NewFileSet()
+		s.Init(fset.AddFile(file, -1, len(input)), input, nil, 0)
+		for {
+			pos, tok, lit := s.Scan()
+			if tok == token.EOF {
+				break
+			}
+			fmt.Fprintf(&want, "%s\t%s\t%q\n", fset.Position(pos), tok, lit)
+		}
+
+		if got := write(t, GoScanner, models.Options{Semicolons: true}, file); got != want.String() {
+			t.Errorf("%s: go-scanner output differs from go/scanner:\n%s\nwant\n%s", file, got, want.String())
+		}
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	for _, f := range Formats {
+		if _, err := NewWriter(&bytes.Buffer{}, f); err != nil {
+			t.Errorf("NewWriter(%s): %v", f, err)
+		}
+	}
+	if _, err := NewWriter(&bytes.Buffer{}, "xml"); err == nil {
+		t.Error("NewWriter(xml) = no error")
+	}
+}
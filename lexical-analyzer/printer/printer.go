@@ -0,0 +1,167 @@
+// Package printer turns tokens back into source text, either exactly as
+// they were lexed or with normalised spacing.
+package printer
+
+import (
+	"io"
+	"strings"
+
+	_ "analyzer/fsmlex" // the default lexer
+	"analyzer/language"
+	"analyzer/models"
+)
+
+// Mode selects how the printer lays out tokens.
+type Mode int
+
+const (
+	// Exact writes the token values as they are. Tokens lexed with
+	// Options.Trivia reproduce the input byte for byte.
+	Exact Mode = iota
+
+	// Normalized drops whitespace and newlines and separates tokens with
+	// as little as it takes to lex them the same way again: nothing, a
+	// space, or a newline where a semicolon was inserted or a line comment
+	// ends. Comments are kept.
+	Normalized
+)
+
+// Config controls the printer.
+type Config struct {
+	Mode Mode
+
+	// Options are the options the tokens were lexed with. Normalized
+	// relexes the output with them to choose the spacing, only Language
+	// and Predeclared matter.
+	Options models.Options
+
+	// Lex is the lexer the output is checked with, the one registered as
+	// models.DefaultLexer if nil.
+	Lex func(string, models.Options) ([]models.Token, []models.Diagnostic)
+}
+
+// Print returns the source text of tokens lexed with Options.Trivia.
+func Print(tokens []models.Token) string {
+	return Config{}.Sprint(tokens)
+}
+
+// Normalize returns the source text of tokens with normalised spacing.
+func Normalize(tokens []models.Token, opts models.Options) string {
+	return Config{Mode: Normalized, Options: opts}.Sprint(tokens)
+}
+
+// Sprint returns the source text of tokens.
+func (c Config) Sprint(tokens []models.Token) string {
+	var b strings.Builder
+	c.Fprint(&b, tokens)
+	return b.String()
+}
+
+// Fprint writes the source text of tokens to w.
+func (c Config) Fprint(w io.Writer, tokens []models.Token) error {
+	if c.Mode == Exact {
+		for _, token := range tokens {
+			if token.Implicit {
+				continue
+			}
+			if _, err := io.WriteString(w, token.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	p := printer{Config: c}
+	if p.Lex == nil {
+		p.Lex = models.LookupLexer(models.DefaultLexer).Lex
+	}
+	p.Options.Trivia, p.Options.Semicolons = true, false
+	p.Options.Decode, p.Options.MaxErrors = false, 0
+	for _, token := range tokens {
+		p.print(token)
+	}
+	_, err := io.WriteString(w, p.out.String())
+	return err
+}
+
+// context is the number of printed tokens the spacing is checked against.
+// Only lexemes that start in them can change when another token follows.
+const context = 3
+
+type printer struct {
+	Config
+	out strings.Builder
+
+	// recent are the last tokens printed, starts their offsets in out
+	recent []models.Token
+	starts []int
+
+	// newline is set when the next token has to go on a new line
+	newline bool
+}
+
+func (p *printer) print(token models.Token) {
+	switch {
+	case token.Implicit:
+		// Semicolons come back at the end of the line, which a block
+		// comment spanning lines ends as well
+		if n := len(p.recent); n > 0 && (p.recent[n-1].Type != models.Comment || !strings.Contains(p.recent[n-1].Value, "\n")) {
+			p.newline = true
+		}
+		return
+	case token.Type == models.Whitespace || token.Type == models.Newline:
+		return
+	}
+
+	sep := "\n"
+	if !p.newline {
+		for _, s := range []string{"", " "} {
+			if p.fits(s, token) {
+				sep = s
+				break
+			}
+		}
+	}
+	if len(p.recent) == 0 {
+		sep = ""
+	}
+
+	p.out.WriteString(sep)
+	p.recent = append(p.recent, token)
+	p.starts = append(p.starts, p.out.Len())
+	if len(p.recent) > context {
+		p.recent, p.starts = p.recent[1:], p.starts[1:]
+	}
+	p.out.WriteString(token.Value)
+	p.newline = p.isLineComment(token)
+}
+
+// fits reports whether the recent tokens and token, separated by sep, lex
+// to the same tokens again.
+func (p *printer) fits(sep string, token models.Token) bool {
+	if len(p.recent) == 0 {
+		return true
+	}
+	src := p.out.String()[p.starts[0]:] + sep + token.Value
+	want := append(p.recent[:len(p.recent):len(p.recent)], token)
+
+	got, _ := p.Lex(src, p.Options)
+	i := 0
+	for _, t := range got {
+		if t.Type == models.Whitespace || t.Type == models.Newline {
+			continue
+		}
+		if i == len(want) || t.Type != want[i].Type || t.Value != want[i].Value {
+			return false
+		}
+		i++
+	}
+	return i == len(want)
+}
+
+// isLineComment reports whether the token is a comment that runs up to the
+// end of the line.
+func (p *printer) isLineComment(token models.Token) bool {
+	start := language.Of(p.Options).LineComment
+	return token.Type == models.Comment && start != "" && strings.HasPrefix(token.Value, start)
+}
@@ -0,0 +1,157 @@
+package printer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"analyzer/fsmlex"
+	"analyzer/language"
+	"analyzer/models"
+	"analyzer/rxlex"
+)
+
+type lexer struct {
+	name string
+	lex  func(string, models.Options) ([]models.Token, []models.Diagnostic)
+}
+
+var lexers = []lexer{{"fsmlex", fsmlex.LexOptions}, {"rxlex", rxlex.LexOptions}}
+
+// sources returns the Go files of this module and the examples.
+func sources(t *testing.T) map[string]string {
+	files, err := filepath.Glob("../*/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcs := make(map[string]string)
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srcs[file] = string(src)
+	}
+	return srcs
+}
+
+func TestPrint(t *testing.T) {
+	srcs := sources(t)
+	srcs["c"] = "#include <stdio.h>\nint main(void) {\n\tputs(\"hi\\n\"); /* done */ \\\n\treturn 0;\n}\n"
+	srcs["bad"] = "\uFEFFx := 08 + \"abc\n\xff @@ `raw"
+	for _, l := range lexers {
+		for name, src := range srcs {
+			opts := models.Options{Semicolons: true, Trivia: true, Language: language.ForFile(name)}
+			if name == "c" {
+				opts.Language = language.C
+			}
+			tokens, _ := l.lex(src, opts)
+			if got := Print(tokens); got != src {
+				t.Errorf("%s: %s doesn't print back exactly:\n%q", l.name, name, got)
+			}
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		lang  *models.Language
+		input string
+		want  string
+	}{
+		{language.Go, "package  main\n\nfunc f( a int ) {\n\treturn a +  +b\n}\n",
+			"package main\nfunc f(a int){return a+ +b\n}"},
+		{language.Go, "x := a . b\ny := 1 . 5 + . 5\nz := 1 .. 2", "x:=a.b\ny:=1 . 5+. 5\nz:=1 .. 2"},
+		{language.Go, "a &^ = b; c & ^ d; e - -f; g-- - h", "a&^ =b;c& ^d;e- -f;g---h"},
+		{language.Go, "a = b / / c\nd := x / *p", "a=b/ /c\nd:=x/ *p"},
+		{language.Go, "a . . . b", "a.. .b"},
+		{language.Go, "f(a, // first\n\tb) // last\n", "f(a,// first\nb)// last"},
+		{language.Go, "x /* a\nb */ y", "x/* a\nb */y"},
+		{language.Go, "x /* a */\ny", "x/* a */\ny"},
+		{language.Go, "var x int = 0x1p-2 - 1 .5", "var x int=0x1p-2-1 .5"},
+		{language.Go, "s := `a\nb` + \"c\"", "s:=`a\nb`+\"c\""},
+		{language.C, "# define X ( 1 + + 2 ) \\\n", "#define X(1+ +2)"},
+		{language.C, "a = b - > c; d -> e", "a=b- >c;d->e"},
+		{language.JSON, "{ \"a\" : [ 1 , -2 , true , null ] }", "{\"a\":[1,-2,true,null]}"},
+	}
+
+	for _, tt := range tests {
+		opts := models.Options{Semicolons: true, Trivia: true, Language: tt.lang}
+		tokens, _ := fsmlex.LexOptions(tt.input, opts)
+		if got := Normalize(tokens, opts); got != tt.want {
+			t.Errorf("Normalize(%q) = %q; want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestNormalizeSources checks that normalised sources lex to the same
+// tokens with both lexers, whichever lexer chose the spacing.
+func TestNormalizeSources(t *testing.T) {
+	for name, src := range sources(t) {
+		opts := models.Options{Semicolons: true, Trivia: true}
+		want, _ := fsmlex.LexOptions(src, opts)
+		for _, check := range lexers {
+			out := Config{Mode: Normalized, Options: opts, Lex: check.lex}.Sprint(want)
+			if len(out) >= len(src) {
+				t.Errorf("%s: normalised with %s to %d bytes, the source has %d", name, check.name, len(out), len(src))
+			}
+			for _, l := range lexers {
+				got, _ := l.lex(out, opts)
+				if i, ok := sameTokens(got, want); !ok {
+					t.Errorf("%s normalised with %s: %s gives token %d %v; want %v", name, check.name, l.name, i, at(got, i), at(want, i))
+					break
+				}
+			}
+		}
+	}
+}
+
+func FuzzNormalize(f *testing.F) {
+	f.Add("package p\n\nfunc f() { return a.b + .5 }\n")
+	f.Add("x := a &^ -b // c\n/* d\n */ y--")
+	f.Fuzz(func(t *testing.T, src string) {
+		opts := models.Options{Semicolons: true, Trivia: true}
+		want, diags := fsmlex.LexOptions(src, opts)
+		if len(diags) > 0 {
+			return
+		}
+		out := Normalize(want, opts)
+		for _, l := range lexers {
+			got, _ := l.lex(out, opts)
+			if i, ok := sameTokens(got, want); !ok {
+				t.Fatalf("%q normalised to %q: %s gives token %d %v; want %v", src, out, l.name, i, at(got, i), at(want, i))
+			}
+		}
+	})
+}
+
+// sameTokens compares the tokens other than whitespace and newlines by
+// type, value and whether they are implicit. It returns the index of the
+// first difference in the compared tokens.
+func sameTokens(got, want []models.Token) (int, bool) {
+	got, want = significant(got), significant(want)
+	for i := range max(len(got), len(want)) {
+		if i >= len(got) || i >= len(want) || got[i].Type != want[i].Type || got[i].Value != want[i].Value || got[i].Implicit != want[i].Implicit {
+			return i, false
+		}
+	}
+	return 0, true
+}
+
+func significant(tokens []models.Token) []models.Token {
+	var s []models.Token
+	for _, token := range tokens {
+		if token.Type != models.Whitespace && token.Type != models.Newline {
+			s = append(s, token)
+		}
+	}
+	return s
+}
+
+func at(tokens []models.Token, i int) any {
+	tokens = significant(tokens)
+	if i < len(tokens) {
+		return tokens[i]
+	}
+	return "nothing"
+}
@@ -0,0 +1,124 @@
+package rxlex
+
+import (
+	"go/scanner"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"analyzer/models"
+)
+
+var diagnosticInputs = []struct {
+	input string
+	code  models.Code
+}{
+	{"a # b", models.ErrIllegalCharacter},
+	{"x := 08 + y", models.ErrInvalidNumber},
+	{"x := 1__0", models.ErrInvalidNumber},
+	{`s := "a\qb"`, models.ErrInvalidEscape},
+	{`s := "\u12"`, models.ErrInvalidEscape},
+	{"s := \"abc\nx", models.ErrUnterminated},
+	{"r := 'ab'", models.ErrInvalidRune},
+	{"x /* open", models.ErrUnterminated},
+	{"s := `open", models.ErrUnterminated},
+	{"s := \"a\xffb\"", models.ErrInvalidEncoding},
+	{"a \uFEFF b", models.ErrInvalidEncoding},
+	{"s := \"a\x00b\"", models.ErrIllegalCharacter},
+}
+
+func TestDiagnostics(t *testing.T) {
+	for _, tt := range diagnosticInputs {
+		var s scanner.Scanner
+		var first token.Position
+		file := token.NewFileSet().AddFile("", -1, len(tt.input))
+		s.Init(file, []byte(tt.input), func(pos token.Position, _ string) {
+			if !first.IsValid() {
+				first = pos
+			}
+		}, 0)
+		for _, tok, _ := s.Scan(); tok != token.EOF; _, tok, _ = s.Scan() {
+		}
+
+		tokens, diags := LexOptions(tt.input, models.Options{})
+		if len(diags) == 0 {
+			t.Errorf("LexOptions(%q) reports no diagnostics", tt.input)
+			continue
+		}
+		d := diags[0]
+		if d.Code != tt.code || d.Severity != models.SeverityError {
+			t.Errorf("LexOptions(%q) diagnostic = %v %v; want %v", tt.input, d.Severity, d.Code, tt.code)
+		}
+		if d.Pos.Offset != first.Offset || d.Pos.Line != first.Line || d.Pos.Column != first.Column {
+			t.Errorf("LexOptions(%q) diagnostic at %d:%d; go/scanner reports %d:%d", tt.input, d.Pos.Line, d.Pos.Column, first.Line, first.Column)
+		}
+
+		// Lexing goes on past the error
+		var errors int
+		for _, token := range tokens {
+			if token.Type == models.Error {
+				errors++
+			}
+		}
+		if errors != 1 {
+			t.Errorf("LexOptions(%q) = %v; want one error token", tt.input, tokens)
+		}
+	}
+}
+
+func TestDiagnosticsAfterError(t *testing.T) {
+	_, diags := LexOptions("a # b\nc := 08 @", models.Options{})
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.Format("f.go"))
+	}
+	want := []string{
+		"f.go:1:3: error: illegal character U+0023 '#' [L0001]",
+		"f.go:2:7: error: invalid digit '8' in octal literal [L0004]\n\thint: remove the leading zero for a decimal literal",
+		"f.go:2:9: error: illegal character U+0040 '@' [L0001]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diagnostics = %q; want %q", got, want)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"a @@# b", []string{"a", "@", "@", "#", "b"}},
+		{"x := y@$+z", []string{"x", ":=", "y", "@", "$", "+", "z"}},
+		{"f(#)", []string{"f", "(", "#", ")"}},
+		{"a$\"s\"", []string{"a", "$", `"s"`}},
+		{"a /* open\nb", []string{"a", "/* open\nb"}},
+	}
+
+	for _, tt := range tests {
+		tokens, diags := LexOptions(tt.input, models.Options{})
+		var got []string
+		errors := 0
+		for _, token := range tokens {
+			got = append(got, token.Value)
+			if token.Type == models.Error {
+				errors++
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("LexOptions(%q) = %q; want %q", tt.input, got, tt.want)
+		}
+		if len(diags) != errors {
+			t.Errorf("LexOptions(%q) reports %d diagnostics for %d error tokens", tt.input, len(diags), errors)
+		}
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	tokens, diags := LexOptions("a # b # c # d", models.Options{MaxErrors: 2})
+	if len(tokens) != 4 || tokens[3].Type != models.Error {
+		t.Errorf("tokens = %v; want lexing to stop after the second error", tokens)
+	}
+	if len(diags) != 3 || diags[2].Code != models.ErrTooManyErrors || diags[2].Pos.Column != 8 {
+		t.Errorf("diagnostics = %v; want two errors and too many errors at 1:8", diags)
+	}
+}
@@ -0,0 +1,383 @@
+package rxlex
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// matcher finds the longest lexeme of some rules, the ones a lexeme may
+// be of given its first byte. Rules of a simple form, such as a list of
+// words or a run of characters of a class, are matched without the regexp
+// package. Group i+1 of re is the rest of the rules, rules[i], unless
+// there is only one of them and re has no groups.
+type matcher struct {
+	simple []simple
+	re     *regexp.Regexp
+	rules  []int
+}
+
+// simple is a rule matched by a function, which returns the length of the
+// longest lexeme at the start of input or -1.
+type simple struct {
+	rule  int
+	match func(input string) int
+}
+
+// dispatch returns a matcher for each first byte of a lexeme, nil where no
+// rule matches. Trying only the rules that can start with the byte keeps
+// the alternations small, which the regexp package runs much faster.
+func dispatch(patterns []string) [256]*matcher {
+	var starts [256][]int
+	simples := make([]func(string) int, len(patterns))
+	for i, pattern := range patterns {
+		re, err := syntax.Parse(pattern, syntax.Perl)
+		if err != nil {
+			panic("rxlex: " + err.Error())
+		}
+		simples[i] = simpleMatch(re.Simplify())
+		var set [256]bool
+		if firstBytes(re, &set) {
+			// A rule for the empty lexeme is tried everywhere
+			set = [256]bool{}
+			for b := range set {
+				set[b] = true
+			}
+		}
+		for b, ok := range set {
+			if ok {
+				starts[b] = append(starts[b], i)
+			}
+		}
+	}
+
+	var first [256]*matcher
+	matchers := make(map[string]*matcher)
+	for b, rules := range starts {
+		if len(rules) == 0 {
+			continue
+		}
+		var key strings.Builder
+		for _, i := range rules {
+			key.WriteString(string(rune(i)))
+		}
+		m, ok := matchers[key.String()]
+		if !ok {
+			m = newMatcher(patterns, simples, rules)
+			matchers[key.String()] = m
+		}
+		first[b] = m
+	}
+	return first
+}
+
+func newMatcher(patterns []string, simples []func(string) int, rules []int) *matcher {
+	m := &matcher{}
+	for _, i := range rules {
+		if simples[i] != nil {
+			m.simple = append(m.simple, simple{i, simples[i]})
+		} else {
+			m.rules = append(m.rules, i)
+		}
+	}
+	switch len(m.rules) {
+	case 0:
+		return m
+	case 1:
+		m.re = regexp.MustCompile(`^(?:` + patterns[m.rules[0]] + `)`)
+	default:
+		alternatives := make([]string, len(m.rules))
+		for j, i := range m.rules {
+			alternatives[j] = "(" + patterns[i] + ")"
+		}
+		m.re = regexp.MustCompile(`^(?:` + strings.Join(alternatives, "|") + `)`)
+		if m.re.NumSubexp() != len(m.rules) {
+			panic("rxlex: rules must not have capturing groups")
+		}
+	}
+	m.re.Longest()
+	return m
+}
+
+// find returns the rule of the longest lexeme at the start of input and
+// its length, or -1. Ties go to the rule listed first.
+func (m *matcher) find(input string) (rule, size int) {
+	rule, size = -1, -1
+	better := func(r, n int) {
+		if n > size || n == size && r < rule {
+			rule, size = r, n
+		}
+	}
+	for _, s := range m.simple {
+		if n := s.match(input); n >= 0 {
+			better(s.rule, n)
+		}
+	}
+	if m.re != nil {
+		if len(m.rules) == 1 {
+			if loc := m.re.FindStringIndex(input); loc != nil {
+				better(m.rules[0], loc[1])
+			}
+		} else if loc := m.re.FindStringSubmatchIndex(input); loc != nil {
+			for j, i := range m.rules {
+				if loc[2*j+2] >= 0 {
+					better(i, loc[1])
+					break
+				}
+			}
+		}
+	}
+	if rule < 0 {
+		return -1, 0
+	}
+	return rule, size
+}
+
+// maxWords is the most words a rule may stand for to be matched as a list.
+const maxWords = 1024
+
+// simpleMatch returns a function matching re without the regexp package,
+// or nil if re has no simple form: a list of words, or a sequence of
+// literals and characters of classes repeated so that the longest match
+// never has to give a character back.
+func simpleMatch(re *syntax.Regexp) func(string) int {
+	if list, ok := words(re); ok {
+		set := make(map[string]bool, len(list))
+		longest := 0
+		for _, w := range list {
+			set[w] = true
+			longest = max(longest, len(w))
+		}
+		return func(input string) int {
+			for n := min(longest, len(input)); n >= 0; n-- {
+				if set[input[:n]] {
+					return n
+				}
+			}
+			return -1
+		}
+	}
+
+	var items []item
+	subs := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		subs = re.Sub
+	}
+	for _, sub := range subs {
+		it, ok := itemOf(sub)
+		if !ok {
+			return nil
+		}
+		items = append(items, it)
+	}
+	// A repetition must stop where the next item starts
+	for j, it := range items[:len(items)-1] {
+		if it.max < 0 && it.overlaps(items[j+1]) {
+			return nil
+		}
+	}
+	return func(input string) int {
+		n := 0
+		for _, it := range items {
+			count := 0
+			for it.max < 0 || count < it.max {
+				r, size := utf8.DecodeRuneInString(input[n:])
+				if size == 0 || !it.has(count, r) {
+					break
+				}
+				n += size
+				count++
+			}
+			if count < it.min {
+				return -1
+			}
+		}
+		return n
+	}
+}
+
+// item is a part of a simple rule: the runes of a literal in order, or
+// characters of a class repeated min to max times, any number if max < 0.
+type item struct {
+	literal  []rune
+	class    []rune
+	min, max int
+}
+
+func itemOf(re *syntax.Regexp) (item, bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return item{}, false
+		}
+		return item{literal: re.Rune, min: len(re.Rune), max: len(re.Rune)}, true
+	case syntax.OpCharClass:
+		return item{class: re.Rune, min: 1, max: 1}, true
+	case syntax.OpStar, syntax.OpPlus:
+		if re.Sub[0].Op != syntax.OpCharClass {
+			return item{}, false
+		}
+		it := item{class: re.Sub[0].Rune, max: -1}
+		if re.Op == syntax.OpPlus {
+			it.min = 1
+		}
+		return it, true
+	}
+	return item{}, false
+}
+
+// has reports whether r may be the i-th rune of the item.
+func (it item) has(i int, r rune) bool {
+	if it.literal != nil {
+		return it.literal[i] == r
+	}
+	return inClass(it.class, r)
+}
+
+// overlaps reports whether the first rune of next may be a rune of it.
+func (it item) overlaps(next item) bool {
+	if next.literal != nil {
+		return inClass(it.class, next.literal[0])
+	}
+	for i := 0; i+1 < len(next.class); i += 2 {
+		for j := 0; j+1 < len(it.class); j += 2 {
+			if next.class[i] <= it.class[j+1] && it.class[j] <= next.class[i+1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inClass reports whether r is in the sorted ranges of class.
+func inClass(class []rune, r rune) bool {
+	i := sort.Search(len(class)/2, func(i int) bool { return class[2*i+1] >= r })
+	return i < len(class)/2 && class[2*i] <= r
+}
+
+// words returns the strings re matches if there are only a few of them.
+func words(re *syntax.Regexp) ([]string, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		return []string{""}, true
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return nil, false
+		}
+		return []string{string(re.Rune)}, true
+	case syntax.OpCharClass:
+		var list []string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if len(list)+int(re.Rune[i+1]-re.Rune[i]) >= maxWords {
+				return nil, false
+			}
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				list = append(list, string(r))
+			}
+		}
+		return list, true
+	case syntax.OpCapture:
+		return words(re.Sub[0])
+	case syntax.OpAlternate:
+		var list []string
+		for _, sub := range re.Sub {
+			more, ok := words(sub)
+			if !ok || len(list)+len(more) > maxWords {
+				return nil, false
+			}
+			list = append(list, more...)
+		}
+		return list, true
+	case syntax.OpConcat:
+		list := []string{""}
+		for _, sub := range re.Sub {
+			more, ok := words(sub)
+			if !ok || len(list)*len(more) > maxWords {
+				return nil, false
+			}
+			var next []string
+			for _, a := range list {
+				for _, b := range more {
+					next = append(next, a+b)
+				}
+			}
+			list = next
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+// firstBytes adds the bytes a match of re may start with to set and
+// reports whether re matches the empty string. The set may hold more bytes
+// than needed: every byte from 0x80 up stands for any rune that is not
+// ASCII, including invalid UTF-8, which the regexp package reads as
+// U+FFFD.
+func firstBytes(re *syntax.Regexp, set *[256]bool) (empty bool) {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return false
+	case syntax.OpLiteral:
+		if len(re.Rune) == 0 {
+			return true
+		}
+		r := re.Rune[0]
+		addRange(set, r, r)
+		if re.Flags&syntax.FoldCase != 0 {
+			for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+				addRange(set, f, f)
+			}
+		}
+		return false
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			addRange(set, re.Rune[i], re.Rune[i+1])
+		}
+		return false
+	case syntax.OpAnyCharNotNL:
+		addRange(set, 0, '\n'-1)
+		addRange(set, '\n'+1, unicode.MaxRune)
+		return false
+	case syntax.OpAnyChar:
+		addRange(set, 0, unicode.MaxRune)
+		return false
+	case syntax.OpCapture, syntax.OpPlus:
+		return firstBytes(re.Sub[0], set)
+	case syntax.OpStar, syntax.OpQuest:
+		firstBytes(re.Sub[0], set)
+		return true
+	case syntax.OpRepeat:
+		return firstBytes(re.Sub[0], set) || re.Min == 0
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !firstBytes(sub, set) {
+				return false
+			}
+		}
+		return true
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if firstBytes(sub, set) {
+				empty = true
+			}
+		}
+		return empty
+	}
+	// Empty-width assertions and the empty match
+	return true
+}
+
+// addRange adds the first bytes of the runes lo to hi to set.
+func addRange(set *[256]bool, lo, hi rune) {
+	for r := lo; r <= min(hi, utf8.RuneSelf-1); r++ {
+		set[r] = true
+	}
+	if hi >= utf8.RuneSelf {
+		for b := utf8.RuneSelf; b < len(set); b++ {
+			set[b] = true
+		}
+	}
+}
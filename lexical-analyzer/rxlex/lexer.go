@@ -2,206 +2,322 @@ package rxlex
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
+	"unicode/utf8"
 
+	"analyzer/language"
+	"analyzer/lexgen"
+	"analyzer/literal"
 	"analyzer/models"
 )
 
-func Lex(input string) ([]models.Token, error) {
-	var tokens []models.Token
+const (
+	bomRune = 0xFEFF
+	bom     = "\uFEFF"
+)
 
-	keywords := []string{
-		"break", "default", "func", "interface", "select",
-		"case", "defer", "go", "map", "struct",
-		"chan", "else", "goto", "package", "switch",
-		"const", "fallthrough", "if", "range", "type",
-		"continue", "for", "import", "return", "var",
-	}
+func init() {
+	models.RegisterLexer("rx", models.LexerFunc(LexOptions))
+}
 
-	operators := [][]string{
-		{"<<=", ">>=", "&^="},
-		{":=", "...", "++", "--", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<", ">>", "&&", "||", "==", "!=", "<=", ">=", "<-", "&^"},
-		{"+", "-", "*", "/", "%", "&", "|", "^", "!", "=", "<", ">", "~"},
+// Lex returns the tokens of input and the first error found in it.
+func Lex(input string) ([]models.Token, error) {
+	tokens, diags := LexOptions(input, models.Options{})
+	for _, d := range diags {
+		if d.Severity == models.SeverityError {
+			return tokens, d
+		}
 	}
+	return tokens, nil
+}
 
-	separators := map[rune]bool{
-		'(': true,
-		')': true,
-		'[': true,
-		']': true,
-		'{': true,
-		'}': true,
-		',': true,
-		';': true,
-		':': true,
-		'.': true,
+// LexOptions lexes the whole input in the language of opts. Problems are
+// reported as diagnostics, the offending text becomes an Error token and
+// lexing goes on after it.
+// It is safe for concurrent use.
+func LexOptions(input string, opts models.Options) ([]models.Token, []models.Diagnostic) {
+	g := grammarOf(language.Of(opts))
+	return LexWith(input, opts, g.match)
+}
+
+// LexWith lexes the whole input like LexOptions, finding lexemes with a
+// matcher for RulesFor(language.Of(opts)) instead of the regexp package.
+// Everything else, such as semicolons and the checks of literals, is done
+// the same way.
+func LexWith(input string, opts models.Options, match lexgen.Matcher) ([]models.Token, []models.Diagnostic) {
+	g := grammarOf(language.Of(opts))
+	lang := g.lang
+	if !lang.Semicolons {
+		opts.Semicolons = false
 	}
 
-	for len(input) > 0 {
-		// Delete empty lines
-		if whitespace := regexp.MustCompile(`^\s+`).FindString(input); whitespace != "" {
-			input = input[len(whitespace):]
-			continue
-		}
+	var tokens []models.Token
+	var diags []models.Diagnostic
+	src := input
+	table := models.NewPosTable(src)
+	insertSemi := false
 
-		// Delete comments
-		if strings.HasPrefix(input, "//") {
-			end := strings.Index(input, "\n")
-			if end == -1 {
-				input = ""
-			} else {
-				input = input[end:]
-			}
-			continue
+	// position is the position of a token at offset, if tokens have them
+	position := func(offset int) models.Position {
+		if opts.OmitPositions {
+			return models.Position{}
 		}
+		return table.Position(offset)
+	}
 
-		if strings.HasPrefix(input, "/*") {
-			end := strings.Index(input, "*/")
-			if end == -1 {
-				return nil, fmt.Errorf("unterminated block comment")
-			}
-			input = input[end+2:]
-			continue
+	// Tokens are always emitted before the lexeme is cut off the input,
+	// so the consumed length is the token offset. Error tokens leave the
+	// line as it was, see invalid for malformed literals.
+	emit := func(typ models.TokenType, value string) {
+		start := len(src) - len(input)
+		token := models.Token{
+			Type:  typ,
+			Value: value,
+			Pos:   position(start),
+			End:   position(start + len(value)),
 		}
-
-		// Check for keywords
-		keywordFound := false
-		for _, kw := range keywords {
-			if strings.HasPrefix(input, kw) {
-				remaining := input[len(kw):]
-				if len(remaining) == 0 || !isIdentifierPart(rune(remaining[0])) {
-					tokens = append(tokens, models.Token{Type: models.Keyword, Value: kw})
-					input = remaining
-					keywordFound = true
-					break
-				}
+		if opts.Decode {
+			token.Decoded = literal.DecodeIn(lang, typ, value)
+			if err := literal.Undecoded(typ, value); err != nil {
+				diags = append(diags, err.Diagnostic(table.Position(start), table.Position(start+len(value))))
 			}
 		}
-		if keywordFound {
-			continue
+		tokens = append(tokens, token)
+		if typ != models.Error && !typ.IsTrivia() {
+			insertSemi = models.InsertsSemicolon(token)
 		}
+	}
 
-		// Check for literals
+	// invalid emits a malformed literal of type typ as an Error token,
+	// which ends a line like the literal would, as in go/scanner
+	invalid := func(typ models.TokenType, text string) {
+		emit(models.Error, text)
+		insertSemi = models.InsertsSemicolon(models.Token{Type: typ, Value: text})
+	}
 
-		// `` string
-		if rawStr := regexp.MustCompile("^`[^`]*`").FindString(input); rawStr != "" {
-			tokens = append(tokens, models.Token{Type: models.StringLiteral, Value: rawStr})
-			input = input[len(rawStr):]
-			continue
+	report := func(code models.Code, start, end int, msg, hint string) {
+		diags = append(diags, models.Diagnostic{
+			Severity: models.SeverityError,
+			Code:     code,
+			Pos:      table.Position(start),
+			End:      table.Position(end),
+			Message:  msg,
+			Hint:     hint,
+		})
+	}
+
+	// fail emits text at the start of the remaining input as an Error token
+	// and reports it
+	fail := func(text string, code models.Code, msg, hint string) {
+		start := len(src) - len(input)
+		report(code, start, start+len(text), msg, hint)
+		emit(models.Error, text)
+	}
+
+	// checked emits a literal, or an Error token if the literal check failed
+	checked := func(typ models.TokenType, text string, err *literal.Error) {
+		if err != nil {
+			start := len(src) - len(input)
+			diags = append(diags, err.Diagnostic(table.Position(start), table.Position(start+len(text))))
+			invalid(typ, text)
+			return
 		}
+		emit(typ, text)
+	}
 
-		// "" string
-		if interpretedStr := regexp.MustCompile(`^"(?:\\.|[^"\\])*"`).FindString(input); interpretedStr != "" {
-			tokens = append(tokens, models.Token{Type: models.StringLiteral, Value: interpretedStr})
-			input = input[len(interpretedStr):]
-			continue
+	// badEncoding reports every illegal character in text at the start of
+	// the remaining input
+	badEncoding := func(text string) bool {
+		start := len(src) - len(input)
+		found := false
+		for offset, r := range text {
+			if illegal(text[offset:], start+offset) {
+				_, size := utf8.DecodeRuneInString(text[offset:])
+				switch r {
+				case bomRune:
+					report(models.ErrInvalidEncoding, start+offset, start+offset+size, "illegal byte order mark", "remove the byte order mark")
+				case 0:
+					report(models.ErrIllegalCharacter, start+offset, start+offset+size, "illegal character NUL", "")
+				default:
+					report(models.ErrInvalidEncoding, start+offset, start+offset+size, "invalid UTF-8 encoding", "")
+				}
+				found = true
+			}
 		}
+		return found
+	}
 
-		// Rune
-		if runeLit := regexp.MustCompile(`^'(?:\\.|.)'`).FindString(input); runeLit != "" {
-			tokens = append(tokens, models.Token{Type: models.RuneLiteral, Value: runeLit})
-			input = input[len(runeLit):]
-			continue
+	// quoted emits a string or rune literal. Illegal characters in it are
+	// reported along with the first problem of the literal itself.
+	quoted := func(typ models.TokenType, text string) {
+		bad := badEncoding(text)
+		if err := literal.QuotedIn(lang, text); err != nil || !bad {
+			checked(typ, text, err)
+		} else {
+			invalid(typ, text)
 		}
+	}
 
-		// Boolean
-		if strings.HasPrefix(input, "true") {
-			remaining := input[4:]
-			if len(remaining) == 0 || !isIdentifierPart(rune(remaining[0])) {
-				tokens = append(tokens, models.Token{Type: models.BooleanLiteral, Value: "true"})
-				input = remaining
-				continue
-			}
+	semicolon := func(offset int) {
+		tokens = append(tokens, models.Semicolon(position(offset)))
+		insertSemi = false
+	}
+
+	// Byte order mark is allowed only at the start of the file
+	if strings.HasPrefix(input, bom) {
+		if opts.Trivia {
+			emit(models.Whitespace, bom)
 		}
+		input = input[len(bom):]
+	}
 
-		if strings.HasPrefix(input, "false") {
-			remaining := input[5:]
-			if len(remaining) == 0 || !isIdentifierPart(rune(remaining[0])) {
-				tokens = append(tokens, models.Token{Type: models.BooleanLiteral, Value: "false"})
-				input = remaining
-				continue
-			}
+	for len(input) > 0 {
+		if opts.MaxErrors > 0 && len(diags) >= opts.MaxErrors {
+			diags = append(diags, models.TooManyErrors(table.Position(len(src)-len(input))))
+			return tokens, diags
 		}
 
-		// Hex integer
-		if hex := regexp.MustCompile(`^0[xX][0-9a-fA-F_]+`).FindString(input); hex != "" {
-			tokens = append(tokens, models.Token{Type: models.IntLiteral, Value: hex})
-			input = input[len(hex):]
+		// Semicolon at the end of a statement line
+		if opts.Semicolons && insertSemi && input[0] == '\n' {
+			semicolon(len(src) - len(input))
 			continue
 		}
 
-		// Binary integer
-		if binary := regexp.MustCompile(`^0[bB][01_]+`).FindString(input); binary != "" {
-			tokens = append(tokens, models.Token{Type: models.IntLiteral, Value: binary})
-			input = input[len(binary):]
+		if _, size := utf8.DecodeRuneInString(input); illegal(input, len(src)-len(input)) {
+			badEncoding(input[:size])
+			emit(models.Error, input[:size])
+			input = input[size:]
 			continue
 		}
 
-		// Octal integer
-		if octal := regexp.MustCompile(`^0[oO]?[0-7_]+`).FindString(input); octal != "" {
-			tokens = append(tokens, models.Token{Type: models.IntLiteral, Value: octal})
-			input = input[len(octal):]
+		rule, size := match(input)
+		if rule < 0 {
+			// Lexing resumes right after the illegal character, which
+			// makes an Error token of its own as in fsmlex
+			r, size := utf8.DecodeRuneInString(input)
+			fail(input[:size], models.ErrIllegalCharacter, fmt.Sprintf("illegal character %#U", r), "")
+			input = input[size:]
 			continue
 		}
 
-		// Numbers
-		if num := regexp.MustCompile(`^([0-9][0-9_]*(\.[0-9_]*)?|\.[0-9_]+)([eE][+-]?[0-9_]+)?`).FindString(input); num != "" {
-			if strings.ContainsAny(num, ".eE") {
-				tokens = append(tokens, models.Token{Type: models.FloatLiteral, Value: num})
-			} else {
-				tokens = append(tokens, models.Token{Type: models.IntLiteral, Value: num})
+		text := input[:size]
+		switch typ := g.rules[rule].Type; typ {
+		case models.Whitespace, models.Newline:
+			// Delete empty lines, or keep them as trivia
+			if opts.Trivia {
+				emit(typ, text)
+			}
+
+		case models.Comment:
+			// Delete comments, or keep them as trivia
+			offset := len(src) - len(input)
+			nl := strings.IndexByte(text, '\n')
+			if badEncoding(text) {
+				emit(models.Error, text)
+			} else if opts.Trivia {
+				emit(models.Comment, text)
+			}
+
+			// A line break in a block comment ends the statement like a
+			// newline
+			if opts.Semicolons && insertSemi && nl >= 0 {
+				semicolon(offset + nl)
 			}
-			input = input[len(num):]
-			continue
-		}
 
-		// Operators
-		opFound := false
-		for _, group := range operators {
-			for _, op := range group {
-				if strings.HasPrefix(input, op) {
-					tokens = append(tokens, models.Token{Type: models.Operator, Value: op})
-					input = input[len(op):]
-					opFound = true
-					break
+		case models.Error:
+			switch q, isQuote := g.quote(text[0]); {
+			case lang.BlockComment[0] != "" && strings.HasPrefix(text, lang.BlockComment[0]):
+				offset := len(src) - len(input)
+				badEncoding(text)
+				fail(text, models.ErrUnterminated, "comment not terminated", "add "+lang.BlockComment[1])
+				if nl := strings.IndexByte(text, '\n'); opts.Semicolons && insertSemi && nl >= 0 {
+					semicolon(offset + nl)
 				}
+			case isQuote && q.Raw:
+				quoted(q.Type, text)
+			case isQuote:
+				// Not terminated, which is reported along with any
+				// illegal characters in it
+				start := len(src) - len(input)
+				badEncoding(text)
+				for _, err := range literal.UnterminatedIn(lang, text) {
+					diags = append(diags, err.Diagnostic(table.Position(start), table.Position(start+len(text))))
+				}
+				invalid(q.Type, text)
+			default:
+				// A word of a language without identifiers
+				fail(text, models.ErrIllegalCharacter, fmt.Sprintf("unexpected word %q", text), "")
 			}
 
-			if opFound {
-				break
+		case models.StringLiteral, models.RuneLiteral:
+			quoted(typ, text)
+
+		case models.Identifier:
+			if predeclared, ok := lang.Predeclared[text]; ok && opts.Predeclared {
+				typ = predeclared
 			}
-		}
+			emit(typ, text)
 
-		if opFound {
-			continue
+		case models.IntLiteral, models.FloatLiteral, models.ImaginaryLiteral:
+			typ, err := literal.NumberIn(lang, text)
+			checked(typ, text, err)
+
+		default:
+			emit(typ, text)
 		}
+		input = input[len(text):]
+	}
+
+	if opts.Semicolons && insertSemi {
+		semicolon(len(src))
+	}
+
+	return tokens, diags
+}
+
+// window is the length of the input prefix a lexeme is first looked for
+// in, the window grows by a factor of windowGrowth from there. The regexp
+// package uses a much faster matcher on short inputs, whose cost grows
+// with the length of the input as well.
+const (
+	window       = 32
+	windowGrowth = 8
+)
 
-		// Separators
-		if len(input) > 0 {
-			r := rune(input[0])
-			if separators[r] {
-				tokens = append(tokens, models.Token{Type: models.Separator, Value: string(r)})
-				input = input[1:]
-				continue
+// match is the lexgen.Matcher for the rules of the grammar, see dispatch.
+func (g *grammar) match(input string) (rule, size int) {
+	for n := window; n < len(input); n *= windowGrowth {
+		// The result holds unless the lexeme may go on past the window.
+		// Every prefix of a lexeme is matched by some rule, even the
+		// prefix of a block comment, so a longer lexeme would reach the
+		// end of the window. It must not split a character.
+		end := n
+		for start := n - 1; start > n-utf8.UTFMax && start >= 0; start-- {
+			if utf8.RuneStart(input[start]) {
+				if _, size := utf8.DecodeRuneInString(input[start:]); start+size > n {
+					end = start
+				}
+				break
 			}
 		}
-
-		// Identifiers
-		if id := regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*`).FindString(input); id != "" {
-			tokens = append(tokens, models.Token{Type: models.Identifier, Value: id})
-			input = input[len(id):]
-			continue
+		rule, size := g.find(input[:end])
+		if size < end {
+			return rule, size
 		}
-
-		// Invalid token case
-		return nil, fmt.Errorf("invalid token at: %q", input[:1])
 	}
+	return g.find(input)
+}
 
-	return tokens, nil
+func (g *grammar) find(input string) (rule, size int) {
+	if m := g.first[input[0]]; m != nil {
+		return m.find(input)
+	}
+	return -1, 0
 }
 
-// It is for checking that some lexeme is standalone
-func isIdentifierPart(r rune) bool {
-	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_'
+// illegal reports whether s starts with invalid UTF-8, a NUL byte or a byte
+// order mark that is not at the start of the file.
+func illegal(s string, offset int) bool {
+	r, size := utf8.DecodeRuneInString(s)
+	return s != "" && r == 0 || r == utf8.RuneError && size == 1 || r == bomRune && offset > 0
 }
@@ -0,0 +1,104 @@
+package rxlex
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"analyzer/models"
+)
+
+func TestLexPositions(t *testing.T) {
+	input := "x := \"hi\"\n\tf(1.5)\n"
+	tests := []struct {
+		typ   models.TokenType
+		value string
+		pos   models.Position
+		end   models.Position
+	}{
+		{models.Identifier, "x", models.Position{Offset: 0, Line: 1, Column: 1}, models.Position{Offset: 1, Line: 1, Column: 2}},
+		{models.Operator, ":=", models.Position{Offset: 2, Line: 1, Column: 3}, models.Position{Offset: 4, Line: 1, Column: 5}},
+		{models.StringLiteral, `"hi"`, models.Position{Offset: 5, Line: 1, Column: 6}, models.Position{Offset: 9, Line: 1, Column: 10}},
+		{models.Identifier, "f", models.Position{Offset: 11, Line: 2, Column: 2}, models.Position{Offset: 12, Line: 2, Column: 3}},
+		{models.Separator, "(", models.Position{Offset: 12, Line: 2, Column: 3}, models.Position{Offset: 13, Line: 2, Column: 4}},
+		{models.FloatLiteral, "1.5", models.Position{Offset: 13, Line: 2, Column: 4}, models.Position{Offset: 16, Line: 2, Column: 7}},
+		{models.Separator, ")", models.Position{Offset: 16, Line: 2, Column: 7}, models.Position{Offset: 17, Line: 2, Column: 8}},
+	}
+
+	tokens, err := Lex(input)
+	if err != nil {
+		t.Fatalf("Lex(%q) error: %v", input, err)
+	}
+	if len(tokens) != len(tests) {
+		t.Fatalf("Lex(%q) returned %d tokens; want %d: %v", input, len(tokens), len(tests), tokens)
+	}
+
+	for i, tt := range tests {
+		tok := tokens[i]
+		if tok.Type != tt.typ || tok.Value != tt.value || tok.Pos != tt.pos || tok.End != tt.end {
+			t.Errorf("token %d = %+v; want {%s %q %+v %+v}", i, tok, tt.typ, tt.value, tt.pos, tt.end)
+		}
+	}
+}
+
+// TestLexOmitPositions checks that OmitPositions changes nothing but the
+// positions of the tokens.
+func TestLexOmitPositions(t *testing.T) {
+	input := "x := 1e10001\ny := 08 // c\nz"
+	opts := models.Options{Semicolons: true, Trivia: true, Decode: true}
+	want, wantDiags := LexOptions(input, opts)
+	opts.OmitPositions = true
+	got, diags := LexOptions(input, opts)
+
+	for i := range want {
+		want[i].Pos, want[i].End = models.Position{}, models.Position{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens without positions\n%+v\nwant\n%+v", got, want)
+	}
+	if !reflect.DeepEqual(diags, wantDiags) {
+		t.Errorf("diagnostics without positions %v; want %v", diags, wantDiags)
+	}
+}
+
+func TestLexErrorPosition(t *testing.T) {
+	_, err := Lex("a\n  @")
+	if err == nil || !strings.HasPrefix(err.Error(), "2:3:") {
+		t.Errorf("Lex error = %v; want position 2:3", err)
+	}
+}
+
+// TestLexLongLexemes checks lexemes that don't fit into the window the
+// rules are first matched in.
+func TestLexLongLexemes(t *testing.T) {
+	long := strings.Repeat("x", window)
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{long + "1", []string{long + "1"}},
+		{long[1:] + "ж", []string{long[1:] + "ж"}},
+		{long[3:] + "𝒳", []string{long[3:] + "𝒳"}},
+		{strings.Repeat(long, windowGrowth) + "1", []string{strings.Repeat(long, windowGrowth) + "1"}},
+		{`"` + long + `"`, []string{`"` + long + `"`}},
+		{"`" + long + "\n`", []string{"`" + long + "\n`"}},
+		{"/*" + long + "*/a", []string{"a"}},
+		{strings.Repeat(" ", window-2) + "...", []string{"..."}},
+	}
+
+	for _, tt := range tests {
+		tokens, err := Lex(tt.input)
+		if err != nil {
+			t.Errorf("Lex(%.10q...) error: %v", tt.input, err)
+			continue
+		}
+		var got []string
+		for _, tok := range tokens {
+			got = append(got, tok.Value)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Lex(%.10q...) = %.20q; want %.20q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package rxlex
+
+import (
+	"go/scanner"
+	"go/token"
+	"testing"
+
+	"analyzer/models"
+)
+
+var numberInputs = []string{
+	"0", "123", "1_000", "017", "08", "09.5", "0129i", "0x1F", "0x", "0x1_",
+	"0x1p-2", "0X.8p1", "0x1.8", "0o17", "0o8", "0b101", "0b102", "1.5", "1.",
+	".5", "1e3", "1E+3", "1e", "1p5", "1__0", "3i", "1e3i", "0x1p-2i", ".5i",
+}
+
+type scanned struct {
+	typ models.TokenType
+	err bool
+}
+
+// scanNumber returns the type go/scanner gives to the literal and whether it
+// reports an error.
+func scanNumber(lit string) scanned {
+	var s scanner.Scanner
+	errors := 0
+	file := token.NewFileSet().AddFile("", -1, len(lit))
+	s.Init(file, []byte(lit), func(token.Position, string) { errors++ }, 0)
+	_, tok, _ := s.Scan()
+
+	types := map[token.Token]models.TokenType{
+		token.INT:   models.IntLiteral,
+		token.FLOAT: models.FloatLiteral,
+		token.IMAG:  models.ImaginaryLiteral,
+	}
+	return scanned{typ: types[tok], err: errors > 0}
+}
+
+func TestLexNumbers(t *testing.T) {
+	for _, lit := range numberInputs {
+		input := "x = " + lit + " + y"
+		want := scanNumber(lit)
+		tokens, err := Lex(input)
+		if want.err {
+			if err == nil {
+				t.Errorf("Lex(%q) = %v; want an error", input, tokens)
+			}
+			continue
+		}
+		if err != nil || len(tokens) != 5 || tokens[2].Value != lit || tokens[2].Type != want.typ {
+			t.Errorf("Lex(%q) = %v, %v; want %s %q in the middle", input, tokens, err, want.typ, lit)
+		}
+	}
+}
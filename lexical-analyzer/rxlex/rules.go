@@ -0,0 +1,170 @@
+package rxlex
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"analyzer/language"
+	"analyzer/lexgen"
+	"analyzer/models"
+)
+
+// Numbers of Go of any base, checked against the spec afterwards.
+// Hexadecimal mantissas take only p exponents, e is a hex digit.
+const (
+	exponent = `[eEpP][+-]?[0-9_]*`
+	hexInt   = `0[xX][0-9a-fA-F_]*`
+	octInt   = `0[oObB][0-9_]*`
+	decInt   = `[0-9][0-9_]*`
+	intLit   = hexInt + `|` + octInt + `|` + decInt
+	floatLit = hexInt + `(?:\.[0-9a-fA-F_]*(?:[pP][+-]?[0-9_]*)?|[pP][+-]?[0-9_]*)|` +
+		octInt + `(?:\.[0-9_]*(?:` + exponent + `)?|` + exponent + `)|` +
+		decInt + `(?:\.[0-9_]*(?:` + exponent + `)?|` + exponent + `)|` +
+		`\.[0-9][0-9_]*(?:` + exponent + `)?`
+)
+
+// Numbers of C and JSON span what a C preprocessor scans as one number
+const (
+	cNumber    = `\.?[0-9](?:[eEpP][+-]|[0-9A-Za-z_.])*`
+	jsonNumber = `-?[0-9](?:[eE][+-]|[0-9A-Za-z_.])*`
+)
+
+// Rules are the lexemes of Go for lexgen, see RulesFor.
+var Rules = RulesFor(language.Go)
+
+// RulesFor returns the lexemes of a language for lexgen. The longest lexeme
+// wins, ties go to the rule listed first. Unterminated comments and
+// literals are lexemes of type models.Error, and numbers are classified by
+// literal.NumberIn.
+func RulesFor(lang *models.Language) []lexgen.Rule {
+	var rules []lexgen.Rule
+	add := func(typ models.TokenType, pattern string) {
+		rules = append(rules, lexgen.Rule{Type: typ, Pattern: pattern})
+	}
+
+	add(models.Whitespace, "["+regexp.QuoteMeta(lang.Whitespace)+"]+")
+	if lang.Splice {
+		add(models.Whitespace, `\\\r?\n`)
+	}
+	add(models.Newline, `\n`)
+	if lang.LineComment != "" {
+		add(models.Comment, regexp.QuoteMeta(lang.LineComment)+`[^\n]*`)
+	}
+	if start := lang.BlockComment[0]; start != "" {
+		body, end := blockComment(lang.BlockComment[1])
+		add(models.Comment, regexp.QuoteMeta(start)+body+end)
+		add(models.Error, regexp.QuoteMeta(start)+body+strings.TrimSuffix(end, "+"+regexp.QuoteMeta(lang.BlockComment[1][1:]))+"*")
+	}
+
+	// Literals up to the line end if not terminated, but raw literals up
+	// to the end of the input
+	for _, q := range lang.Quotes {
+		d := regexp.QuoteMeta(string(q.Delim))
+		if q.Raw {
+			add(q.Type, d+`[^`+d+`]*`+d)
+			add(models.Error, d+`[^`+d+`]*`)
+			continue
+		}
+		body := d + `(?:\\.|[^` + d + `\\\n])*`
+		add(q.Type, body+d)
+		add(models.Error, body+`\\?`)
+	}
+
+	switch lang.Numbers {
+	case models.GoNumbers:
+		add(models.ImaginaryLiteral, `(?:`+intLit+`|`+floatLit+`)i`)
+		add(models.FloatLiteral, floatLit)
+		add(models.IntLiteral, intLit)
+	case models.CNumbers:
+		add(models.IntLiteral, cNumber)
+	case models.JSONNumbers:
+		add(models.IntLiteral, jsonNumber)
+	}
+
+	if len(lang.Keywords) > 0 {
+		add(models.Keyword, alternation(lang.Keywords))
+	}
+	literals := make(map[models.TokenType][]string)
+	for _, word := range slices.Sorted(maps.Keys(lang.Literals)) {
+		literals[lang.Literals[word]] = append(literals[lang.Literals[word]], word)
+	}
+	for _, typ := range slices.Sorted(maps.Keys(literals)) {
+		add(typ, alternation(literals[typ]))
+	}
+	switch lang.Identifiers {
+	case models.UnicodeIdentifiers:
+		add(models.Identifier, `[\p{L}_][\p{L}\p{Nd}_]*`)
+	case models.ASCIIIdentifiers:
+		add(models.Identifier, `[A-Za-z_][A-Za-z0-9_]*`)
+	case models.NoIdentifiers:
+		add(models.Error, `[A-Za-z_][A-Za-z0-9_]*`)
+	}
+
+	if len(lang.Operators) > 0 {
+		add(models.Operator, alternation(lang.Operators))
+	}
+	if lang.Separators != "" {
+		add(models.Separator, "["+regexp.QuoteMeta(lang.Separators)+"]")
+	}
+	return rules
+}
+
+// blockComment returns the patterns for the body of a block comment and
+// its end, which has one or two characters.
+func blockComment(end string) (body, close string) {
+	switch len(end) {
+	case 1:
+		c := regexp.QuoteMeta(end)
+		return `[^` + c + `]*`, c
+	case 2:
+		c1, c2 := regexp.QuoteMeta(end[:1]), regexp.QuoteMeta(end[1:])
+		return `(?:[^` + c1 + `]|` + c1 + `+[^` + c1 + c2 + `])*`, c1 + `+` + c2
+	}
+	panic("rxlex: block comment end must have one or two characters")
+}
+
+func alternation(words []string) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(w)
+	}
+	return strings.Join(quoted, "|")
+}
+
+// grammar is a language with its rules compiled into alternations, one
+// for each first byte of a lexeme.
+type grammar struct {
+	lang  *models.Language
+	rules []lexgen.Rule
+	first [256]*matcher
+}
+
+// grammars caches the grammar of each language.
+var grammars sync.Map
+
+func grammarOf(lang *models.Language) *grammar {
+	if g, ok := grammars.Load(lang); ok {
+		return g.(*grammar)
+	}
+	g := &grammar{lang: lang, rules: RulesFor(lang)}
+	patterns := make([]string, len(g.rules))
+	for i, rule := range g.rules {
+		patterns[i] = rule.Pattern
+	}
+	g.first = dispatch(patterns)
+	actual, _ := grammars.LoadOrStore(lang, g)
+	return actual.(*grammar)
+}
+
+// quote returns the quote of the language delimited by c.
+func (g *grammar) quote(c byte) (models.Quote, bool) {
+	for _, q := range g.lang.Quotes {
+		if q.Delim == c {
+			return q, true
+		}
+	}
+	return models.Quote{}, false
+}
@@ -0,0 +1,58 @@
+package rxlex
+
+import (
+	"strings"
+	"testing"
+
+	"analyzer/models"
+)
+
+func TestLexUnicode(t *testing.T) {
+	input := "имя := \"строка\" + 'ж' // комментарий\nπ2 = typeА"
+	want := []models.Token{
+		{Type: models.Identifier, Value: "имя"},
+		{Type: models.Operator, Value: ":="},
+		{Type: models.StringLiteral, Value: `"строка"`},
+		{Type: models.Operator, Value: "+"},
+		{Type: models.RuneLiteral, Value: "'ж'"},
+		{Type: models.Identifier, Value: "π2"},
+		{Type: models.Operator, Value: "="},
+		{Type: models.Identifier, Value: "typeА"},
+	}
+
+	tokens, err := Lex(input)
+	if err != nil {
+		t.Fatalf("Lex(%q) error: %v", input, err)
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("Lex(%q) = %v; want %d tokens", input, tokens, len(want))
+	}
+	for i, tok := range tokens {
+		if tok.Type != want[i].Type || tok.Value != want[i].Value {
+			t.Errorf("token %d = %s %q; want %s %q", i, tok.Type, tok.Value, want[i].Type, want[i].Value)
+		}
+	}
+}
+
+func TestLexIllegalEncoding(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"a \xff b", "1:3: invalid UTF-8 encoding"},
+		{"s = `a\nb\xffc`", "2:2: invalid UTF-8 encoding"},
+		{"a\uFEFFb", "1:2: illegal byte order mark"},
+	}
+
+	for _, tt := range tests {
+		_, err := Lex(tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Lex(%q) error = %v; want %q", tt.input, err, tt.err)
+		}
+	}
+
+	tokens, err := Lex("\uFEFFpackage p")
+	if err != nil || len(tokens) != 2 || tokens[0].Value != "package" {
+		t.Errorf("leading byte order mark: tokens %v, error %v", tokens, err)
+	}
+}
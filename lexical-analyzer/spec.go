@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"analyzer/models"
+	"analyzer/spec"
+)
+
+const specUsage = `Usage:
+	lexer spec lex [FLAGS] SPEC PATH...   lex files, the files below directories
+	                                      or stdin for - with the rules of SPEC
+	lexer spec gen SPEC DIR               write a Go package lexing with SPEC to DIR`
+
+// specCommand loads a lexer specification and lexes files with it or
+// generates a Go package from it. It returns the exit code.
+func specCommand(args []string) int {
+	if len(args) == 1 && (args[0] == "-h" || args[0] == "-help") {
+		fmt.Fprintln(stderr, specUsage)
+		return 0
+	}
+	if len(args) == 0 || args[0] != "lex" && args[0] != "gen" {
+		fmt.Fprintln(stderr, specUsage)
+		return 2
+	}
+
+	if args[0] == "gen" {
+		if len(args) != 3 {
+			fmt.Fprintln(stderr, specUsage)
+			return 2
+		}
+		s, code := readSpec(args[1])
+		if s == nil {
+			return code
+		}
+		if err := generate(s, args[2]); err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return 2
+		}
+		return 0
+	}
+
+	// A specification is for no language in particular, so every file
+	// below directories is lexed unless -include names some
+	fs := newFlagSet("spec lex", "spec lex [FLAGS] SPEC PATH...")
+	sel := selection{fallback: func(path string) bool { return true }}
+	sel.addFlags(fs)
+	if code, stop := parseFlags(fs, args[1:]); stop {
+		return code
+	}
+	if fs.NArg() < 2 {
+		return usageError(fs, "no files to lex")
+	}
+	s, code := readSpec(fs.Arg(0))
+	if s == nil {
+		return code
+	}
+	paths, err := sel.files(fs.Args()[1:])
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+
+	program, err := s.Compile()
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+	code = 0
+	for _, path := range paths {
+		name, input, err := readFile(path)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return 2
+		}
+		tokens, diags := program.Lex(string(input), models.Options{OmitPositions: true})
+		for _, token := range tokens {
+			fmt.Fprintf(stdout, "%s: %s\n", token.Type, token.Value)
+		}
+		for _, d := range diags {
+			fmt.Fprintln(stderr, d.Format(name))
+			code = 1
+		}
+	}
+	return code
+}
+
+// readSpec reads and parses the specification in a file. It returns the
+// exit code if it can't.
+func readSpec(path string) (*spec.Spec, int) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return nil, 2
+	}
+	s, err := spec.Parse(path, string(src))
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return nil, 2
+	}
+	return s, 0
+}
+
+// generate writes the package for s to dir, named after the directory.
+func generate(s *spec.Spec, dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	files, err := s.Generate(filepath.Base(abs))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return err
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), src, 0o666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
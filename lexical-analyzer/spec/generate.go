@@ -0,0 +1,64 @@
+package spec
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+
+	"analyzer/lexgen"
+)
+
+// Generate writes a Go package lexing with the specification. It returns
+// the source files by name: lexer.go with the function Lex and a file with
+// the match function of each start condition.
+func (s *Spec) Generate(pkg string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	var states strings.Builder
+	for _, c := range s.Conditions {
+		dfa, err := s.dfa(c.Name)
+		if err != nil {
+			return nil, err
+		}
+		name := "match" + title(c.Name)
+		src, err := dfa.Generate(lexgen.Config{Package: pkg, Func: name})
+		if err != nil {
+			return nil, err
+		}
+		files["match_"+strings.ToLower(c.Name)+".go"] = src
+		fmt.Fprintf(&states, "{Name: %q, Match: %s, Rules: %#v},\n", c.Name, name, s.Active(c.Name))
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by lexer spec gen from %s. DO NOT EDIT.\n\n", s.Name)
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	b.WriteString("import (\n\"analyzer/models\"\n\"analyzer/spec\"\n)\n\n")
+	b.WriteString("var program = &spec.Program{\nActions: []spec.Action{\n")
+	for _, rule := range s.Rules {
+		begin := -1
+		if rule.Begin != "" {
+			begin = s.condition(rule.Begin)
+		}
+		fmt.Fprintf(&b, "{Type: %s, Skip: %t, Begin: %d},\n", lexgen.TypeExpr(rule.Type), rule.Skip, begin)
+	}
+	fmt.Fprintf(&b, "},\nStates: []spec.State{\n%s},\n}\n\n", states.String())
+	b.WriteString("// Lex lexes input, see spec.Program.Lex.\n")
+	b.WriteString("func Lex(input string, opts models.Options) ([]models.Token, []models.Diagnostic) {\n")
+	b.WriteString("return program.Lex(input, opts)\n}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	files["lexer.go"] = src
+	return files, nil
+}
+
+// title turns the name of a start condition into a part of a Go name,
+// INITIAL into Initial.
+func title(name string) string {
+	runes := []rune(strings.ToLower(name))
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,9 @@
+package spec
+
+import _ "embed"
+
+//go:embed go.l
+var goSpec string
+
+// Go is the specification of the lexemes of Go shipped in go.l.
+var Go = MustParse("go.l", goSpec)
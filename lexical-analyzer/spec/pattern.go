@@ -0,0 +1,128 @@
+package spec
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// expand reads the pattern at the start of src up to the first space outside
+// of a character class or quotes. It returns the pattern in the syntax of
+// the regexp package and the rest of src.
+func expand(src string, defs map[string]string) (pattern, rest string, err error) {
+	var b strings.Builder
+	i := 0
+	for i < len(src) && src[i] != ' ' && src[i] != '\t' {
+		switch c := src[i]; c {
+		case '\\':
+			// An escape, with the braces of \p{Greek} or \x{263a}
+			n := 2
+			if i+2 < len(src) && src[i+2] == '{' && strings.ContainsRune("pPx", rune(src[i+1])) {
+				n = strings.IndexByte(src[i:], '}') + 1
+			}
+			if n <= 0 || i+n > len(src) {
+				return "", "", fmt.Errorf("incomplete escape at the end of %q", src)
+			}
+			b.WriteString(src[i : i+n])
+			i += n
+
+		case '[':
+			n := classLength(src[i:])
+			if n < 0 {
+				return "", "", fmt.Errorf("missing ] in %q", src)
+			}
+			b.WriteString(src[i : i+n])
+			i += n
+
+		case '"':
+			text, n, err := quoted(src[i:])
+			if err != nil {
+				return "", "", err
+			}
+			// Grouped, so that a repetition applies to all of it
+			b.WriteString("(?:" + regexp.QuoteMeta(text) + ")")
+			i += n
+
+		case '{':
+			end := strings.IndexByte(src[i:], '}')
+			name := ""
+			if end > 0 {
+				name = src[i+1 : i+end]
+			}
+			if !isName(name) {
+				// A repetition such as {2,4}
+				b.WriteByte(c)
+				i++
+				continue
+			}
+			def, ok := defs[name]
+			if !ok {
+				return "", "", fmt.Errorf("undefined {%s}", name)
+			}
+			b.WriteString("(?:" + def + ")")
+			i += end + 1
+
+		case '(':
+			// Groups don't capture
+			b.WriteByte(c)
+			if !strings.HasPrefix(src[i+1:], "?") {
+				b.WriteString("?:")
+			}
+			i++
+
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), strings.TrimLeft(src[i:], " \t"), nil
+}
+
+// classLength returns the length of the character class at the start of
+// src, or -1 if it has no end.
+func classLength(src string) int {
+	i := 1
+	if strings.HasPrefix(src[i:], "^") {
+		i++
+	}
+	// A ] right after the opening is part of the class
+	if strings.HasPrefix(src[i:], "]") {
+		i++
+	}
+	for i < len(src) {
+		switch {
+		case src[i] == '\\':
+			i += 2
+		case strings.HasPrefix(src[i:], "[:"):
+			end := strings.Index(src[i:], ":]")
+			if end < 0 {
+				return -1
+			}
+			i += end + 2
+		case src[i] == ']':
+			return i + 1
+		default:
+			i++
+		}
+	}
+	return -1
+}
+
+// quoted returns the text between the quotes at the start of src and the
+// length of the quoted text. Quotes and backslashes in it are escaped with
+// a backslash.
+func quoted(src string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch src[i] {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 < len(src) {
+				i++
+			}
+		}
+		b.WriteByte(src[i])
+	}
+	return "", 0, fmt.Errorf("missing closing quote in %q", src)
+}
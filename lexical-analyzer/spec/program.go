@@ -0,0 +1,83 @@
+package spec
+
+import (
+	"fmt"
+	"strings"
+
+	"analyzer/lexgen"
+	"analyzer/models"
+)
+
+// Program is a specification ready to lex, with a matcher for each start
+// condition. Generated lexers hold one as well.
+type Program struct {
+	Actions []Action
+	States  []State // States[0] is INITIAL
+}
+
+// Action is what the lexer does with the lexemes of a rule.
+type Action struct {
+	Type  models.TokenType
+	Skip  bool // drop the lexemes
+	Begin int  // state entered after a lexeme, -1 to stay
+}
+
+// State is a start condition with the matcher of the rules active in it.
+type State struct {
+	Name  string
+	Match lexgen.Matcher
+	Rules []int // the action of each rule of the matcher
+}
+
+// Compile builds a DFA for each start condition.
+func (s *Spec) Compile() (*Program, error) {
+	p := &Program{}
+	for _, rule := range s.Rules {
+		begin := -1
+		if rule.Begin != "" {
+			begin = s.condition(rule.Begin)
+		}
+		p.Actions = append(p.Actions, Action{Type: rule.Type, Skip: rule.Skip, Begin: begin})
+	}
+	for _, c := range s.Conditions {
+		dfa, err := s.dfa(c.Name)
+		if err != nil {
+			return nil, err
+		}
+		p.States = append(p.States, State{Name: c.Name, Match: dfa.Match, Rules: s.Active(c.Name)})
+	}
+	return p, nil
+}
+
+// dfa compiles the rules active in a start condition. Errors name the line
+// of the offending rule.
+func (s *Spec) dfa(condition string) (*lexgen.DFA, error) {
+	dfa, err := lexgen.Compile(s.Patterns(condition))
+	if err == nil {
+		return dfa, nil
+	}
+	for _, i := range s.Active(condition) {
+		rule := lexgen.Rule{Type: s.Rules[i].Type, Pattern: s.Rules[i].Pattern}
+		if _, err := lexgen.Compile([]lexgen.Rule{rule}); err != nil {
+			_, msg, _ := strings.Cut(err.Error(), ": ")
+			return nil, fmt.Errorf("%s:%d: %s", s.Name, s.Rules[i].Line, msg)
+		}
+	}
+	return nil, err
+}
+
+// Lex lexes the whole input with lexgen.LexSteps, starting in INITIAL.
+func (p *Program) Lex(input string, opts models.Options) ([]models.Token, []models.Diagnostic) {
+	state := &p.States[0]
+	return lexgen.LexSteps(input, opts, func(input string) (int, models.TokenType, bool) {
+		rule, size := state.Match(input)
+		if rule < 0 {
+			return -1, models.Error, false
+		}
+		action := p.Actions[state.Rules[rule]]
+		if action.Begin >= 0 {
+			state = &p.States[action.Begin]
+		}
+		return size, action.Type, action.Skip
+	})
+}
@@ -0,0 +1,128 @@
+package spec_test
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"analyzer/genlex"
+	"analyzer/models"
+	"analyzer/spec"
+)
+
+// strings lexes quoted strings in a start condition, the quotes are
+// skipped and escapes are tokens of their own.
+const stringSpec = `
+%x STRING
+%%
+[a-z]+                 Identifier
+[ \n]+                 Whitespace
+\"                     skip BEGIN(STRING)
+<STRING>[^"\\\n]+      String
+<STRING>\\.            Rune
+<STRING>\"             skip BEGIN(INITIAL)
+<STRING>\n             ERROR BEGIN(INITIAL)
+`
+
+func TestProgramLex(t *testing.T) {
+	s, err := spec.Parse("strings.l", stringSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := s.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input  string
+		opts   models.Options
+		tokens []string
+		errors int
+	}{
+		{`say "hi\n there" now`, models.Options{}, []string{"Identifier say", "String hi", `Rune \n`, "String  there", "Identifier now"}, 0},
+		{"a \"b\nc", models.Options{}, []string{"Identifier a", "String b", "ERROR \n", "Identifier c"}, 1},
+		{"a \"\"", models.Options{Trivia: true}, []string{"Identifier a", "Whitespace  "}, 0},
+		{"a 1 \"1\"", models.Options{}, []string{"Identifier a", "ERROR 1", "String 1"}, 1},
+		{"a\nb", models.Options{Semicolons: true}, []string{"Identifier a", "Separator ;", "Identifier b", "Separator ;"}, 0},
+		{"1 2 3", models.Options{MaxErrors: 2}, []string{"ERROR 1", "ERROR 2"}, 3},
+	}
+	for _, tt := range tests {
+		tokens, diags := p.Lex(tt.input, tt.opts)
+		var got []string
+		for _, tok := range tokens {
+			got = append(got, tok.Type.String()+" "+tok.Value)
+		}
+		if !slices.Equal(got, tt.tokens) || len(diags) != tt.errors {
+			t.Errorf("Lex(%q, %+v) = %q, %v; want %q and %d errors", tt.input, tt.opts, got, diags, tt.tokens, tt.errors)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	s, err := spec.Parse("empty.l", "%x S\n%%\nx Identifier\n<S>y* Identifier\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Compile()
+	if err == nil || err.Error() != "empty.l:4: matches the empty string" {
+		t.Errorf("Compile error = %v; want empty.l:4: matches the empty string", err)
+	}
+}
+
+// TestGo lexes the sources of this module with go.l, which gives the tokens
+// of genlex on code without errors.
+func TestGo(t *testing.T) {
+	p, err := spec.Go.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob("../*/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := models.Options{Semicolons: true, Trivia: true}
+		tokens, diags := p.Lex(string(src), opts)
+		want, wantDiags := genlex.LexOptions(string(src), opts)
+		if len(wantDiags) > 0 {
+			continue
+		}
+		if len(diags) > 0 || !slices.Equal(tokens, want) {
+			t.Errorf("%s: go.l differs from genlex, diagnostics %v", file, diags)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	s, err := spec.Parse("strings.l", stringSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := s.Generate("strlex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name, src := range files {
+		names = append(names, name)
+		if _, err := parser.ParseFile(token.NewFileSet(), name, src, 0); err != nil {
+			t.Errorf("%s doesn't parse: %v\n%s", name, err, src)
+		}
+	}
+	slices.Sort(names)
+	if want := []string{"lexer.go", "match_initial.go", "match_string.go"}; !slices.Equal(names, want) {
+		t.Errorf("Generate files = %v; want %v", names, want)
+	}
+	if lexer := string(files["lexer.go"]); !strings.Contains(lexer, `{Name: "STRING", Match: matchString, Rules: []int{3, 4, 5, 6}}`) {
+		t.Errorf("lexer.go lacks the state STRING:\n%s", lexer)
+	}
+}
@@ -0,0 +1,260 @@
+// Package spec reads lexer specifications in a format modelled on the .l
+// files of flex. A specification has a section of definitions and a section
+// of rules, separated by a line holding only %%:
+//
+//	# Definitions: a name and the pattern it stands for
+//	digit    [0-9]
+//	%x       STRING
+//	%%
+//	# Rules: a pattern, a token type and actions
+//	{digit}+          Int
+//	[ \t\n]+          skip
+//	\"                skip BEGIN(STRING)
+//	<STRING>[^"\n]+   String
+//	<STRING>\"        skip BEGIN(INITIAL)
+//
+// Patterns use the syntax of the regexp package. They end at the first
+// space outside of a character class or quotes. As in flex, {name} stands
+// for a definition, "text" matches text literally and groups don't capture.
+// Lines starting with # are comments.
+//
+// The type of a rule is a token type such as Keyword or ERROR, rules of
+// type ERROR report their lexemes as errors. The action skip drops the
+// lexemes of a rule, BEGIN(NAME) enters a start condition after them.
+//
+// Start conditions are declared with %s (inclusive) or %x (exclusive) in
+// the definitions. A rule prefixed with <NAME,...> is active only in the
+// named conditions, <*> in all of them. Rules without a prefix are active
+// in INITIAL and the inclusive conditions.
+//
+// Lexing follows lexgen: the longest lexeme wins and ties go to the rule
+// listed first.
+package spec
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"slices"
+	"strings"
+
+	"analyzer/lexgen"
+	"analyzer/models"
+)
+
+// Initial is the start condition lexing starts in.
+const Initial = "INITIAL"
+
+// Spec is a parsed lexer specification.
+type Spec struct {
+	Name        string // file name for messages
+	Definitions []Definition
+	Conditions  []Condition // INITIAL comes first
+	Rules       []Rule
+}
+
+// Definition is a named pattern.
+type Definition struct {
+	Name    string
+	Pattern string // with the definitions it refers to expanded
+}
+
+// Condition is a start condition.
+type Condition struct {
+	Name      string
+	Exclusive bool // only rules naming the condition are active in it
+}
+
+// Rule is a pattern with the token type of its lexemes and its actions.
+type Rule struct {
+	Line       int
+	Conditions []string // the prefix <...>, nil if there is none
+	Pattern    string   // in the syntax of the regexp package
+	Type       models.TokenType
+	Skip       bool   // drop the lexemes
+	Begin      string // condition entered after a lexeme, if any
+}
+
+// Parse parses the specification src. Errors are prefixed with name and the
+// line number.
+func Parse(name, src string) (*Spec, error) {
+	s := &Spec{Name: name, Conditions: []Condition{{Name: Initial}}}
+	defs := make(map[string]string)
+	rules := false
+	for i, line := range strings.Split(src, "\n") {
+		errorf := func(format string, args ...any) error {
+			return fmt.Errorf("%s:%d: %s", name, i+1, fmt.Sprintf(format, args...))
+		}
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		switch {
+		case trimmed == "" || trimmed[0] == '#':
+			continue
+		case line == "%%":
+			if rules {
+				return nil, errorf("second %%%%")
+			}
+			rules = true
+			continue
+		}
+
+		if !rules {
+			if err := s.define(trimmed, defs); err != nil {
+				return nil, errorf("%v", err)
+			}
+			continue
+		}
+		rule, err := s.rule(trimmed, defs)
+		if err != nil {
+			return nil, errorf("%v", err)
+		}
+		rule.Line = i + 1
+		s.Rules = append(s.Rules, rule)
+	}
+
+	if len(s.Rules) == 0 {
+		return nil, fmt.Errorf("%s: no rules", name)
+	}
+	for _, rule := range s.Rules {
+		for _, c := range append(slices.Clone(rule.Conditions), rule.Begin) {
+			if c != "" && c != "*" && s.condition(c) < 0 {
+				return nil, fmt.Errorf("%s:%d: undeclared start condition %s", name, rule.Line, c)
+			}
+		}
+	}
+	return s, nil
+}
+
+// MustParse is like Parse but panics on errors. It is meant for
+// specifications shipped with the program.
+func MustParse(name, src string) *Spec {
+	s, err := Parse(name, src)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// define reads a definition or a declaration of start conditions.
+func (s *Spec) define(line string, defs map[string]string) error {
+	fields := strings.Fields(line)
+	if fields[0] == "%s" || fields[0] == "%x" {
+		if len(fields) == 1 {
+			return fmt.Errorf("%s without a start condition", fields[0])
+		}
+		for _, name := range fields[1:] {
+			if !isName(name) {
+				return fmt.Errorf("invalid start condition %q", name)
+			}
+			if s.condition(name) >= 0 {
+				return fmt.Errorf("start condition %s declared twice", name)
+			}
+			s.Conditions = append(s.Conditions, Condition{Name: name, Exclusive: fields[0] == "%x"})
+		}
+		return nil
+	}
+
+	name, rest := fields[0], ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		rest = strings.TrimLeft(line[i:], " \t")
+	}
+	switch {
+	case !isName(name):
+		return fmt.Errorf("invalid definition name %q", name)
+	case rest == "":
+		return fmt.Errorf("definition %s without a pattern", name)
+	}
+	if _, ok := defs[name]; ok {
+		return fmt.Errorf("%s defined twice", name)
+	}
+	pattern, rest, err := expand(rest, defs)
+	if err != nil {
+		return err
+	}
+	if rest != "" {
+		return fmt.Errorf("unexpected %q after the pattern of %s", rest, name)
+	}
+	defs[name] = pattern
+	s.Definitions = append(s.Definitions, Definition{Name: name, Pattern: pattern})
+	return nil
+}
+
+// rule reads a rule.
+func (s *Spec) rule(line string, defs map[string]string) (Rule, error) {
+	var rule Rule
+	if strings.HasPrefix(line, "<") {
+		end := strings.IndexByte(line, '>')
+		if end < 0 {
+			return rule, fmt.Errorf("missing > after start conditions")
+		}
+		for _, name := range strings.Split(line[1:end], ",") {
+			if name = strings.TrimSpace(name); name != "*" && !isName(name) {
+				return rule, fmt.Errorf("invalid start condition %q", name)
+			}
+			rule.Conditions = append(rule.Conditions, strings.TrimSpace(name))
+		}
+		line = line[end+1:]
+	}
+
+	pattern, rest, err := expand(line, defs)
+	if err != nil {
+		return rule, err
+	}
+	if pattern == "" {
+		return rule, fmt.Errorf("rule without a pattern")
+	}
+	if _, err := syntax.Parse(pattern, syntax.Perl); err != nil {
+		return rule, err
+	}
+	rule.Pattern = pattern
+
+	for _, word := range strings.Fields(rest) {
+		switch {
+		case word == "skip":
+			rule.Skip = true
+		case strings.HasPrefix(word, "BEGIN(") && strings.HasSuffix(word, ")"):
+			rule.Begin = word[len("BEGIN(") : len(word)-1]
+		case rule.Type != models.NoType:
+			return rule, fmt.Errorf("unexpected %q after the type %s", word, rule.Type)
+		default:
+			rule.Type = models.NewTokenType(word)
+		}
+	}
+	if rule.Type == models.NoType && !rule.Skip {
+		return rule, fmt.Errorf("rule without a type")
+	}
+	return rule, nil
+}
+
+func (s *Spec) condition(name string) int {
+	return slices.IndexFunc(s.Conditions, func(c Condition) bool { return c.Name == name })
+}
+
+// Active returns the indices of the rules active in a start condition.
+func (s *Spec) Active(condition string) []int {
+	c := s.Conditions[s.condition(condition)]
+	var active []int
+	for i, rule := range s.Rules {
+		if rule.Conditions == nil && !c.Exclusive || slices.Contains(rule.Conditions, "*") || slices.Contains(rule.Conditions, c.Name) {
+			active = append(active, i)
+		}
+	}
+	return active
+}
+
+// Patterns returns the rules active in a start condition for lexgen.
+func (s *Spec) Patterns(condition string) []lexgen.Rule {
+	var rules []lexgen.Rule
+	for _, i := range s.Active(condition) {
+		rules = append(rules, lexgen.Rule{Type: s.Rules[i].Type, Pattern: s.Rules[i].Pattern})
+	}
+	return rules
+}
+
+func isName(s string) bool {
+	for i, r := range s {
+		if !(r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
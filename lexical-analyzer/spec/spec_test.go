@@ -0,0 +1,110 @@
+package spec
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"analyzer/models"
+)
+
+func TestExpand(t *testing.T) {
+	defs := map[string]string{"digit": "[0-9]", "alt": "a|b"}
+	tests := []struct {
+		src     string
+		pattern string
+		rest    string
+	}{
+		{`{digit}+ Int`, `(?:[0-9])+`, "Int"},
+		{`x{2,3}`, `x{2,3}`, ""},
+		{`{alt}c`, `(?:a|b)c`, ""},
+		{`"a+b"*	Op skip`, `(?:a\+b)*`, "Op skip"},
+		{`"say \"hi\""`, `(?:say "hi")`, ""},
+		{`[ \t{}]+ Ws`, `[ \t{}]+`, "Ws"},
+		{`[]a]`, `[]a]`, ""},
+		{`[[:alpha:]]`, `[[:alpha:]]`, ""},
+		{`\p{Greek}\x{263a}\{`, `\p{Greek}\x{263a}\{`, ""},
+		{`(a|b)(?i)c`, `(?:a|b)(?i)c`, ""},
+	}
+	for _, tt := range tests {
+		pattern, rest, err := expand(tt.src, defs)
+		if err != nil || pattern != tt.pattern || rest != tt.rest {
+			t.Errorf("expand(%q) = %q, %q, %v; want %q, %q", tt.src, pattern, rest, err, tt.pattern, tt.rest)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	src := `# numbers
+digit   [0-9]
+	int     {digit}+
+%s  MAYBE
+%x  STRING OTHER
+
+%%
+{int}                  Int
+<STRING>[^"]+          String
+<STRING,MAYBE>\"       skip BEGIN(INITIAL)
+  \"                   skip BEGIN(STRING)
+<*>\n                  Newline
+`
+	s, err := Parse("test.l", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Definitions) != 2 || s.Definitions[1].Pattern != "(?:[0-9])+" {
+		t.Errorf("Definitions = %v", s.Definitions)
+	}
+	want := []Condition{{"INITIAL", false}, {"MAYBE", false}, {"STRING", true}, {"OTHER", true}}
+	if !slices.Equal(s.Conditions, want) {
+		t.Errorf("Conditions = %v; want %v", s.Conditions, want)
+	}
+	if r := s.Rules[2]; r.Line != 10 || !r.Skip || r.Begin != "INITIAL" || r.Type != models.NoType || !slices.Equal(r.Conditions, []string{"STRING", "MAYBE"}) {
+		t.Errorf("Rules[2] = %+v", r)
+	}
+
+	active := []struct {
+		condition string
+		rules     []int
+	}{
+		{"INITIAL", []int{0, 3, 4}},
+		{"MAYBE", []int{0, 2, 3, 4}},
+		{"STRING", []int{1, 2, 4}},
+		{"OTHER", []int{4}},
+	}
+	for _, tt := range active {
+		if got := s.Active(tt.condition); !slices.Equal(got, tt.rules) {
+			t.Errorf("Active(%s) = %v; want %v", tt.condition, got, tt.rules)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"x [a]\n", "test.l: no rules"},
+		{"1x [a]\n%%\na A\n", `test.l:1: invalid definition name "1x"`},
+		{"x\n%%\na A\n", "test.l:1: definition x without a pattern"},
+		{"x a\nx b\n%%\na A\n", "test.l:2: x defined twice"},
+		{"%x\n%%\na A\n", "test.l:1: %x without a start condition"},
+		{"%s A A\n%%\na A\n", "test.l:1: start condition A declared twice"},
+		{"%%\n{y} A\n", "test.l:2: undefined {y}"},
+		{"%%\na\n", "test.l:2: rule without a type"},
+		{"%%\na A B\n", `test.l:2: unexpected "B" after the type A`},
+		{"%%\n[a A\n", "test.l:2: missing ]"},
+		{"%%\n\"a A\n", "test.l:2: missing closing quote"},
+		{"%%\na) A\n", "test.l:2: error parsing regexp"},
+		{"%%\n<S>a A\n", "test.l:2: undeclared start condition S"},
+		{"%%\na A BEGIN(S)\n", "test.l:2: undeclared start condition S"},
+		{"%%\n<S a A\n", "test.l:2: missing > after start conditions"},
+		{"%%\na A\n%%\n", "test.l:3: second %%"},
+	}
+	for _, tt := range tests {
+		_, err := Parse("test.l", tt.src)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("Parse(%q) error = %v; want %q", tt.src, err, tt.err)
+		}
+	}
+}
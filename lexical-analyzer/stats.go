@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+
+	"analyzer/driver"
+	"analyzer/models"
+	"analyzer/stats"
+)
+
+const statsUsage = "stats [FLAGS] PATH..."
+
+// statsCommand prints token statistics of files, added up over all paths
+// and, with -per-path, for each path as well.
+func statsCommand(args []string) int {
+	fs := newFlagSet("stats", statsUsage)
+	var f lexFlags
+	f.add(fs)
+	format := fs.String("format", "text", "output `FORMAT`: text or json")
+	top := fs.Int("top", 10, "list the `N` most frequent identifiers, keywords and operators, 0 for all")
+	trivia := fs.Bool("trivia", false, "count comments, whitespace and newlines as tokens")
+	perPath := fs.Bool("per-path", false, "report each PATH on its own before the total")
+	if code, stop := parseFlags(fs, args); stop {
+		return code
+	}
+	if msg := f.check(); msg != "" {
+		return usageError(fs, "%s", msg)
+	}
+	if *format != "text" && *format != "json" {
+		return usageError(fs, "unknown format %q, want text or json", *format)
+	}
+	if *top < 0 {
+		return usageError(fs, "-top must not be negative")
+	}
+	if fs.NArg() == 0 {
+		return usageError(fs, "no files to count")
+	}
+
+	// The files of each path, so that they can be counted apart
+	var files []string
+	var ends []int
+	for _, path := range fs.Args() {
+		paths, err := f.files([]string{path})
+		if err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return 2
+		}
+		files = append(files, paths...)
+		ends = append(ends, len(files))
+	}
+
+	ctx, stop := interruptible()
+	defer stop()
+	config := driver.Config{
+		Workers: f.workers,
+		Read:    readFile,
+		Lex: func(name string, src []byte) ([]models.Token, []models.Diagnostic) {
+			return f.lex(name, src, models.Options{Trivia: *trivia, OmitPositions: true})
+		},
+	}
+	perPathStats := make([]stats.Stats, fs.NArg())
+	i, path := 0, 0
+	summary, err := config.Run(ctx, files, func(file driver.File) error {
+		if file.Err != nil {
+			return file.Err
+		}
+		for i == ends[path] {
+			path++
+		}
+		i++
+		perPathStats[path].Add(file.Src, file.Tokens)
+		for _, d := range file.Diagnostics {
+			fmt.Fprintln(stderr, d.Format(file.Name))
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+
+	var total stats.Stats
+	var reports []stats.Report
+	for i := range perPathStats {
+		total.Merge(&perPathStats[i])
+		if *perPath {
+			r := perPathStats[i].Report(*top)
+			r.Path = fs.Arg(i)
+			reports = append(reports, r)
+		}
+	}
+	r := total.Report(*top)
+	if *perPath {
+		r.Path = "total"
+	}
+	reports = append(reports, r)
+	if *format == "json" {
+		err = stats.WriteJSON(stdout, reports...)
+	} else {
+		err = stats.WriteText(stdout, reports...)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+	if summary.WithErrors > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,225 @@
+// Package stats counts the tokens of source files: the tokens of each type,
+// the most frequent identifiers, keywords and operators, the distribution
+// of the literal types, the average token length and the tokens per line.
+package stats
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"analyzer/models"
+)
+
+// Literals lists the types the literal distribution is made of.
+var Literals = []models.TokenType{
+	models.IntLiteral,
+	models.FloatLiteral,
+	models.ImaginaryLiteral,
+	models.RuneLiteral,
+	models.StringLiteral,
+	models.BooleanLiteral,
+}
+
+// Stats counts the tokens of the files added to it. The zero value is ready
+// to use.
+type Stats struct {
+	Files  int
+	Lines  int
+	Tokens int
+	Runes  int // the length of all tokens
+
+	Types       map[models.TokenType]int
+	Identifiers map[string]int
+	Keywords    map[string]int
+	Operators   map[string]int
+}
+
+// Add counts the tokens of a file with source src. Implicit tokens, such as
+// inserted semicolons, aren't in the source and aren't counted.
+func (s *Stats) Add(src []byte, tokens []models.Token) {
+	s.makeMaps()
+	s.Files++
+	s.Lines += lines(src)
+	for _, t := range tokens {
+		if t.Implicit {
+			continue
+		}
+		s.Tokens++
+		s.Runes += utf8.RuneCountInString(t.Value)
+		s.Types[t.Type]++
+		switch t.Type {
+		case models.Identifier:
+			s.Identifiers[t.Value]++
+		case models.Keyword:
+			s.Keywords[t.Value]++
+		case models.Operator:
+			s.Operators[t.Value]++
+		}
+	}
+}
+
+func (s *Stats) makeMaps() {
+	if s.Types == nil {
+		s.Types = make(map[models.TokenType]int)
+		s.Identifiers = make(map[string]int)
+		s.Keywords = make(map[string]int)
+		s.Operators = make(map[string]int)
+	}
+}
+
+// lines returns the number of lines of src. A last line without a newline
+// counts too.
+func lines(src []byte) int {
+	n := strings.Count(string(src), "\n")
+	if len(src) > 0 && src[len(src)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// Merge adds the counts of o to s.
+func (s *Stats) Merge(o *Stats) {
+	s.makeMaps()
+	s.Files += o.Files
+	s.Lines += o.Lines
+	s.Tokens += o.Tokens
+	s.Runes += o.Runes
+	for typ, n := range o.Types {
+		s.Types[typ] += n
+	}
+	for _, m := range [][2]map[string]int{{s.Identifiers, o.Identifiers}, {s.Keywords, o.Keywords}, {s.Operators, o.Operators}} {
+		for value, n := range m[1] {
+			m[0][value] += n
+		}
+	}
+}
+
+// Report is the outcome of Stats in the form it is printed in. The lists
+// are sorted by count, most frequent first, and values with the same count
+// by value.
+type Report struct {
+	Path          string  `json:"path,omitempty"`
+	Files         int     `json:"files"`
+	Lines         int     `json:"lines"`
+	Tokens        int     `json:"tokens"`
+	AverageLength float64 `json:"average_length"` // in runes
+	TokensPerLine float64 `json:"tokens_per_line"`
+
+	Types       []Count `json:"types"`
+	Literals    []Count `json:"literals"`
+	Identifiers []Count `json:"identifiers"` // the most frequent ones
+	Keywords    []Count `json:"keywords"`
+	Operators   []Count `json:"operators"`
+}
+
+// Count is the number of tokens with a type or value and their share of
+// the tokens of the list, between 0 and 1.
+type Count struct {
+	Value string  `json:"value"`
+	Count int     `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// Report returns the report of s with the top most frequent identifiers,
+// keywords and operators, all of them if top is 0.
+func (s *Stats) Report(top int) Report {
+	r := Report{
+		Files:         s.Files,
+		Lines:         s.Lines,
+		Tokens:        s.Tokens,
+		AverageLength: ratio(s.Runes, s.Tokens),
+		TokensPerLine: ratio(s.Tokens, s.Lines),
+		Types:         counts(s.Types, 0),
+		Identifiers:   counts(s.Identifiers, top),
+		Keywords:      counts(s.Keywords, top),
+		Operators:     counts(s.Operators, top),
+	}
+	literals := make(map[models.TokenType]int)
+	for _, typ := range Literals {
+		if n := s.Types[typ]; n > 0 {
+			literals[typ] = n
+		}
+	}
+	r.Literals = counts(literals, 0)
+	return r
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// counts sorts m into a list and keeps the first top entries if top > 0.
+// The shares are those of all entries.
+func counts[K comparable](m map[K]int, top int) []Count {
+	list := []Count{}
+	total := 0
+	for value, n := range m {
+		list = append(list, Count{Value: fmt.Sprint(value), Count: n})
+		total += n
+	}
+	slices.SortFunc(list, func(a, b Count) int {
+		return cmp.Or(b.Count-a.Count, strings.Compare(a.Value, b.Value))
+	})
+	for i := range list {
+		list[i].Share = ratio(list[i].Count, total)
+	}
+	if top > 0 && len(list) > top {
+		list = list[:top]
+	}
+	return list
+}
+
+// WriteJSON writes reports as indented JSON: a single report as an object,
+// several as an array.
+func WriteJSON(w io.Writer, reports ...Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if len(reports) == 1 {
+		return enc.Encode(reports[0])
+	}
+	return enc.Encode(reports)
+}
+
+// WriteText writes reports as aligned text, separated by blank lines.
+func WriteText(w io.Writer, reports ...Report) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for i, r := range reports {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		if r.Path != "" {
+			fmt.Fprintf(tw, "%s\n", r.Path)
+		}
+		fmt.Fprintf(tw, "files\t%d\n", r.Files)
+		fmt.Fprintf(tw, "lines\t%d\n", r.Lines)
+		fmt.Fprintf(tw, "tokens\t%d\n", r.Tokens)
+		fmt.Fprintf(tw, "average length\t%.2f\n", r.AverageLength)
+		fmt.Fprintf(tw, "tokens per line\t%.2f\n", r.TokensPerLine)
+		writeCounts(tw, "types", r.Types)
+		writeCounts(tw, "literals", r.Literals)
+		writeCounts(tw, "identifiers", r.Identifiers)
+		writeCounts(tw, "keywords", r.Keywords)
+		writeCounts(tw, "operators", r.Operators)
+	}
+	return tw.Flush()
+}
+
+// writeCounts writes a list under a heading with the shares in percent.
+func writeCounts(w io.Writer, heading string, list []Count) {
+	if len(list) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s\n", heading)
+	for _, c := range list {
+		fmt.Fprintf(w, "  %s\t%d\t%.1f%%\n", c.Value, c.Count, 100*c.Share)
+	}
+}
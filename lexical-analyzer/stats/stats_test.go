@@ -0,0 +1,130 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"analyzer/fsmlex"
+	"analyzer/language"
+	"analyzer/models"
+)
+
+func add(s *Stats, src string, opts models.Options) {
+	tokens, _ := fsmlex.LexOptions(src, opts)
+	s.Add([]byte(src), tokens)
+}
+
+func TestReport(t *testing.T) {
+	var s Stats
+	add(&s, "x := x + 1\ny := \"é\"\n", models.Options{Semicolons: true})
+	add(&s, "if x == 1.5 { x++ }", models.Options{Semicolons: true})
+
+	want := Report{
+		Files:         2,
+		Lines:         3,
+		Tokens:        16,
+		AverageLength: 25.0 / 16,
+		TokensPerLine: 16.0 / 3,
+		Types: []Count{
+			{"Identifier", 5, 5.0 / 16},
+			{"Operator", 5, 5.0 / 16},
+			{"Separator", 2, 2.0 / 16},
+			{"Float", 1, 1.0 / 16},
+			{"Int", 1, 1.0 / 16},
+			{"Keyword", 1, 1.0 / 16},
+			{"String", 1, 1.0 / 16},
+		},
+		Literals:    []Count{{"Float", 1, 1.0 / 3}, {"Int", 1, 1.0 / 3}, {"String", 1, 1.0 / 3}},
+		Identifiers: []Count{{"x", 4, 4.0 / 5}, {"y", 1, 1.0 / 5}},
+		Keywords:    []Count{{"if", 1, 1}},
+		Operators:   []Count{{":=", 2, 2.0 / 5}, {"+", 1, 1.0 / 5}},
+	}
+	if got := s.Report(2); !reflect.DeepEqual(got, want) {
+		t.Errorf("Report(2) =\n%+v\nwant\n%+v", got, want)
+	}
+	if got := s.Report(0); len(got.Operators) != 4 || got.Operators[2] != (Count{"++", 1, 1.0 / 5}) {
+		t.Errorf("Report(0) operators = %v; want all 4", got.Operators)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	srcs := []struct {
+		src  string
+		opts models.Options
+	}{
+		{"package p\n\nvar s = []string{\"a\", `b`}\n", models.Options{}},
+		{"int main(void) { return 'a' + 0x1Fu; }", models.Options{Language: language.C}},
+		{`{"a": [true, null, 1e3]}`, models.Options{Language: language.JSON, Trivia: true}},
+		{"", models.Options{}},
+	}
+
+	var all, merged Stats
+	for _, tt := range srcs {
+		add(&all, tt.src, tt.opts)
+		var one Stats
+		add(&one, tt.src, tt.opts)
+		merged.Merge(&one)
+	}
+	if !reflect.DeepEqual(merged, all) {
+		t.Errorf("merged stats\n%+v\ndiffer from\n%+v", merged, all)
+	}
+	if all.Files != 4 || all.Lines != 5 {
+		t.Errorf("%d files and %d lines; want 4 and 5", all.Files, all.Lines)
+	}
+
+	var empty Stats
+	r := empty.Report(10)
+	if r.AverageLength != 0 || r.TokensPerLine != 0 || r.Types == nil {
+		t.Errorf("report of no files = %+v", r)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var s Stats
+	add(&s, "a = b\n", models.Options{})
+	r := s.Report(10)
+
+	var text strings.Builder
+	if err := WriteText(&text, r); err != nil {
+		t.Fatal(err)
+	}
+	want := `files            1
+lines            1
+tokens           3
+average length   1.00
+tokens per line  3.00
+
+types
+  Identifier  2  66.7%
+  Operator    1  33.3%
+
+identifiers
+  a  1  50.0%
+  b  1  50.0%
+
+operators
+  =  1  100.0%
+`
+	if text.String() != want {
+		t.Errorf("WriteText:\n%s\nwant\n%s", text.String(), want)
+	}
+
+	var buf bytes.Buffer
+	r.Path = "x"
+	if err := WriteJSON(&buf, r, r); err != nil {
+		t.Fatal(err)
+	}
+	var got []Report
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []Report{r, r}) {
+		t.Errorf("WriteJSON: got\n%+v\nwant\n%+v", got, []Report{r, r})
+	}
+	if !strings.Contains(buf.String(), `"keywords": []`) {
+		t.Errorf("WriteJSON: empty lists aren't arrays:\n%s", buf.String())
+	}
+}